@@ -0,0 +1,320 @@
+package sitebuilder
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "path"
+    "strings"
+    "sync"
+
+    "crypto/sha256"
+    "encoding/hex"
+    "io/ioutil"
+    "path/filepath"
+
+    "github.com/dsoprea/go-logging"
+)
+
+const (
+    defaultAssetsPath = "assets"
+)
+
+// assetPublisher tracks the local files that have been referenced by
+// PublishedResourceLocators and copies them into the output path.
+type assetPublisher struct {
+    // publishedBySource maps source file-paths to their output path relative
+    // to the HTML output path.
+    publishedBySource map[string]string
+
+    // sourceByPublished maps output paths relative to the HTML output path
+    // back to the source file-paths. Since the output filenames are derived
+    // from the content, there is one entry per distinct file.
+    sourceByPublished map[string]string
+
+    // dataByPublished maps output paths relative to the HTML output path to
+    // data that was generated rather than read from a file (e.g.
+    // thumbnails) and has not been written yet.
+    dataByPublished map[string][]byte
+
+    // writtenData has the output paths of the data that was written by the
+    // current write.
+    writtenData map[string]struct{}
+
+    // writing is true while the site is being written. Data that is published
+    // then is written immediately rather than kept.
+    writing bool
+
+    lock sync.Mutex
+}
+
+func newAssetPublisher() *assetPublisher {
+    return &assetPublisher{
+        publishedBySource: make(map[string]string),
+        sourceByPublished: make(map[string]string),
+        dataByPublished:   make(map[string][]byte),
+        writtenData:       make(map[string]struct{}),
+    }
+}
+
+// startWriting has data that is published from now on written to the output
+// path as soon as it is published. The returned function ends this.
+func (ap *assetPublisher) startWriting() (endWriting func()) {
+    ap.lock.Lock()
+    defer ap.lock.Unlock()
+
+    ap.writing = true
+    ap.writtenData = make(map[string]struct{})
+
+    return func() {
+        ap.lock.Lock()
+        defer ap.lock.Unlock()
+
+        ap.writing = false
+        ap.writtenData = make(map[string]struct{})
+    }
+}
+
+// publish registers the given file to be copied and returns its path relative
+// to the HTML output path.
+func (ap *assetPublisher) publish(sc *SiteContext, localFilepath string) (publishedPath string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    ap.lock.Lock()
+    publishedPath, found := ap.publishedBySource[localFilepath]
+    ap.lock.Unlock()
+
+    if found == true {
+        return publishedPath, nil
+    }
+
+    // The file is hashed without holding the lock so that pages that publish
+    // other files are not held up. A file that is published by two pages at
+    // once is hashed twice, with the same result.
+
+    f, err := os.Open(localFilepath)
+    log.PanicIf(err)
+
+    defer f.Close()
+
+    h := sha256.New()
+
+    _, err = io.Copy(h, f)
+    log.PanicIf(err)
+
+    digest := hex.EncodeToString(h.Sum(nil))
+    extension := strings.ToLower(filepath.Ext(localFilepath))
+
+    filename := fmt.Sprintf("%s%s", digest, extension)
+    publishedPath = path.Join(sc.AssetsPath(), filename)
+
+    ap.lock.Lock()
+    defer ap.lock.Unlock()
+
+    ap.publishedBySource[localFilepath] = publishedPath
+
+    if _, found := ap.sourceByPublished[publishedPath]; found == false {
+        ap.sourceByPublished[publishedPath] = localFilepath
+    }
+
+    return publishedPath, nil
+}
+
+// publishData registers the given data to be written and returns its path
+// relative to the HTML output path. The extension (e.g. ".png") is appended to
+// the filename, which is derived from the data. While the site is being
+// written, the data is written immediately rather than kept until the end of
+// the build.
+func (ap *assetPublisher) publishData(sc *SiteContext, data []byte, extension string) (publishedPath string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    digest := sha256.Sum256(data)
+
+    filename := fmt.Sprintf("%s%s", hex.EncodeToString(digest[:]), strings.ToLower(extension))
+    publishedPath = path.Join(sc.AssetsPath(), filename)
+
+    ap.lock.Lock()
+
+    _, pending := ap.dataByPublished[publishedPath]
+    _, written := ap.writtenData[publishedPath]
+
+    writeNow := false
+
+    if pending == false && written == false {
+        if ap.writing == true {
+            ap.writtenData[publishedPath] = struct{}{}
+            writeNow = true
+        } else {
+            ap.dataByPublished[publishedPath] = data
+        }
+    }
+
+    ap.lock.Unlock()
+
+    if writeNow == true {
+        err := writeAsset(sc, publishedPath, data)
+        if err != nil {
+            // Let the next page that publishes the data try again.
+            ap.lock.Lock()
+            delete(ap.writtenData, publishedPath)
+            ap.lock.Unlock()
+
+            log.Panic(err)
+        }
+    }
+
+    return publishedPath, nil
+}
+
+// writeToPath copies every published file, and writes the published data
+// that has not been written yet, into the output path. Files that already
+// exist are skipped since their names are derived from their content. The
+// data is released once it is written.
+func (ap *assetPublisher) writeToPath(sc *SiteContext) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    ap.lock.Lock()
+    defer ap.lock.Unlock()
+
+    for publishedPath, localFilepath := range ap.sourceByPublished {
+        outputFilepath := path.Join(sc.HtmlOutputPath(), publishedPath)
+
+        if _, err := os.Stat(outputFilepath); err == nil {
+            continue
+        } else if os.IsNotExist(err) == false {
+            log.Panic(err)
+        }
+
+        err := os.MkdirAll(path.Dir(outputFilepath), 0755)
+        log.PanicIf(err)
+
+        err = copyFile(localFilepath, outputFilepath)
+        log.PanicIf(err)
+    }
+
+    for publishedPath, data := range ap.dataByPublished {
+        err := writeAsset(sc, publishedPath, data)
+        log.PanicIf(err)
+
+        delete(ap.dataByPublished, publishedPath)
+    }
+
+    return nil
+}
+
+// writeAsset writes the published data into the output path unless it is
+// already there.
+func writeAsset(sc *SiteContext, publishedPath string, data []byte) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    outputFilepath := path.Join(sc.HtmlOutputPath(), publishedPath)
+
+    if _, err := os.Stat(outputFilepath); err == nil {
+        return nil
+    } else if os.IsNotExist(err) == false {
+        log.Panic(err)
+    }
+
+    err = os.MkdirAll(path.Dir(outputFilepath), 0755)
+    log.PanicIf(err)
+
+    err = writeFile(outputFilepath, data)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// copyFile copies the file via a temporary file so that an interrupted copy
+// is never mistaken for a published file.
+func copyFile(fromFilepath, toFilepath string) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    from, err := os.Open(fromFilepath)
+    log.PanicIf(err)
+
+    defer from.Close()
+
+    tempFilepath := toFilepath + ".partial"
+
+    to, err := os.Create(tempFilepath)
+    log.PanicIf(err)
+
+    _, err = io.Copy(to, from)
+    if err != nil {
+        to.Close()
+        os.Remove(tempFilepath)
+
+        log.Panic(err)
+    }
+
+    err = to.Close()
+    log.PanicIf(err)
+
+    err = os.Rename(tempFilepath, toFilepath)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// writeFile writes the data via a temporary file for the same reason as
+// copyFile.
+func writeFile(toFilepath string, data []byte) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    tempFilepath := toFilepath + ".partial"
+
+    err = ioutil.WriteFile(tempFilepath, data, 0644)
+    if err != nil {
+        os.Remove(tempFilepath)
+
+        log.Panic(err)
+    }
+
+    err = os.Rename(tempFilepath, toFilepath)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// SetAssetsPath sets the subdirectory of the HTML output path that published
+// resources are copied into. ErrInvalidOutputPath is returned if it is not
+// inside the output path. Pages may not be written into it (see Validate).
+func (sc *SiteContext) SetAssetsPath(assetsPath string) (err error) {
+    cleaned := path.Clean(assetsPath)
+
+    if isInsideOutputPath(cleaned) == false {
+        return fmt.Errorf("%w: assets path [%s] is not inside the output path", ErrInvalidOutputPath, assetsPath)
+    }
+
+    sc.assetsPath = cleaned
+
+    return nil
+}
+
+func (sc *SiteContext) AssetsPath() string {
+    return sc.assetsPath
+}
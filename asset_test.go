@@ -0,0 +1,251 @@
+package sitebuilder
+
+import (
+    "fmt"
+    "os"
+    "path"
+    "reflect"
+    "sort"
+    "testing"
+
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+const (
+    // testAssetDigest is the SHA-256 of the bytes {1, 2, 3}.
+    testAssetDigest = "039058c6f2c0cb492c533b0a4d14ef77cc0f78abccced5287d84a1a2011cfb81"
+)
+
+func writeTestAsset(tempPath, filename string) string {
+    filepath := path.Join(tempPath, filename)
+
+    err := ioutil.WriteFile(filepath, []byte{1, 2, 3}, 0644)
+    log.PanicIf(err)
+
+    return filepath
+}
+
+func TestPublishedResourceLocator_Uri(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    assetFilepath := writeTestAsset(tempPath, "image.PNG")
+
+    sc := NewSiteContext("")
+    sb := NewSiteBuilder("site title", NewTestDialect(), sc)
+
+    prl := NewPublishedResourceLocator(sb, assetFilepath)
+
+    uri := prl.Uri()
+    expected := fmt.Sprintf("assets/%s.png", testAssetDigest)
+
+    if uri != expected {
+        t.Fatalf("URI not correct: [%s]", uri)
+    }
+}
+
+func TestPublishedResourceLocator_Uri_CustomAssetsPath(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    assetFilepath := writeTestAsset(tempPath, "image.png")
+
+    sc := NewSiteContext("")
+    err = sc.SetAssetsPath("static/files")
+    log.PanicIf(err)
+
+    sb := NewSiteBuilder("site title", NewTestDialect(), sc)
+
+    prl := NewPublishedResourceLocator(sb, assetFilepath)
+
+    uri := prl.Uri()
+    expected := fmt.Sprintf("static/files/%s.png", testAssetDigest)
+
+    if uri != expected {
+        t.Fatalf("URI not correct: [%s]", uri)
+    }
+}
+
+func TestSiteBuilder_WriteToPath_PublishedResource(t *testing.T) {
+    sourcePath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(sourcePath)
+
+    outputPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(outputPath)
+
+    // Two different files with identical content should only be published
+    // once.
+
+    assetFilepath1 := writeTestAsset(sourcePath, "image1.png")
+    assetFilepath2 := writeTestAsset(sourcePath, "image2.png")
+
+    sc := NewSiteContext(outputPath)
+    sb := NewSiteBuilder("site title", NewTestDialect(), sc)
+
+    rootNode := sb.Root()
+
+    iw := NewImageWidget("image alt text", NewPublishedResourceLocator(sb, assetFilepath1), 0, 0)
+
+    err = rootNode.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    childNode1, err := rootNode.AddChildNode("child1", "Child1")
+    log.PanicIf(err)
+
+    iw = NewImageWidget("image alt text", NewPublishedResourceLocator(sb, assetFilepath1), 0, 0)
+
+    err = childNode1.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    childNode2, err := rootNode.AddChildNode("child2", "Child2")
+    log.PanicIf(err)
+
+    iw = NewImageWidget("image alt text", NewPublishedResourceLocator(sb, assetFilepath2), 0, 0)
+
+    err = childNode2.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    files, err := ioutil.ReadDir(path.Join(outputPath, "assets"))
+    log.PanicIf(err)
+
+    actualFiles := make([]string, 0)
+    for _, fi := range files {
+        actualFiles = append(actualFiles, fi.Name())
+    }
+
+    sort.Strings(actualFiles)
+
+    expectedFiles := []string{
+        fmt.Sprintf("%s.png", testAssetDigest),
+    }
+
+    if reflect.DeepEqual(actualFiles, expectedFiles) != true {
+        t.Fatalf("Published files not correct: %v", actualFiles)
+    }
+
+    raw, err := ioutil.ReadFile(path.Join(outputPath, "assets", expectedFiles[0]))
+    log.PanicIf(err)
+
+    if reflect.DeepEqual(raw, []byte{1, 2, 3}) != true {
+        t.Fatalf("Published content not correct.")
+    }
+
+    actual, err := ioutil.ReadFile(path.Join(outputPath, "child2.html"))
+    log.PanicIf(err)
+
+    expected := fmt.Sprintf("<header>Child2</header>\n<widget>image alt text | assets/%s.png</widget>\n<footer>Child2</footer>\n", testAssetDigest)
+
+    if string(actual) != expected {
+        t.Fatalf("Page not correct:\n%s", actual)
+    }
+}
+
+func TestPublishedResourceLocator_UriFor(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    assetFilepath := writeTestAsset(tempPath, "image.png")
+
+    sb := getLayoutTestSite("")
+    sb.Context().SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+
+    prl := NewPublishedResourceLocator(sb, assetFilepath)
+
+    childChildNode1 := sb.Root().Children[0].Children[0]
+
+    uri := prl.UriFor(childChildNode1)
+    expected := fmt.Sprintf("../../assets/%s.png", testAssetDigest)
+
+    if uri != expected {
+        t.Fatalf("URI not correct: [%s]", uri)
+    }
+}
+
+func TestAssetPublisher_PublishData(t *testing.T) {
+    outputPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(outputPath)
+
+    sc := NewSiteContext(outputPath)
+    ap := newAssetPublisher()
+
+    publishedPath, err := ap.publishData(sc, []byte{1, 2, 3}, ".PNG")
+    log.PanicIf(err)
+
+    if publishedPath != fmt.Sprintf("assets/%s.png", testAssetDigest) {
+        t.Fatalf("Published path not correct: [%s]", publishedPath)
+    }
+
+    // The same data is only written once.
+
+    secondPublishedPath, err := ap.publishData(sc, []byte{1, 2, 3}, ".png")
+    log.PanicIf(err)
+
+    if secondPublishedPath != publishedPath {
+        t.Fatalf("Published path not stable.")
+    }
+
+    err = ap.writeToPath(sc)
+    log.PanicIf(err)
+
+    raw, err := ioutil.ReadFile(path.Join(outputPath, publishedPath))
+    log.PanicIf(err)
+
+    if reflect.DeepEqual(raw, []byte{1, 2, 3}) != true {
+        t.Fatalf("Published content not correct.")
+    } else if len(ap.dataByPublished) != 0 {
+        t.Fatalf("Written data should be released.")
+    }
+}
+
+func TestAssetPublisher_PublishData_Writing(t *testing.T) {
+    outputPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(outputPath)
+
+    sc := NewSiteContext(outputPath)
+    ap := newAssetPublisher()
+
+    endWriting := ap.startWriting()
+
+    // While the site is being written, the data is written immediately and
+    // not kept.
+
+    publishedPath, err := ap.publishData(sc, []byte{1, 2, 3}, ".png")
+    log.PanicIf(err)
+
+    raw, err := ioutil.ReadFile(path.Join(outputPath, publishedPath))
+    log.PanicIf(err)
+
+    if reflect.DeepEqual(raw, []byte{1, 2, 3}) != true {
+        t.Fatalf("Published content not correct.")
+    } else if len(ap.dataByPublished) != 0 {
+        t.Fatalf("Written data should not be kept.")
+    }
+
+    endWriting()
+
+    _, err = ap.publishData(sc, []byte{4, 5, 6}, ".png")
+    log.PanicIf(err)
+
+    if len(ap.dataByPublished) != 1 {
+        t.Fatalf("Data published outside of a write should be kept until it is written.")
+    }
+}
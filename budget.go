@@ -0,0 +1,280 @@
+package sitebuilder
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "sync"
+
+    "encoding/base64"
+)
+
+var (
+    // ErrEmbeddedBudgetExceeded indicates that the resources embedded in a
+    // page, or in the whole site, are larger than the budget allows.
+    ErrEmbeddedBudgetExceeded = errors.New("embedded resource budget exceeded")
+)
+
+// EmbeddedBudgetPolicy determines what happens to a resource that would
+// exceed the embedded-resource budget.
+type EmbeddedBudgetPolicy int
+
+const (
+    // FailEmbeddedBudgetPolicy fails the page with ErrEmbeddedResourceTooLarge
+    // or ErrEmbeddedBudgetExceeded.
+    FailEmbeddedBudgetPolicy EmbeddedBudgetPolicy = iota
+
+    // PublishEmbeddedBudgetPolicy writes the resource into the assets
+    // subdirectory of the output path and refers to it there instead.
+    PublishEmbeddedBudgetPolicy
+
+    // DownscaleEmbeddedBudgetPolicy scales images down until they fit. Other
+    // resources, and images that can not be made to fit, fail as with
+    // FailEmbeddedBudgetPolicy.
+    DownscaleEmbeddedBudgetPolicy
+)
+
+// EmbeddedBudget limits the size of the resources that are embedded in pages.
+// The sizes are of the data before it is encoded. A limit of zero means that
+// there is no limit.
+type EmbeddedBudget struct {
+    // MaxResourceSize is the largest single resource.
+    MaxResourceSize int64
+
+    // MaxPageSize is the largest total of the resources embedded in one page.
+    MaxPageSize int64
+
+    // MaxSiteSize is the largest total of the resources embedded in all
+    // pages. Pages are rendered concurrently, so which resources exceed it
+    // depends on the order in which they are rendered. Incremental builds
+    // count the pages that they do not rewrite from the manifest.
+    MaxSiteSize int64
+
+    Policy EmbeddedBudgetPolicy
+}
+
+// NewEmbeddedBudget returns the default budget, which only limits single
+// resources to MaxEmbeddedResourceSize and fails those that are larger.
+func NewEmbeddedBudget() EmbeddedBudget {
+    return EmbeddedBudget{
+        MaxResourceSize: MaxEmbeddedResourceSize,
+        Policy:          FailEmbeddedBudgetPolicy,
+    }
+}
+
+// check returns an error if the resource can not be embedded in a page that
+// already has `pageSize` bytes embedded in a site that has `siteSize` bytes
+// embedded.
+func (eb EmbeddedBudget) check(size, pageSize, siteSize int64) error {
+    if eb.MaxResourceSize > 0 && size > eb.MaxResourceSize {
+        return fmt.Errorf("%w: (%d) bytes exceeds the limit of (%d)", ErrEmbeddedResourceTooLarge, size, eb.MaxResourceSize)
+    } else if eb.MaxPageSize > 0 && pageSize+size > eb.MaxPageSize {
+        return fmt.Errorf("%w: (%d) bytes would make the page (%d) bytes, exceeding the limit of (%d)", ErrEmbeddedBudgetExceeded, size, pageSize+size, eb.MaxPageSize)
+    } else if eb.MaxSiteSize > 0 && siteSize+size > eb.MaxSiteSize {
+        return fmt.Errorf("%w: (%d) bytes would make the site (%d) bytes, exceeding the limit of (%d)", ErrEmbeddedBudgetExceeded, size, siteSize+size, eb.MaxSiteSize)
+    }
+
+    return nil
+}
+
+// available returns the size of the largest resource that could still be
+// embedded.
+func (eb EmbeddedBudget) available(pageSize, siteSize int64) int64 {
+    available := int64(math.MaxInt64)
+
+    if eb.MaxResourceSize > 0 && eb.MaxResourceSize < available {
+        available = eb.MaxResourceSize
+    }
+
+    if eb.MaxPageSize > 0 && eb.MaxPageSize-pageSize < available {
+        available = eb.MaxPageSize - pageSize
+    }
+
+    if eb.MaxSiteSize > 0 && eb.MaxSiteSize-siteSize < available {
+        available = eb.MaxSiteSize - siteSize
+    }
+
+    return available
+}
+
+// SetEmbeddedBudget sets the limits on the resources embedded in the pages.
+func (sc *SiteContext) SetEmbeddedBudget(eb EmbeddedBudget) {
+    sc.embeddedBudget = eb
+}
+
+func (sc *SiteContext) EmbeddedBudget() EmbeddedBudget {
+    return sc.embeddedBudget
+}
+
+// embeddedAccountant tracks how much data has been embedded in each page so
+// that the budget can be applied.
+type embeddedAccountant struct {
+    pageSizes map[string]int64
+    siteSize  int64
+
+    lock sync.Mutex
+}
+
+func newEmbeddedAccountant() *embeddedAccountant {
+    return &embeddedAccountant{
+        pageSizes: make(map[string]int64),
+    }
+}
+
+// resetPage forgets what was embedded in the page. This is done before the
+// page is rendered so that rendering it again is not counted twice.
+func (ea *embeddedAccountant) resetPage(pageId string) {
+    ea.seedPage(pageId, 0)
+}
+
+// seedPage records that the page has the given size embedded. This is used
+// for pages that are not rendered by an incremental build so that the site
+// total still includes them.
+func (ea *embeddedAccountant) seedPage(pageId string, size int64) {
+    ea.lock.Lock()
+    defer ea.lock.Unlock()
+
+    ea.siteSize -= ea.pageSizes[pageId]
+
+    if size == 0 {
+        delete(ea.pageSizes, pageId)
+    } else {
+        ea.pageSizes[pageId] = size
+        ea.siteSize += size
+    }
+}
+
+// pageSize returns the size embedded in the page.
+func (ea *embeddedAccountant) pageSize(pageId string) int64 {
+    ea.lock.Lock()
+    defer ea.lock.Unlock()
+
+    return ea.pageSizes[pageId]
+}
+
+// reserve checks the size against the budget and, if it fits, counts it
+// against the page. If it does not fit, the error and the size of the
+// largest resource that would still fit are returned.
+func (ea *embeddedAccountant) reserve(pageId string, size int64, eb EmbeddedBudget) (available int64, err error) {
+    ea.lock.Lock()
+    defer ea.lock.Unlock()
+
+    pageSize := ea.pageSizes[pageId]
+
+    err = eb.check(size, pageSize, ea.siteSize)
+    if err != nil {
+        return eb.available(pageSize, ea.siteSize), err
+    }
+
+    ea.pageSizes[pageId] = pageSize + size
+    ea.siteSize += size
+
+    return 0, nil
+}
+
+// release returns part of a reservation.
+func (ea *embeddedAccountant) release(pageId string, size int64) {
+    ea.lock.Lock()
+    defer ea.lock.Unlock()
+
+    ea.pageSizes[pageId] -= size
+    ea.siteSize -= size
+}
+
+// embed returns the URI that the page uses to refer to the data. This is a
+// "data:" URI unless the budget would be exceeded, in which case the policy
+// is applied. The extension (e.g. ".png") is used if the data is published.
+// The size is reserved before any images are scaled or files are written so
+// that pages are not held up by each other.
+func (ea *embeddedAccountant) embed(sn *SiteNode, mimeType string, raw []byte, extension string) (uri string, err error) {
+    sb := sn.sb
+    eb := sb.Context().EmbeddedBudget()
+
+    available, budgetErr := ea.reserve(sn.PageId, int64(len(raw)), eb)
+    if budgetErr == nil {
+        return dataUri(mimeType, raw), nil
+    }
+
+    switch eb.Policy {
+    case PublishEmbeddedBudgetPolicy:
+        publishedPath, err := sb.assets.publishData(sb.Context(), raw, extension)
+        if err != nil {
+            return "", err
+        }
+
+        return relativeUriFrom(sn, publishedPath), nil
+    case DownscaleEmbeddedBudgetPolicy:
+        if available <= 0 {
+            return "", budgetErr
+        }
+
+        // Hold the space that is left while the image is scaled and give
+        // back whatever is not used.
+        _, err := ea.reserve(sn.PageId, available, eb)
+        if err != nil {
+            return "", budgetErr
+        }
+
+        downscaled, downscaledMimeType, err := downscaleToFit(raw, available)
+        if err != nil {
+            ea.release(sn.PageId, available)
+            return "", fmt.Errorf("%w (%s)", budgetErr, err.Error())
+        }
+
+        ea.release(sn.PageId, available-int64(len(downscaled)))
+
+        return dataUri(downscaledMimeType, downscaled), nil
+    }
+
+    return "", budgetErr
+}
+
+// embedData returns a "data:" URI for the data for use in the given page,
+// subject to the embedded-resource budget of its site. Without a site, there
+// is no budget.
+func embedData(sn *SiteNode, mimeType string, raw []byte, extension string) (uri string, err error) {
+    if sn == nil || sn.sb == nil {
+        return dataUri(mimeType, raw), nil
+    }
+
+    return sn.sb.embedded.embed(sn, mimeType, raw, extension)
+}
+
+func dataUri(mimeType string, raw []byte) string {
+    return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(raw))
+}
+
+// downscaleToFit scales the image down until it is encoded in no more than
+// `limit` bytes.
+func downscaleToFit(raw []byte, limit int64) (data []byte, mimeType string, err error) {
+    original, err := decodeImage(raw)
+    if err != nil {
+        return nil, "", err
+    }
+
+    bounds := original.Bounds()
+
+    // The encoded size is roughly proportional to the area, so start at the
+    // scale that would make it fit and shrink from there.
+    scale := math.Sqrt(float64(limit) / float64(len(raw)))
+
+    for {
+        width := int(float64(bounds.Dx()) * scale)
+        height := int(float64(bounds.Dy()) * scale)
+
+        if width < 1 || height < 1 {
+            return nil, "", fmt.Errorf("image can not be made small enough")
+        }
+
+        data, mimeType, _, err = encodeScaledImage(original, width, height)
+        if err != nil {
+            return nil, "", err
+        }
+
+        if int64(len(data)) <= limit {
+            return data, mimeType, nil
+        }
+
+        scale *= 0.75
+    }
+}
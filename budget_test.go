@@ -0,0 +1,307 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "errors"
+    "image"
+    "os"
+    "strings"
+    "testing"
+
+    "encoding/base64"
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func TestEmbeddedBudget_check(t *testing.T) {
+    eb := EmbeddedBudget{
+        MaxResourceSize: 10,
+        MaxPageSize:     20,
+        MaxSiteSize:     30,
+    }
+
+    cases := []struct {
+        size, pageSize, siteSize int64
+        expected                 error
+    }{
+        {10, 10, 20, nil},
+        {11, 0, 0, ErrEmbeddedResourceTooLarge},
+        {10, 11, 11, ErrEmbeddedBudgetExceeded},
+        {10, 0, 21, ErrEmbeddedBudgetExceeded},
+    }
+
+    for i, c := range cases {
+        err := eb.check(c.size, c.pageSize, c.siteSize)
+        if c.expected == nil && err != nil {
+            t.Fatalf("Case (%d) should fit: [%v]", i, err)
+        } else if c.expected != nil && errors.Is(err, c.expected) != true {
+            t.Fatalf("Case (%d) error not correct: [%v]", i, err)
+        }
+    }
+
+    if available := eb.available(15, 25); available != 5 {
+        t.Fatalf("Available size not correct: (%d)", available)
+    }
+
+    if err := NewEmbeddedBudget().check(MaxEmbeddedResourceSize, 1024*1024*1024, 1024*1024*1024); err != nil {
+        t.Fatalf("Default budget should only limit single resources: [%v]", err)
+    }
+}
+
+func TestEmbeddedResourceLocator_CheckedUriFor_Budget(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    sb.Context().SetEmbeddedBudget(EmbeddedBudget{
+        MaxPageSize: 10,
+        MaxSiteSize: 15,
+    })
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("image/png", []byte{1, 2, 3, 4, 5, 6})
+    log.PanicIf(err)
+
+    rootNode := sb.Root()
+    childNode1 := rootNode.Children[0]
+
+    uri, err := erl.CheckedUriFor(rootNode)
+    log.PanicIf(err)
+
+    if uri != "data:image/png;base64,AQIDBAUG" {
+        t.Fatalf("URI not correct: [%s]", uri)
+    }
+
+    _, err = erl.CheckedUriFor(rootNode)
+    if errors.Is(err, ErrEmbeddedBudgetExceeded) != true {
+        t.Fatalf("Page budget should be exceeded: [%v]", err)
+    }
+
+    _, err = erl.CheckedUriFor(childNode1)
+    log.PanicIf(err)
+
+    _, err = erl.CheckedUriFor(childNode1)
+    if errors.Is(err, ErrEmbeddedBudgetExceeded) != true {
+        t.Fatalf("Site budget should be exceeded: [%v]", err)
+    }
+
+    // Rendering the page again starts its count over, which frees the site
+    // budget for other pages.
+    sb.embedded.resetPage(rootNode.PageId)
+
+    _, err = erl.CheckedUriFor(rootNode.Children[1])
+    log.PanicIf(err)
+
+    // Without a site, there is no budget.
+    _, err = erl.CheckedUriFor(nil)
+    log.PanicIf(err)
+}
+
+func TestEmbeddedResourceLocator_CheckedUriFor_Render(t *testing.T) {
+    sb := NewSiteBuilder("site title", NewTestDialect(), NewSiteContext(""))
+
+    sb.Context().SetEmbeddedBudget(EmbeddedBudget{
+        MaxPageSize: 10,
+    })
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("image/png", []byte{1, 2, 3, 4, 5, 6})
+    log.PanicIf(err)
+
+    rootNode := sb.Root()
+
+    err = rootNode.Builder().AddContentImage(NewImageWidget("alt text", erl, 0, 0))
+    log.PanicIf(err)
+
+    // Rendering the same page twice does not count its resources twice.
+    for i := 0; i < 2; i++ {
+        err = rootNode.Render()
+        log.PanicIf(err)
+    }
+
+    err = rootNode.Builder().AddContentImage(NewImageWidget("alt text", erl, 0, 0))
+    log.PanicIf(err)
+
+    if err := rootNode.Render(); err == nil {
+        t.Fatalf("Expected the page budget to be exceeded.")
+    }
+}
+
+func TestEmbeddedResourceLocator_CheckedUriFor_Publish(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    sb.Context().SetEmbeddedBudget(EmbeddedBudget{
+        MaxResourceSize: 2,
+        Policy:          PublishEmbeddedBudgetPolicy,
+    })
+
+    raw := []byte{1, 2, 3}
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("image/png", raw)
+    log.PanicIf(err)
+
+    uri, err := erl.CheckedUriFor(sb.Root())
+    log.PanicIf(err)
+
+    if strings.HasPrefix(uri, "assets/") == false || strings.HasSuffix(uri, ".png") == false {
+        t.Fatalf("Resource should be published: [%s]", uri)
+    } else if bytes.Equal(sb.assets.dataByPublished[uri], raw) != true {
+        t.Fatalf("Published data not correct.")
+    }
+}
+
+func TestEmbeddedResourceLocator_CheckedUriFor_Downscale(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    raw := getTestImage(400, 300, false)
+    limit := int64(len(raw) / 2)
+
+    sb.Context().SetEmbeddedBudget(EmbeddedBudget{
+        MaxResourceSize: limit,
+        Policy:          DownscaleEmbeddedBudgetPolicy,
+    })
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("image/png", raw)
+    log.PanicIf(err)
+
+    uri, err := erl.CheckedUriFor(sb.Root())
+    log.PanicIf(err)
+
+    prefix := "data:image/png;base64,"
+    if strings.HasPrefix(uri, prefix) == false {
+        t.Fatalf("Downscaled image should be embedded: [%s]", uri)
+    }
+
+    data, err := base64.StdEncoding.DecodeString(uri[len(prefix):])
+    log.PanicIf(err)
+
+    config, _, err := image.DecodeConfig(bytes.NewReader(data))
+    log.PanicIf(err)
+
+    if int64(len(data)) > limit {
+        t.Fatalf("Downscaled image too large: (%d) > (%d)", len(data), limit)
+    } else if config.Width >= 400 || config.Width*3 != config.Height*4 {
+        t.Fatalf("Downscaled image not correct: %v", config)
+    }
+
+    // Only images can be downscaled.
+    erl, err = NewEmbeddedResourceLocatorWithBytes("text/plain", make([]byte, limit+1))
+    log.PanicIf(err)
+
+    _, err = erl.CheckedUriFor(sb.Root())
+    if errors.Is(err, ErrEmbeddedResourceTooLarge) != true {
+        t.Fatalf("Expected the resource to be too large: [%v]", err)
+    }
+}
+
+func TestSiteBuilder_Validate_EmbeddedBudget(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    sb.Context().SetEmbeddedBudget(EmbeddedBudget{
+        MaxPageSize: 10,
+    })
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("image/png", []byte{1, 2, 3, 4, 5, 6})
+    log.PanicIf(err)
+
+    pb := sb.Root().Builder()
+
+    for i := 0; i < 2; i++ {
+        err = pb.AddContentImage(NewImageWidget("alt text", erl, 0, 0))
+        log.PanicIf(err)
+    }
+
+    err = sb.Validate()
+
+    ve, ok := err.(*ValidationError)
+    if ok != true {
+        t.Fatalf("Expected a ValidationError: [%v]", err)
+    } else if len(ve.Problems) != 1 {
+        t.Fatalf("Number of problems not correct: %s", ve)
+    }
+
+    vp := ve.Problems[0]
+    if vp.StatementIndex != 1 || errors.Is(vp, ErrEmbeddedBudgetExceeded) != true {
+        t.Fatalf("Problem not correct: %s", vp)
+    }
+
+    // Resources that would be published instead are not problems.
+    sb.Context().SetEmbeddedBudget(EmbeddedBudget{
+        MaxPageSize: 10,
+        Policy:      PublishEmbeddedBudgetPolicy,
+    })
+
+    if err := sb.Validate(); err != nil {
+        t.Fatalf("Expected no problems: %s", err)
+    }
+}
+
+func getEmbeddedBudgetTestSite(htmlOutputPath string, child2Images int) (sb *SiteBuilder) {
+    sb = NewSiteBuilder("site title", NewTestDialect(), NewSiteContext(htmlOutputPath))
+
+    sb.Context().SetEmbeddedBudget(EmbeddedBudget{
+        MaxSiteSize: 15,
+    })
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("image/png", []byte{1, 2, 3, 4, 5, 6})
+    log.PanicIf(err)
+
+    rootNode := sb.Root()
+
+    childNode1, err := rootNode.AddChildNode("child1", "Child1")
+    log.PanicIf(err)
+
+    err = childNode1.Builder().AddContentImage(NewImageWidget("alt text", erl, 0, 0))
+    log.PanicIf(err)
+
+    childNode2, err := rootNode.AddChildNode("child2", "Child2")
+    log.PanicIf(err)
+
+    for i := 0; i < child2Images; i++ {
+        err = childNode2.Builder().AddContentImage(NewImageWidget("alt text", erl, 0, 0))
+        log.PanicIf(err)
+    }
+
+    return sb
+}
+
+func TestSiteBuilder_WriteChangedToPath_EmbeddedBudget(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getEmbeddedBudgetTestSite(tempPath, 1)
+
+    _, err = sb.WriteChangedToPath(WriteOptions{})
+    log.PanicIf(err)
+
+    // The page that is not rewritten still counts against the site budget.
+    sb = getEmbeddedBudgetTestSite(tempPath, 2)
+
+    report, err := sb.WriteChangedToPath(WriteOptions{})
+
+    we, ok := err.(*WriteError)
+    if ok != true {
+        t.Fatalf("Expected a WriteError: [%v]", err)
+    } else if len(we.NodeErrors) != 1 || we.NodeErrors[0].PageId != "child2" || errors.Is(err, ErrEmbeddedBudgetExceeded) != true {
+        t.Fatalf("Node errors not correct: %s", we)
+    }
+
+    checkReport(t, report, "A=[] U=[child2] N=[child1 index] D=[]")
+}
+
+func TestEmbeddedResourceSize(t *testing.T) {
+    for i := 0; i < 6; i++ {
+        erl, err := NewEmbeddedResourceLocatorWithBytes("image/png", make([]byte, i))
+        log.PanicIf(err)
+
+        if i == 0 {
+            continue
+        }
+
+        size, err := embeddedResourceSize(erl)
+        log.PanicIf(err)
+
+        if size != int64(i) {
+            t.Fatalf("Size not correct: (%d) != (%d)", size, i)
+        }
+    }
+}
@@ -1,6 +1,8 @@
 package sitebuilder
 
 import (
+    "fmt"
+
     "github.com/dsoprea/go-logging"
 )
 
@@ -35,13 +37,18 @@ func (pb *PageBuilder) AddHeading(h HeadingWidget) (err error) {
     return nil
 }
 
+// AddContentImage adds an image. ErrInvalidImage is returned if a variant
+// width is not positive.
 func (pb *PageBuilder) AddContentImage(iw ImageWidget) (err error) {
     defer func() {
         if state := recover(); state != nil {
-            err = log.Wrap(state.(error))
+            err = recoveredError(state)
         }
     }()
 
+    err = iw.Validate()
+    log.PanicIf(err)
+
     metadata := map[string]interface{}{
         "image": iw,
     }
@@ -127,3 +134,221 @@ func (pb *PageBuilder) AddLink(lw LinkWidget) (err error) {
 
     return nil
 }
+
+// AddChildNavbar adds a navbar with links to all of the children of the page.
+// The children are determined when the page is rendered.
+func (pb *PageBuilder) AddChildNavbar(style NavbarStyle) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    metadata := map[string]interface{}{
+        "child_navbar": NewChildNavbarWidget(style),
+    }
+
+    ps := PageStatement{
+        Type:              ChildNavbar,
+        StatementMetadata: metadata,
+    }
+
+    pb.sn.Content.Add(ps)
+
+    return nil
+}
+
+// AddBreadcrumb adds the path from the root to the page, with each ancestor
+// linked.
+func (pb *PageBuilder) AddBreadcrumb(bw BreadcrumbWidget) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    metadata := map[string]interface{}{
+        "breadcrumb": bw,
+    }
+
+    ps := PageStatement{
+        Type:              Breadcrumb,
+        StatementMetadata: metadata,
+    }
+
+    pb.sn.Content.Add(ps)
+
+    return nil
+}
+
+// AddSequenceNavigation adds links to the previous and next pages and up to
+// the parent.
+func (pb *PageBuilder) AddSequenceNavigation(snw SequenceNavigationWidget) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    metadata := map[string]interface{}{
+        "sequence_navigation": snw,
+    }
+
+    ps := PageStatement{
+        Type:              SequenceNavigation,
+        StatementMetadata: metadata,
+    }
+
+    pb.sn.Content.Add(ps)
+
+    return nil
+}
+
+// AddParagraph adds a paragraph of prose.
+func (pb *PageBuilder) AddParagraph(pw ParagraphWidget) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    metadata := map[string]interface{}{
+        "paragraph": pw,
+    }
+
+    ps := PageStatement{
+        Type:              Paragraph,
+        StatementMetadata: metadata,
+    }
+
+    pb.sn.Content.Add(ps)
+
+    return nil
+}
+
+// AddTable adds a table. ErrInvalidTable is returned if the table has no
+// columns or if a row has more cells than there are columns.
+func (pb *PageBuilder) AddTable(tw TableWidget) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    err = tw.Validate()
+    log.PanicIf(err)
+
+    metadata := map[string]interface{}{
+        "table": tw,
+    }
+
+    ps := PageStatement{
+        Type:              Table,
+        StatementMetadata: metadata,
+    }
+
+    pb.sn.Content.Add(ps)
+
+    return nil
+}
+
+// AddCodeBlock adds a block of code. ErrInvalidCodeBlock is returned if a
+// highlighted line range is not valid.
+func (pb *PageBuilder) AddCodeBlock(cbw CodeBlockWidget) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    err = cbw.Validate()
+    log.PanicIf(err)
+
+    metadata := map[string]interface{}{
+        "code_block": cbw,
+    }
+
+    ps := PageStatement{
+        Type:              CodeBlock,
+        StatementMetadata: metadata,
+    }
+
+    pb.sn.Content.Add(ps)
+
+    return nil
+}
+
+// AddGallery adds a gallery of thumbnails. If the gallery has ImagePages set,
+// a child page is also added for each image, in the order in which the
+// images are shown, with the full-size image and links to the previous and
+// next images. ErrInvalidGallery is returned if the gallery is not valid.
+// Nothing is added if any of the image pages can not be.
+func (pb *PageBuilder) AddGallery(gw GalleryWidget) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    err = gw.Validate()
+    log.PanicIf(err)
+
+    if gw.ImagePages == true {
+        // Page-IDs must be unique even if there is more than one gallery.
+        galleryNumber := 1
+        for _, ps := range pb.sn.Content.Statements {
+            if ps.Type == Gallery {
+                galleryNumber++
+            }
+        }
+
+        order := gw.Order()
+
+        gw.ImagePageIds = make([]string, len(gw.Images))
+        pageTitles := make([]string, len(gw.Images))
+
+        // Check every page before adding any so that a failure does not leave
+        // some of them behind.
+        for n, i := range order {
+            pageId := fmt.Sprintf("%s-gallery%d-%d", pb.sn.PageId, galleryNumber, n+1)
+
+            if isValidPageId(pageId) == false {
+                log.Panicf("page-ID of image page has an invalid format: [%s]", pageId)
+            } else if _, found := pb.sn.sb.Node(pageId); found == true {
+                log.Panicf("page-ID of image page is already used: [%s]", pageId)
+            }
+
+            pageTitle := gw.Images[i].Caption
+            if pageTitle == "" {
+                pageTitle = fmt.Sprintf("%s (%d)", pb.sn.PageTitle, n+1)
+            }
+
+            gw.ImagePageIds[i] = pageId
+            pageTitles[i] = pageTitle
+        }
+
+        for _, i := range order {
+            imageNode, err := pb.sn.AddChildNode(gw.ImagePageIds[i], pageTitles[i])
+            log.PanicIf(err)
+
+            err = imageNode.Builder().AddContentImage(NewImageWidget(pageTitles[i], gw.Images[i].Locator, 0, 0))
+            log.PanicIf(err)
+
+            err = imageNode.Builder().AddSequenceNavigation(NewSequenceNavigationWidget(false))
+            log.PanicIf(err)
+        }
+    }
+
+    metadata := map[string]interface{}{
+        "gallery": gw,
+    }
+
+    ps := PageStatement{
+        Type:              Gallery,
+        StatementMetadata: metadata,
+    }
+
+    pb.sn.Content.Add(ps)
+
+    return nil
+}
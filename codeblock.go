@@ -0,0 +1,163 @@
+package sitebuilder
+
+import (
+    "errors"
+    "fmt"
+
+    "encoding/json"
+
+    "github.com/dsoprea/go-logging"
+)
+
+var (
+    // ErrInvalidCodeBlock indicates a code block with a highlighted line
+    // range that is not valid.
+    ErrInvalidCodeBlock = errors.New("code block not valid")
+)
+
+// LineRange is an inclusive range of line numbers. The first line is one.
+type LineRange struct {
+    Start int
+    End  int
+}
+
+func NewLineRange(start, end int) LineRange {
+    return LineRange{
+        Start: start,
+        End:   end,
+    }
+}
+
+func (lr LineRange) String() string {
+    if lr.Start == lr.End {
+        return fmt.Sprintf("%d", lr.Start)
+    }
+
+    return fmt.Sprintf("%d-%d", lr.Start, lr.End)
+}
+
+// CodeBlockWidget is a block of preformatted code that the dialects
+// highlight when the site is built.
+type CodeBlockWidget struct {
+    // Language is the name or alias of the language (e.g. "go" or "yaml").
+    // Code in an unknown language, or with no language, is not highlighted.
+    Language string
+
+    // Code is the code to show. It is ignored if there is a source.
+    Code string
+
+    // Source, if not nil, refers to a file whose content is shown rather
+    // than the code. It is read when the page is rendered.
+    Source ResourceLocator
+
+    // LineNumbers determines whether the lines are numbered.
+    LineNumbers bool
+
+    // HighlightedLines are the lines that are emphasized.
+    HighlightedLines []LineRange
+}
+
+func NewCodeBlockWidget(language, code string) CodeBlockWidget {
+    return CodeBlockWidget{
+        Language: language,
+        Code:     code,
+    }
+}
+
+// NewCodeBlockWidgetFromSource returns a code block that shows the content of
+// the given resource (e.g. a LocalResourceLocator).
+func NewCodeBlockWidgetFromSource(language string, source ResourceLocator) CodeBlockWidget {
+    return CodeBlockWidget{
+        Language: language,
+        Source:   source,
+    }
+}
+
+// Validate returns ErrInvalidCodeBlock if any highlighted range does not
+// start at a positive line or ends before it starts.
+func (cbw CodeBlockWidget) Validate() error {
+    for _, lr := range cbw.HighlightedLines {
+        if lr.Start < 1 || lr.End < lr.Start {
+            return fmt.Errorf("%w: line range not valid: (%d)-(%d)", ErrInvalidCodeBlock, lr.Start, lr.End)
+        }
+    }
+
+    return nil
+}
+
+// ReadCode returns the code to show, reading the source if there is one.
+// Errors wrap ErrResourceUnreadable.
+func (cbw CodeBlockWidget) ReadCode(from *SiteNode) (code string, err error) {
+    if cbw.Source == nil {
+        return cbw.Code, nil
+    }
+
+    data, err := ReadResource(cbw.Source, from)
+    if err != nil {
+        return "", err
+    }
+
+    return string(data), nil
+}
+
+// MarshalJSON stores the code block along with the type of its source.
+func (cbw CodeBlockWidget) MarshalJSON() (data []byte, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    source, err := marshalResourceLocator(cbw.Source)
+    log.PanicIf(err)
+
+    stored := struct {
+        Language         string
+        Code             string
+        Source           json.RawMessage
+        LineNumbers      bool
+        HighlightedLines []LineRange
+    }{
+        Language:         cbw.Language,
+        Code:             cbw.Code,
+        Source:           source,
+        LineNumbers:      cbw.LineNumbers,
+        HighlightedLines: cbw.HighlightedLines,
+    }
+
+    data, err = json.Marshal(stored)
+    log.PanicIf(err)
+
+    return data, nil
+}
+
+// UnmarshalJSON restores the code block and its source.
+func (cbw *CodeBlockWidget) UnmarshalJSON(data []byte) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    stored := struct {
+        Language         string
+        Code             string
+        Source           json.RawMessage
+        LineNumbers      bool
+        HighlightedLines []LineRange
+    }{}
+
+    err = json.Unmarshal(data, &stored)
+    log.PanicIf(err)
+
+    source, err := unmarshalResourceLocator(stored.Source)
+    log.PanicIf(err)
+
+    cbw.Language = stored.Language
+    cbw.Code = stored.Code
+    cbw.Source = source
+    cbw.LineNumbers = stored.LineNumbers
+    cbw.HighlightedLines = stored.HighlightedLines
+
+    return nil
+}
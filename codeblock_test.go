@@ -0,0 +1,89 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "errors"
+    "testing"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func TestCodeBlockWidget_Validate(t *testing.T) {
+    cbw := NewCodeBlockWidget("go", "package main\n")
+    cbw.HighlightedLines = []LineRange{NewLineRange(1, 1), NewLineRange(2, 4)}
+
+    err := cbw.Validate()
+    log.PanicIf(err)
+
+    for _, lr := range []LineRange{NewLineRange(0, 1), NewLineRange(3, 2)} {
+        cbw.HighlightedLines = []LineRange{lr}
+
+        if err := cbw.Validate(); errors.Is(err, ErrInvalidCodeBlock) != true {
+            t.Fatalf("Range should not be valid: %v", lr)
+        }
+    }
+}
+
+func TestCodeBlockWidget_ReadCode(t *testing.T) {
+    cbw := NewCodeBlockWidget("go", "package main\n")
+
+    code, err := cbw.ReadCode(nil)
+    log.PanicIf(err)
+
+    if code != "package main\n" {
+        t.Fatalf("Code not correct: [%s]", code)
+    }
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("text/plain", []byte("package other\n"))
+    log.PanicIf(err)
+
+    cbw = NewCodeBlockWidgetFromSource("go", erl)
+
+    code, err = cbw.ReadCode(nil)
+    log.PanicIf(err)
+
+    if code != "package other\n" {
+        t.Fatalf("Source code not correct: [%s]", code)
+    }
+}
+
+func TestCodeBlockWidget_RoundTrip(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    cbw := NewCodeBlockWidgetFromSource("yaml", NewLocalResourceLocator("/some/config.yaml"))
+    cbw.LineNumbers = true
+    cbw.HighlightedLines = []LineRange{NewLineRange(2, 3)}
+
+    err := sb.Root().Builder().AddCodeBlock(cbw)
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    err = sb.Save(b)
+    log.PanicIf(err)
+
+    restoredSb, err := LoadSiteBuilder(b, NewTestDialect(), NewSiteContext(""))
+    log.PanicIf(err)
+
+    restoredCbw := restoredSb.Root().Content.Statements[0].StatementMetadata["code_block"].(CodeBlockWidget)
+
+    if restoredCbw.Language != "yaml" || restoredCbw.LineNumbers != true {
+        t.Fatalf("Code block not restored: %v", restoredCbw)
+    } else if len(restoredCbw.HighlightedLines) != 1 || restoredCbw.HighlightedLines[0] != NewLineRange(2, 3) {
+        t.Fatalf("Highlighted lines not restored: %v", restoredCbw.HighlightedLines)
+    } else if lrl, ok := restoredCbw.Source.(*LocalResourceLocator); ok != true || lrl.LocalFilepath != "/some/config.yaml" {
+        t.Fatalf("Source not restored: %v", restoredCbw.Source)
+    }
+}
+
+func TestPageBuilder_AddCodeBlock_Invalid(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    cbw := NewCodeBlockWidget("go", "package main\n")
+    cbw.HighlightedLines = []LineRange{NewLineRange(0, 0)}
+
+    err := sb.Root().Builder().AddCodeBlock(cbw)
+    if errors.Is(err, ErrInvalidCodeBlock) != true {
+        t.Fatalf("Invalid code block should not be added: %v", err)
+    }
+}
@@ -8,15 +8,27 @@ type DialectPageBuilder interface {
     AddContentImage(altText string, locator ResourceLocator) (err error)
 
     // AddChildNavbar adds a navbar with links for all children.
-    AddChildNavbar() (err error)
+    AddChildNavbar(style NavbarStyle) (err error)
 }
 
 // Dialect defines high-level, dialect-specific translation operations.
+//
+// The builder owns the traversal of the tree. Each method is called exactly
+// once per node per render and must only render the node that it is given. It
+// must not recurse into the children.
 type Dialect interface {
-    // RenderIntermediate produces dialect-specific content that can be passed
-    // to RenderHtml.
+    // RenderIntermediate produces dialect-specific content for the one node
+    // that can be passed to RenderHtml.
     RenderIntermediate(sn *SiteNode) (err error)
 
-    // RenderHtml produces HTML from the dialect-specific content.
+    // RenderHtml produces HTML from the dialect-specific content of the one
+    // node.
     RenderHtml(sn *SiteNode) (err error)
 }
+
+// WidgetTypeSupporter is implemented by dialects that can report which widget
+// types they render. SiteBuilder.Validate uses it to find statements that the
+// dialect would fail on.
+type WidgetTypeSupporter interface {
+    SupportsWidgetType(wt WidgetType) bool
+}
@@ -83,14 +83,9 @@ func main() {
     err = childPb.AddContentImage(iw)
     log.PanicIf(err)
 
-    items := []sitebuilder.LinkWidget{
-        sitebuilder.NewLinkWidget("Child1", sitebuilder.NewSitePageLocalResourceLocator(sb, "child1")),
-        sitebuilder.NewLinkWidget("Child2", sitebuilder.NewSitePageLocalResourceLocator(sb, "child2")),
-    }
-
-    nw := sitebuilder.NewNavbarWidget(items)
+    // Add a navbar with links to all of the children of the root page.
 
-    err = rootPb.AddHorizontalNavbar(nw)
+    err = rootPb.AddChildNavbar(sitebuilder.HorizontalNavbarStyle)
     log.PanicIf(err)
 
     // Render and write.
@@ -0,0 +1,575 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path"
+    "regexp"
+    "sort"
+    "strings"
+    "time"
+
+    "encoding/json"
+    "encoding/xml"
+    "html/template"
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+const (
+    // feedSourcePageMetadataKey is the PageMetadata key that marks a node as
+    // a feed source.
+    feedSourcePageMetadataKey = "feed_source"
+
+    // feedItemPageMetadataKey is the PageMetadata key of the information
+    // that a node contributes to its parent's feed.
+    feedItemPageMetadataKey = "feed_item"
+
+    jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+    atomNamespace   = "http://www.w3.org/2005/Atom"
+)
+
+// FeedFormat is one of the feed formats that are written for a feed source.
+type FeedFormat int
+
+const (
+    FeedFormatRss FeedFormat = iota
+    FeedFormatAtom
+    FeedFormatJson
+)
+
+var (
+    feedFormats = []FeedFormat{FeedFormatRss, FeedFormatAtom, FeedFormatJson}
+
+    // headEndRe finds the end of the document head.
+    headEndRe = regexp.MustCompile(`(?i)</head\s*>`)
+)
+
+// MimeType returns the media type that the feed is advertised with.
+func (ff FeedFormat) MimeType() string {
+    switch ff {
+    case FeedFormatRss:
+        return "application/rss+xml"
+    case FeedFormatAtom:
+        return "application/atom+xml"
+    case FeedFormatJson:
+        return "application/feed+json"
+    }
+
+    log.Panicf("feed format not valid: (%d)", ff)
+    return ""
+}
+
+// extension returns what replaces the extension of the feed source's output
+// path to produce the feed's path.
+func (ff FeedFormat) extension() string {
+    switch ff {
+    case FeedFormatRss:
+        return ".rss.xml"
+    case FeedFormatAtom:
+        return ".atom.xml"
+    case FeedFormatJson:
+        return ".feed.json"
+    }
+
+    log.Panicf("feed format not valid: (%d)", ff)
+    return ""
+}
+
+// FeedSource marks a node as a feed source. A feed of its children is written
+// in every format.
+type FeedSource struct {
+    // Title is the title of the feed. If empty, the page title is used.
+    Title string
+
+    Description string
+
+    // Author is the author of the feed. If empty, the site title is used.
+    Author string
+}
+
+// FeedItem is the information that a child of a feed source contributes to
+// the feed beyond its title and URL.
+type FeedItem struct {
+    Published time.Time
+    Summary   string
+}
+
+// SetFeedSource marks this node as a feed source. Feeds use absolute URLs, so
+// ErrBaseUrlNotSet is returned if the base URL has not been set yet (see
+// SiteContext.SetBaseUrl).
+func (sn *SiteNode) SetFeedSource(fs FeedSource) (err error) {
+    if sn.sb.siteContext.baseUrl == "" {
+        return fmt.Errorf("%w: feed source [%s] needs absolute URLs", ErrBaseUrlNotSet, sn.PageId)
+    }
+
+    sn.Content.PageMetadata[feedSourcePageMetadataKey] = fs
+
+    return nil
+}
+
+// FeedSource returns the feed information for this node if it is a feed
+// source.
+func (sn *SiteNode) FeedSource() (fs FeedSource, found bool) {
+    fs, found = sn.Content.PageMetadata[feedSourcePageMetadataKey].(FeedSource)
+    return fs, found
+}
+
+// SetFeedItem sets the publish date and summary that this node is listed with
+// in its parent's feed.
+func (sn *SiteNode) SetFeedItem(fi FeedItem) {
+    sn.Content.PageMetadata[feedItemPageMetadataKey] = fi
+}
+
+// FeedItem returns the feed information for this node, if any was set.
+func (sn *SiteNode) FeedItem() (fi FeedItem, found bool) {
+    fi, found = sn.Content.PageMetadata[feedItemPageMetadataKey].(FeedItem)
+    return fi, found
+}
+
+// FeedPath returns the path, relative to the HTML output path, that the given
+// feed of this page is written to. It is next to the page's own output path
+// (e.g. "reports.rss.xml" for "reports.html", or "reports/index.rss.xml" for
+// "reports/index.html").
+func (sn *SiteNode) FeedPath(ff FeedFormat) string {
+    outputPath := sn.OutputPath()
+    return strings.TrimSuffix(outputPath, path.Ext(outputPath)) + ff.extension()
+}
+
+// feedPaths returns the paths of all of the feeds of this page if it is a
+// feed source.
+func (sn *SiteNode) feedPaths() (paths []string) {
+    if _, found := sn.FeedSource(); found == false {
+        return nil
+    }
+
+    paths = make([]string, len(feedFormats))
+    for i, ff := range feedFormats {
+        paths[i] = sn.FeedPath(ff)
+    }
+
+    return paths
+}
+
+// resolvedFeed is a feed with its defaults applied and its items sorted.
+type resolvedFeed struct {
+    Title       string
+    Description string
+    Author      string
+    Url         string
+    Updated     time.Time
+    Items       []resolvedFeedItem
+}
+
+type resolvedFeedItem struct {
+    Title     string
+    Url       string
+    Published time.Time
+    Summary   string
+}
+
+// resolveFeed collects the feed of the given feed source. The items are
+// ordered newest first. Items without a publish date follow, in tree order.
+func (sb *SiteBuilder) resolveFeed(sn *SiteNode, fs FeedSource) (rf resolvedFeed, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    sc := sb.siteContext
+
+    url, err := sc.AbsoluteUrl(sn.UriFrom(nil))
+    log.PanicIf(err)
+
+    rf = resolvedFeed{
+        Title:       fs.Title,
+        Description: fs.Description,
+        Author:      fs.Author,
+        Url:         url,
+        Items:       make([]resolvedFeedItem, len(sn.Children)),
+    }
+
+    if rf.Title == "" {
+        rf.Title = sn.PageTitle
+    }
+
+    if rf.Author == "" {
+        rf.Author = sb.rootNode.PageTitle
+    }
+
+    for i, childNode := range sn.Children {
+        url, err := sc.AbsoluteUrl(childNode.UriFrom(nil))
+        log.PanicIf(err)
+
+        fi, _ := childNode.FeedItem()
+
+        rf.Items[i] = resolvedFeedItem{
+            Title:     childNode.PageTitle,
+            Url:       url,
+            Published: fi.Published,
+            Summary:   fi.Summary,
+        }
+
+        if fi.Published.After(rf.Updated) == true {
+            rf.Updated = fi.Published
+        }
+    }
+
+    sort.SliceStable(rf.Items, func(i, j int) bool {
+        if rf.Items[j].Published.IsZero() == true {
+            return rf.Items[i].Published.IsZero() == false
+        }
+
+        return rf.Items[i].Published.After(rf.Items[j].Published)
+    })
+
+    return rf, nil
+}
+
+type rssDocument struct {
+    XMLName   xml.Name   `xml:"rss"`
+    Version   string     `xml:"version,attr"`
+    AtomXmlns string     `xml:"xmlns:atom,attr"`
+    Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+    Title         string    `xml:"title"`
+    Link          string    `xml:"link"`
+    Description   string    `xml:"description"`
+    AtomLink      atomLink  `xml:"atom:link"`
+    LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+    Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+    Title       string  `xml:"title"`
+    Link        string  `xml:"link"`
+    Guid        rssGuid `xml:"guid"`
+    PubDate     string  `xml:"pubDate,omitempty"`
+    Description string  `xml:"description,omitempty"`
+}
+
+type rssGuid struct {
+    IsPermaLink bool   `xml:"isPermaLink,attr"`
+    Value       string `xml:",chardata"`
+}
+
+type atomDocument struct {
+    XMLName  xml.Name    `xml:"feed"`
+    Xmlns    string      `xml:"xmlns,attr"`
+    Title    string      `xml:"title"`
+    Subtitle string      `xml:"subtitle,omitempty"`
+    Id       string      `xml:"id"`
+    Updated  string      `xml:"updated"`
+    Links    []atomLink  `xml:"link"`
+    Author   atomAuthor  `xml:"author"`
+    Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+    Href string `xml:"href,attr"`
+    Rel  string `xml:"rel,attr,omitempty"`
+    Type string `xml:"type,attr,omitempty"`
+}
+
+type atomAuthor struct {
+    Name string `xml:"name"`
+}
+
+type atomEntry struct {
+    Title     string   `xml:"title"`
+    Id        string   `xml:"id"`
+    Link      atomLink `xml:"link"`
+    Updated   string   `xml:"updated"`
+    Published string   `xml:"published,omitempty"`
+    Summary   string   `xml:"summary,omitempty"`
+}
+
+type jsonFeedDocument struct {
+    Version     string           `json:"version"`
+    Title       string           `json:"title"`
+    HomePageUrl string           `json:"home_page_url"`
+    FeedUrl     string           `json:"feed_url"`
+    Description string           `json:"description,omitempty"`
+    Authors     []jsonFeedAuthor `json:"authors"`
+    Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+    Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+    Id            string `json:"id"`
+    Url           string `json:"url"`
+    Title         string `json:"title"`
+    Summary       string `json:"summary,omitempty"`
+    DatePublished string `json:"date_published,omitempty"`
+}
+
+// rssDocument builds the RSS 2.0 form of the feed.
+func (rf resolvedFeed) rssDocument(selfUrl string) rssDocument {
+    description := rf.Description
+    if description == "" {
+        // The description is required.
+        description = rf.Title
+    }
+
+    channel := rssChannel{
+        Title:       rf.Title,
+        Link:        rf.Url,
+        Description: description,
+        AtomLink: atomLink{
+            Href: selfUrl,
+            Rel:  "self",
+            Type: FeedFormatRss.MimeType(),
+        },
+        Items: make([]rssItem, len(rf.Items)),
+    }
+
+    if rf.Updated.IsZero() == false {
+        channel.LastBuildDate = rf.Updated.Format(time.RFC1123Z)
+    }
+
+    for i, item := range rf.Items {
+        ri := rssItem{
+            Title: item.Title,
+            Link:  item.Url,
+            Guid: rssGuid{
+                IsPermaLink: true,
+                Value:       item.Url,
+            },
+            Description: item.Summary,
+        }
+
+        if item.Published.IsZero() == false {
+            ri.PubDate = item.Published.Format(time.RFC1123Z)
+        }
+
+        channel.Items[i] = ri
+    }
+
+    return rssDocument{
+        Version:   "2.0",
+        AtomXmlns: atomNamespace,
+        Channel:   channel,
+    }
+}
+
+// atomDocument builds the Atom form of the feed. Atom requires an update
+// time for the feed and every entry; undated entries use the feed's, and a
+// feed without any dates uses the Unix epoch so that the output is stable.
+func (rf resolvedFeed) atomDocument(selfUrl string) atomDocument {
+    updated := rf.Updated
+    if updated.IsZero() == true {
+        updated = time.Unix(0, 0).UTC()
+    }
+
+    ad := atomDocument{
+        Xmlns:    atomNamespace,
+        Title:    rf.Title,
+        Subtitle: rf.Description,
+        Id:       rf.Url,
+        Updated:  updated.Format(time.RFC3339),
+        Links: []atomLink{
+            {Href: rf.Url, Rel: "alternate"},
+            {Href: selfUrl, Rel: "self", Type: FeedFormatAtom.MimeType()},
+        },
+        Author: atomAuthor{
+            Name: rf.Author,
+        },
+        Entries: make([]atomEntry, len(rf.Items)),
+    }
+
+    for i, item := range rf.Items {
+        ae := atomEntry{
+            Title: item.Title,
+            Id:    item.Url,
+            Link: atomLink{
+                Href: item.Url,
+                Rel:  "alternate",
+            },
+            Updated: ad.Updated,
+            Summary: item.Summary,
+        }
+
+        if item.Published.IsZero() == false {
+            ae.Updated = item.Published.Format(time.RFC3339)
+            ae.Published = ae.Updated
+        }
+
+        ad.Entries[i] = ae
+    }
+
+    return ad
+}
+
+// jsonFeedDocument builds the JSON Feed form of the feed.
+func (rf resolvedFeed) jsonFeedDocument(selfUrl string) jsonFeedDocument {
+    jfd := jsonFeedDocument{
+        Version:     jsonFeedVersion,
+        Title:       rf.Title,
+        HomePageUrl: rf.Url,
+        FeedUrl:     selfUrl,
+        Description: rf.Description,
+        Authors: []jsonFeedAuthor{
+            {Name: rf.Author},
+        },
+        Items: make([]jsonFeedItem, len(rf.Items)),
+    }
+
+    for i, item := range rf.Items {
+        jfi := jsonFeedItem{
+            Id:      item.Url,
+            Url:     item.Url,
+            Title:   item.Title,
+            Summary: item.Summary,
+        }
+
+        if item.Published.IsZero() == false {
+            jfi.DatePublished = item.Published.Format(time.RFC3339)
+        }
+
+        jfd.Items[i] = jfi
+    }
+
+    return jfd
+}
+
+// writeFeeds writes every format of the feed of every feed source.
+func (sb *SiteBuilder) writeFeeds() (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    sc := sb.siteContext
+
+    for _, sn := range sb.rootNode.flatten() {
+        fs, found := sn.FeedSource()
+        if found == false {
+            continue
+        }
+
+        rf, err := sb.resolveFeed(sn, fs)
+        log.PanicIf(err)
+
+        for _, ff := range feedFormats {
+            feedPath := sn.FeedPath(ff)
+
+            selfUrl, err := sc.AbsoluteUrl(relativeUriFrom(nil, feedPath))
+            log.PanicIf(err)
+
+            filepath := path.Join(sc.htmlOutputPath, feedPath)
+
+            err = os.MkdirAll(path.Dir(filepath), 0755)
+            log.PanicIf(err)
+
+            switch ff {
+            case FeedFormatRss:
+                err = writeXmlFile(filepath, rf.rssDocument(selfUrl))
+                log.PanicIf(err)
+            case FeedFormatAtom:
+                err = writeXmlFile(filepath, rf.atomDocument(selfUrl))
+                log.PanicIf(err)
+            case FeedFormatJson:
+                b := new(bytes.Buffer)
+
+                // The feed is not embedded in HTML, so there is no need to
+                // escape for it.
+                e := json.NewEncoder(b)
+                e.SetEscapeHTML(false)
+                e.SetIndent("", "  ")
+
+                err := e.Encode(rf.jsonFeedDocument(selfUrl))
+                log.PanicIf(err)
+
+                err = ioutil.WriteFile(filepath, b.Bytes(), 0644)
+                log.PanicIf(err)
+            }
+        }
+    }
+
+    return nil
+}
+
+// feedLinks returns the alternate links for the feeds of this node and its
+// ancestors, root first.
+func (sn *SiteNode) feedLinks() (links []string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    sc := sn.sb.siteContext
+
+    links = make([]string, 0)
+
+    nodes := append(sn.Ancestors(), sn)
+    for _, current := range nodes {
+        fs, found := current.FeedSource()
+        if found == false {
+            continue
+        }
+
+        title := fs.Title
+        if title == "" {
+            title = current.PageTitle
+        }
+
+        for _, ff := range feedFormats {
+            url, err := sc.AbsoluteUrl(relativeUriFrom(nil, current.FeedPath(ff)))
+            log.PanicIf(err)
+
+            link := fmt.Sprintf(`<link rel="alternate" type="%s" title="%s" href="%s">`, ff.MimeType(), template.HTMLEscapeString(title), template.HTMLEscapeString(url))
+            links = append(links, link)
+        }
+    }
+
+    return links, nil
+}
+
+// injectFeedLinks adds the alternate links for the feeds that apply to this
+// node to the end of the head of its final output. Output without a head
+// (e.g. a fragment that is not put into a layout) is left alone.
+func (sn *SiteNode) injectFeedLinks() (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    finalOutput := sn.FinalOutput()
+
+    location := headEndRe.FindIndex(finalOutput)
+    if location == nil {
+        return nil
+    }
+
+    links, err := sn.feedLinks()
+    log.PanicIf(err)
+
+    if len(links) == 0 {
+        return nil
+    }
+
+    block := []byte(strings.Join(links, "\n") + "\n")
+
+    b := new(bytes.Buffer)
+
+    i := location[0]
+
+    b.Write(finalOutput[:i])
+    b.Write(block)
+    b.Write(finalOutput[i:])
+
+    sn.SetFinalOutput(b.Bytes())
+
+    return nil
+}
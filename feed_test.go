@@ -0,0 +1,334 @@
+package sitebuilder
+
+import (
+    "errors"
+    "os"
+    "strings"
+    "testing"
+    "time"
+
+    "html/template"
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func getFeedTestSite(htmlOutputPath string) (sb *SiteBuilder) {
+    sc := NewSiteContext(htmlOutputPath)
+    sc.SetBaseUrl("https://example.com")
+
+    sb = NewSiteBuilder("site title", NewTestDialect(), sc)
+
+    reportsNode, err := sb.Root().AddChildNode("reports", "Reports")
+    log.PanicIf(err)
+
+    err = reportsNode.SetFeedSource(FeedSource{Description: "Monthly reports"})
+    log.PanicIf(err)
+
+    report1, err := reportsNode.AddChildNode("report1", "Report 1")
+    log.PanicIf(err)
+
+    report1.SetFeedItem(FeedItem{
+        Published: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+        Summary:   "The first report",
+    })
+
+    _, err = reportsNode.AddChildNode("draft", "Draft & Notes")
+    log.PanicIf(err)
+
+    report2, err := reportsNode.AddChildNode("report2", "Report 2")
+    log.PanicIf(err)
+
+    report2.SetFeedItem(FeedItem{
+        Published: time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC),
+        Summary:   "The second report",
+    })
+
+    return sb
+}
+
+func TestSiteBuilder_WriteToPath_Feeds(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getFeedTestSite(tempPath)
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    actual := readOutputFile(t, tempPath, "reports.rss.xml")
+
+    expected := `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom">
+  <channel>
+    <title>Reports</title>
+    <link>https://example.com/reports.html</link>
+    <description>Monthly reports</description>
+    <atom:link href="https://example.com/reports.rss.xml" rel="self" type="application/rss+xml"></atom:link>
+    <lastBuildDate>Sat, 01 Feb 2020 00:00:00 +0000</lastBuildDate>
+    <item>
+      <title>Report 2</title>
+      <link>https://example.com/report2.html</link>
+      <guid isPermaLink="true">https://example.com/report2.html</guid>
+      <pubDate>Sat, 01 Feb 2020 00:00:00 +0000</pubDate>
+      <description>The second report</description>
+    </item>
+    <item>
+      <title>Report 1</title>
+      <link>https://example.com/report1.html</link>
+      <guid isPermaLink="true">https://example.com/report1.html</guid>
+      <pubDate>Wed, 01 Jan 2020 00:00:00 +0000</pubDate>
+      <description>The first report</description>
+    </item>
+    <item>
+      <title>Draft &amp; Notes</title>
+      <link>https://example.com/draft.html</link>
+      <guid isPermaLink="true">https://example.com/draft.html</guid>
+    </item>
+  </channel>
+</rss>
+`
+
+    if actual != expected {
+        t.Fatalf("RSS feed not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+
+    actual = readOutputFile(t, tempPath, "reports.atom.xml")
+
+    expected = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Reports</title>
+  <subtitle>Monthly reports</subtitle>
+  <id>https://example.com/reports.html</id>
+  <updated>2020-02-01T00:00:00Z</updated>
+  <link href="https://example.com/reports.html" rel="alternate"></link>
+  <link href="https://example.com/reports.atom.xml" rel="self" type="application/atom+xml"></link>
+  <author>
+    <name>site title</name>
+  </author>
+  <entry>
+    <title>Report 2</title>
+    <id>https://example.com/report2.html</id>
+    <link href="https://example.com/report2.html" rel="alternate"></link>
+    <updated>2020-02-01T00:00:00Z</updated>
+    <published>2020-02-01T00:00:00Z</published>
+    <summary>The second report</summary>
+  </entry>
+  <entry>
+    <title>Report 1</title>
+    <id>https://example.com/report1.html</id>
+    <link href="https://example.com/report1.html" rel="alternate"></link>
+    <updated>2020-01-01T00:00:00Z</updated>
+    <published>2020-01-01T00:00:00Z</published>
+    <summary>The first report</summary>
+  </entry>
+  <entry>
+    <title>Draft &amp; Notes</title>
+    <id>https://example.com/draft.html</id>
+    <link href="https://example.com/draft.html" rel="alternate"></link>
+    <updated>2020-02-01T00:00:00Z</updated>
+  </entry>
+</feed>
+`
+
+    if actual != expected {
+        t.Fatalf("Atom feed not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+
+    actual = readOutputFile(t, tempPath, "reports.feed.json")
+
+    expected = `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Reports",
+  "home_page_url": "https://example.com/reports.html",
+  "feed_url": "https://example.com/reports.feed.json",
+  "description": "Monthly reports",
+  "authors": [
+    {
+      "name": "site title"
+    }
+  ],
+  "items": [
+    {
+      "id": "https://example.com/report2.html",
+      "url": "https://example.com/report2.html",
+      "title": "Report 2",
+      "summary": "The second report",
+      "date_published": "2020-02-01T00:00:00Z"
+    },
+    {
+      "id": "https://example.com/report1.html",
+      "url": "https://example.com/report1.html",
+      "title": "Report 1",
+      "summary": "The first report",
+      "date_published": "2020-01-01T00:00:00Z"
+    },
+    {
+      "id": "https://example.com/draft.html",
+      "url": "https://example.com/draft.html",
+      "title": "Draft & Notes"
+    }
+  ]
+}
+`
+
+    if actual != expected {
+        t.Fatalf("JSON feed not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+}
+
+func TestSiteNode_injectFeedLinks_NoHead(t *testing.T) {
+    sb := getFeedTestSite("")
+
+    err := sb.Root().Render()
+    log.PanicIf(err)
+
+    reportsNode := sb.Root().Children[0]
+
+    // Output without a head is left alone.
+
+    actual := string(reportsNode.Children[0].FinalOutput())
+    expected := `<header>Report 1</header>
+<footer>Report 1</footer>
+`
+
+    if actual != expected {
+        t.Fatalf("Output without a head should not be changed:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+}
+
+func TestSiteNode_injectFeedLinks_Head(t *testing.T) {
+    sb := getFeedTestSite("")
+
+    layout := template.Must(template.New("layout").Parse("<html><head><title>{{.Node.PageTitle}}</title></HEAD ><body>{{.Body}}</body></html>"))
+    sb.Context().SetLayout(layout)
+
+    err := sb.Root().Render()
+    log.PanicIf(err)
+
+    reportsNode := sb.Root().Children[0]
+
+    actual := string(reportsNode.FinalOutput())
+
+    expected := `<html><head><title>Reports</title><link rel="alternate" type="application/rss+xml" title="Reports" href="https://example.com/reports.rss.xml">
+<link rel="alternate" type="application/atom+xml" title="Reports" href="https://example.com/reports.atom.xml">
+<link rel="alternate" type="application/feed+json" title="Reports" href="https://example.com/reports.feed.json">
+</HEAD ><body><header>Reports</header>
+<footer>Reports</footer>
+</body></html>`
+
+    if actual != expected {
+        t.Fatalf("Links not injected correctly:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+
+    // Pages outside of the feed's section do not get links.
+
+    if actual := string(sb.Root().FinalOutput()); strings.Contains(actual, "<link") == true {
+        t.Fatalf("Links should not be injected outside the section:\n%s", actual)
+    }
+}
+
+func TestSiteNode_SetFeedSource_WithoutBaseUrl(t *testing.T) {
+    sc := NewSiteContext("")
+    sb := NewSiteBuilder("site title", NewTestDialect(), sc)
+
+    err := sb.Root().SetFeedSource(FeedSource{Description: "Site news"})
+    if err == nil {
+        t.Fatalf("Expected error for a feed without a base URL.")
+    } else if errors.Is(err, ErrBaseUrlNotSet) != true {
+        t.Fatalf("Error not correct: %v", err)
+    }
+}
+
+func TestSiteNode_Render_FeedWithoutBaseUrl(t *testing.T) {
+    sb := getFeedTestSite("")
+    sb.Context().SetBaseUrl("")
+
+    layout := template.Must(template.New("layout").Parse("<html><head></head><body>{{.Body}}</body></html>"))
+    sb.Context().SetLayout(layout)
+
+    // The base URL was removed after the feed source was set.
+
+    err := sb.Validate()
+
+    ve, ok := err.(*ValidationError)
+    if ok != true {
+        t.Fatalf("Expected a ValidationError: [%v]", err)
+    } else if len(ve.Problems) != 1 || ve.Problems[0].PageId != "reports" || ve.Problems[0].StatementIndex != -1 || errors.Is(ve.Problems[0], ErrBaseUrlNotSet) != true {
+        t.Fatalf("Problems not correct: %s", ve)
+    }
+
+    err = sb.Root().Render()
+    if err == nil {
+        t.Fatalf("Expected error for a feed without a base URL.")
+    } else if errors.Is(err, ErrBaseUrlNotSet) != true {
+        t.Fatalf("Error not correct: %v", err)
+    }
+}
+
+func TestSiteNode_FeedPath(t *testing.T) {
+    sb := getFeedTestSite("")
+
+    reportsNode := sb.Root().Children[0]
+
+    if p := reportsNode.FeedPath(FeedFormatRss); p != "reports.rss.xml" {
+        t.Fatalf("Feed path not correct: [%s]", p)
+    }
+
+    sb.Context().SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+
+    if p := reportsNode.FeedPath(FeedFormatJson); p != "reports/index.feed.json" {
+        t.Fatalf("Feed path not correct with the directory strategy: [%s]", p)
+    }
+}
+
+func TestSiteBuilder_WriteToPath_FeedsDirectoryOutputPathStrategy(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getFeedTestSite(tempPath)
+
+    paths := map[string]string{
+        "report1": "reports/my report.html",
+    }
+
+    sb.Context().SetOutputPathStrategy(NewMappedOutputPathStrategy(paths, NewDirectoryOutputPathStrategy()))
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    actual := readOutputFile(t, tempPath, "reports/index.rss.xml")
+
+    // The links are escaped once.
+    for _, expected := range []string{"<link>https://example.com/reports/index.html</link>", "<link>https://example.com/reports/my%20report.html</link>", `href="https://example.com/reports/index.rss.xml"`} {
+        if strings.Contains(actual, expected) == false {
+            t.Fatalf("Feed does not contain [%s]:\n%s", expected, actual)
+        }
+    }
+}
+
+func TestOutputPathProblems_Feeds(t *testing.T) {
+    sb := getFeedTestSite("")
+
+    // A page may not be written over a feed.
+    paths := map[string]string{
+        "report1": "reports.atom.xml",
+    }
+
+    sb.Context().SetOutputPathStrategy(NewMappedOutputPathStrategy(paths, NewFlatOutputPathStrategy("%s.html")))
+
+    problems := outputPathProblems(sb.rootNode.flatten(), defaultAssetsPath)
+
+    if len(problems) != 1 || problems[0].PageId != "report1" || errors.Is(problems[0], ErrInvalidOutputPath) != true {
+        t.Fatalf("Problems not correct: %v", problems)
+    }
+
+    err := sb.Validate()
+    if _, ok := err.(*ValidationError); ok != true {
+        t.Fatalf("Expected a ValidationError: [%v]", err)
+    }
+}
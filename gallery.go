@@ -0,0 +1,333 @@
+package sitebuilder
+
+import (
+    "errors"
+    "fmt"
+    "path"
+    "sort"
+    "strings"
+
+    "encoding/json"
+
+    "github.com/dsoprea/go-logging"
+)
+
+const (
+    // DefaultGalleryColumns is the number of columns of a new gallery.
+    DefaultGalleryColumns = 3
+
+    // DefaultThumbnailSize is the length, in pixels, of the longest side of
+    // the thumbnails of a new gallery.
+    DefaultThumbnailSize = 200
+)
+
+var (
+    // ErrInvalidGallery indicates a gallery without images or with a column
+    // count or thumbnail size that is not positive.
+    ErrInvalidGallery = errors.New("gallery not valid")
+)
+
+// GallerySort determines the order of the images of a gallery.
+type GallerySort int
+
+const (
+    // GivenGallerySort keeps the images in the order that they were given.
+    GivenGallerySort GallerySort = iota
+
+    // CaptionGallerySort orders the images by their captions.
+    CaptionGallerySort
+
+    // FilenameGallerySort orders the images by the filenames of their
+    // locators.
+    FilenameGallerySort
+)
+
+// ThumbnailStorage determines how the thumbnails of a gallery are stored.
+type ThumbnailStorage int
+
+const (
+    // EmbeddedThumbnailStorage embeds the thumbnails in the page as "data:"
+    // URIs. They count against the embedded-resource budget of the site.
+    EmbeddedThumbnailStorage ThumbnailStorage = iota
+
+    // PublishedThumbnailStorage writes the thumbnails into the assets
+    // subdirectory of the output path.
+    PublishedThumbnailStorage
+)
+
+// GalleryImage is one image of a gallery. The caption is also used as the alt
+// text.
+type GalleryImage struct {
+    Caption string
+    Locator ResourceLocator
+}
+
+func NewGalleryImage(caption string, locator ResourceLocator) GalleryImage {
+    return GalleryImage{
+        Caption: caption,
+        Locator: locator,
+    }
+}
+
+// MarshalJSON stores the image along with the type of its locator.
+func (gi GalleryImage) MarshalJSON() (data []byte, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    locator, err := marshalResourceLocator(gi.Locator)
+    log.PanicIf(err)
+
+    stored := struct {
+        Caption string
+        Locator json.RawMessage
+    }{
+        Caption: gi.Caption,
+        Locator: locator,
+    }
+
+    data, err = json.Marshal(stored)
+    log.PanicIf(err)
+
+    return data, nil
+}
+
+// UnmarshalJSON restores the image and its locator.
+func (gi *GalleryImage) UnmarshalJSON(data []byte) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    stored := struct {
+        Caption string
+        Locator json.RawMessage
+    }{}
+
+    err = json.Unmarshal(data, &stored)
+    log.PanicIf(err)
+
+    locator, err := unmarshalResourceLocator(stored.Locator)
+    log.PanicIf(err)
+
+    gi.Caption = stored.Caption
+    gi.Locator = locator
+
+    return nil
+}
+
+// GalleryWidget is a grid of thumbnails that are generated from the images
+// when the page is rendered. Each thumbnail links to its full-size image or,
+// if there are image pages, to the page of that image.
+type GalleryWidget struct {
+    // Caption, if not empty, describes the gallery.
+    Caption string
+
+    Images  []GalleryImage
+    Sort    GallerySort
+    Columns int
+
+    // ThumbnailSize is the length, in pixels, of the longest side of the
+    // thumbnails. Images that are already smaller are not enlarged.
+    ThumbnailSize int
+
+    ThumbnailStorage ThumbnailStorage
+
+    // ImagePages, if true, has AddGallery add a child page for each image.
+    ImagePages bool
+
+    // ImagePageIds are the page-IDs of the image pages, in the same order as
+    // Images. They are assigned by AddGallery.
+    ImagePageIds []string
+}
+
+func NewGalleryWidget(images ...GalleryImage) GalleryWidget {
+    return GalleryWidget{
+        Images:        images,
+        Columns:       DefaultGalleryColumns,
+        ThumbnailSize: DefaultThumbnailSize,
+    }
+}
+
+// Validate returns ErrInvalidGallery if the gallery has no images or if the
+// column count or the thumbnail size is not positive.
+func (gw GalleryWidget) Validate() error {
+    if len(gw.Images) == 0 {
+        return fmt.Errorf("%w: no images", ErrInvalidGallery)
+    } else if gw.Columns < 1 {
+        return fmt.Errorf("%w: column count not valid: (%d)", ErrInvalidGallery, gw.Columns)
+    } else if gw.ThumbnailSize < 1 {
+        return fmt.Errorf("%w: thumbnail size not valid: (%d)", ErrInvalidGallery, gw.ThumbnailSize)
+    } else if len(gw.ImagePageIds) != 0 && len(gw.ImagePageIds) != len(gw.Images) {
+        return fmt.Errorf("%w: (%d) image page(s) for (%d) image(s)", ErrInvalidGallery, len(gw.ImagePageIds), len(gw.Images))
+    }
+
+    return nil
+}
+
+// Order returns the indices of the images in the order in which they are
+// shown.
+func (gw GalleryWidget) Order() (indices []int) {
+    indices = make([]int, len(gw.Images))
+    for i := range indices {
+        indices[i] = i
+    }
+
+    var key func(gi GalleryImage) string
+
+    switch gw.Sort {
+    case CaptionGallerySort:
+        key = func(gi GalleryImage) string {
+            return strings.ToLower(gi.Caption)
+        }
+    case FilenameGallerySort:
+        key = func(gi GalleryImage) string {
+            return strings.ToLower(locatorFilename(gi.Locator))
+        }
+    default:
+        return indices
+    }
+
+    sort.SliceStable(indices, func(i, j int) bool {
+        return key(gw.Images[indices[i]]) < key(gw.Images[indices[j]])
+    })
+
+    return indices
+}
+
+// locatorFilename returns the filename of the file that the locator refers to
+// or its URI if it does not refer to a local file.
+func locatorFilename(rl ResourceLocator) string {
+    switch t := rl.(type) {
+    case *LocalResourceLocator:
+        return path.Base(t.LocalFilepath)
+    case *PublishedResourceLocator:
+        return path.Base(t.LocalFilepath)
+    case *EmbeddedResourceLocator:
+        if t.Filepath != "" {
+            return path.Base(t.Filepath)
+        }
+    }
+
+    uri, _ := CheckedResolveUri(rl, nil)
+    return path.Base(uri)
+}
+
+// GalleryThumbnail is a thumbnail of a gallery as resolved for one page.
+type GalleryThumbnail struct {
+    Caption string
+
+    // ThumbnailUri refers to the thumbnail and TargetUri to what it links to.
+    ThumbnailUri string
+    TargetUri    string
+
+    // TargetLocator is the locator that TargetUri was resolved from.
+    TargetLocator ResourceLocator
+
+    Width, Height int
+}
+
+// Thumbnails reads and decodes every image, in order, and returns its
+// thumbnail for use in the given page. Errors reading an image wrap
+// ErrResourceUnreadable and errors decoding it wrap ErrImageUndecodable.
+func (gw GalleryWidget) Thumbnails(sn *SiteNode) (thumbnails []GalleryThumbnail, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    err = gw.Validate()
+    log.PanicIf(err)
+
+    if (gw.ThumbnailStorage == PublishedThumbnailStorage || len(gw.ImagePageIds) > 0) && (sn == nil || sn.sb == nil) {
+        log.Panicf("published thumbnails and image pages need a page of a site")
+    }
+
+    indices := gw.Order()
+    thumbnails = make([]GalleryThumbnail, len(indices))
+
+    for i, index := range indices {
+        gi := gw.Images[index]
+
+        raw, err := ReadResource(gi.Locator, sn)
+        log.PanicIf(err)
+
+        data, mimeType, extension, width, height, err := makeThumbnail(raw, gw.ThumbnailSize)
+        log.PanicIf(err)
+
+        thumbnailUri := ""
+        if gw.ThumbnailStorage == PublishedThumbnailStorage {
+            publishedPath, err := sn.sb.assets.publishData(sn.sb.Context(), data, extension)
+            log.PanicIf(err)
+
+            thumbnailUri = relativeUriFrom(sn, publishedPath)
+        } else {
+            thumbnailUri, err = embedData(sn, mimeType, data, extension)
+            log.PanicIf(err)
+        }
+
+        var target ResourceLocator = gi.Locator
+        if len(gw.ImagePageIds) > 0 {
+            target = NewSitePageLocalResourceLocator(sn.sb, gw.ImagePageIds[index])
+        }
+
+        targetUri, err := CheckedResolveUri(target, sn)
+        log.PanicIf(err)
+
+        thumbnails[i] = GalleryThumbnail{
+            Caption:       gi.Caption,
+            ThumbnailUri:  thumbnailUri,
+            TargetUri:     targetUri,
+            TargetLocator: target,
+            Width:         width,
+            Height:        height,
+        }
+    }
+
+    return thumbnails, nil
+}
+
+// makeThumbnail scales the image down so that its longest side is no longer
+// than `size`.
+func makeThumbnail(raw []byte, size int) (data []byte, mimeType, extension string, width, height int, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    original, err := decodeImage(raw)
+    if err != nil {
+        return nil, "", "", 0, 0, err
+    }
+
+    bounds := original.Bounds()
+
+    width = bounds.Dx()
+    height = bounds.Dy()
+
+    if width > size || height > size {
+        if width >= height {
+            height = height * size / width
+            width = size
+        } else {
+            width = width * size / height
+            height = size
+        }
+
+        if width < 1 {
+            width = 1
+        } else if height < 1 {
+            height = 1
+        }
+    }
+
+    data, mimeType, extension, err = encodeScaledImage(original, width, height)
+    log.PanicIf(err)
+
+    return data, mimeType, extension, width, height, nil
+}
@@ -0,0 +1,324 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "errors"
+    "image"
+    "os"
+    "path"
+    "reflect"
+    "strings"
+    "testing"
+
+    "encoding/base64"
+    "image/color"
+    "image/png"
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// getTestImage returns a PNG image of the given size. An opaque image has no
+// alpha channel.
+func getTestImage(width, height int, opaque bool) []byte {
+    var img image.Image
+
+    if opaque == true {
+        gray := image.NewGray(image.Rect(0, 0, width, height))
+        for x := 0; x < width; x++ {
+            gray.SetGray(x, 0, color.Gray{Y: 255})
+        }
+
+        img = gray
+    } else {
+        img = image.NewNRGBA(image.Rect(0, 0, width, height))
+    }
+
+    b := new(bytes.Buffer)
+
+    err := png.Encode(b, img)
+    log.PanicIf(err)
+
+    return b.Bytes()
+}
+
+func getTestGalleryImage(caption string, width, height int, opaque bool) GalleryImage {
+    erl, err := NewEmbeddedResourceLocatorWithBytes("image/png", getTestImage(width, height, opaque))
+    log.PanicIf(err)
+
+    return NewGalleryImage(caption, erl)
+}
+
+func TestGalleryWidget_Validate(t *testing.T) {
+    gw := NewGalleryWidget()
+
+    if err := gw.Validate(); errors.Is(err, ErrInvalidGallery) != true {
+        t.Fatalf("Gallery without images should not be valid: %v", err)
+    }
+
+    gw = NewGalleryWidget(NewGalleryImage("a", NewLocalResourceLocator("/a.png")))
+
+    err := gw.Validate()
+    log.PanicIf(err)
+
+    gw.Columns = 0
+
+    if err := gw.Validate(); errors.Is(err, ErrInvalidGallery) != true {
+        t.Fatalf("Gallery without columns should not be valid: %v", err)
+    }
+}
+
+func TestGalleryWidget_Order(t *testing.T) {
+    gw := NewGalleryWidget(
+        NewGalleryImage("b", NewLocalResourceLocator("/images/3.png")),
+        NewGalleryImage("C", NewLocalResourceLocator("/images/1.png")),
+        NewGalleryImage("a", NewLocalResourceLocator("/images/2.png")),
+    )
+
+    if order := gw.Order(); reflect.DeepEqual(order, []int{0, 1, 2}) != true {
+        t.Fatalf("Given order not correct: %v", order)
+    }
+
+    gw.Sort = CaptionGallerySort
+
+    if order := gw.Order(); reflect.DeepEqual(order, []int{2, 0, 1}) != true {
+        t.Fatalf("Caption order not correct: %v", order)
+    }
+
+    gw.Sort = FilenameGallerySort
+
+    if order := gw.Order(); reflect.DeepEqual(order, []int{1, 2, 0}) != true {
+        t.Fatalf("Filename order not correct: %v", order)
+    }
+}
+
+func TestMakeThumbnail(t *testing.T) {
+    data, mimeType, extension, width, height, err := makeThumbnail(getTestImage(400, 100, true), 200)
+    log.PanicIf(err)
+
+    if mimeType != "image/jpeg" || extension != ".jpg" {
+        t.Fatalf("Opaque image should be a JPEG: [%s] [%s]", mimeType, extension)
+    } else if width != 200 || height != 50 {
+        t.Fatalf("Size not correct: (%d) x (%d)", width, height)
+    }
+
+    thumbnail, format, err := image.Decode(bytes.NewReader(data))
+    log.PanicIf(err)
+
+    if format != "jpeg" || thumbnail.Bounds().Dx() != 200 || thumbnail.Bounds().Dy() != 50 {
+        t.Fatalf("Thumbnail not correct: [%s] %v", format, thumbnail.Bounds())
+    }
+
+    // Transparent images are kept as PNGs and small images are not enlarged.
+    _, mimeType, _, width, height, err = makeThumbnail(getTestImage(10, 30, false), 200)
+    log.PanicIf(err)
+
+    if mimeType != "image/png" {
+        t.Fatalf("Transparent image should be a PNG: [%s]", mimeType)
+    } else if width != 10 || height != 30 {
+        t.Fatalf("Small image should not be resized: (%d) x (%d)", width, height)
+    }
+
+    _, _, _, _, _, err = makeThumbnail([]byte("not an image"), 200)
+    if errors.Is(err, ErrImageUndecodable) != true {
+        t.Fatalf("Expected undecodable image: [%v]", err)
+    }
+}
+
+func TestGalleryWidget_Thumbnails(t *testing.T) {
+    gw := NewGalleryWidget(
+        getTestGalleryImage("wide", 400, 100, true),
+        getTestGalleryImage("tall", 100, 400, false),
+    )
+
+    thumbnails, err := gw.Thumbnails(nil)
+    log.PanicIf(err)
+
+    if len(thumbnails) != 2 {
+        t.Fatalf("Thumbnail count not correct: (%d)", len(thumbnails))
+    }
+
+    gt := thumbnails[1]
+    if gt.Caption != "tall" || gt.Width != 50 || gt.Height != 200 {
+        t.Fatalf("Thumbnail not correct: %v", gt)
+    } else if gt.TargetUri != gw.Images[1].Locator.Uri() {
+        t.Fatalf("Thumbnail should link to the image.")
+    }
+
+    prefix := "data:image/png;base64,"
+    if strings.HasPrefix(gt.ThumbnailUri, prefix) == false {
+        t.Fatalf("Thumbnail not embedded: [%s]", gt.ThumbnailUri)
+    }
+
+    data, err := base64.StdEncoding.DecodeString(gt.ThumbnailUri[len(prefix):])
+    log.PanicIf(err)
+
+    config, _, err := image.DecodeConfig(bytes.NewReader(data))
+    log.PanicIf(err)
+
+    if config.Width != 50 || config.Height != 200 {
+        t.Fatalf("Embedded thumbnail not correct: %v", config)
+    }
+}
+
+func TestGalleryWidget_Thumbnails_Undecodable(t *testing.T) {
+    erl, err := NewEmbeddedResourceLocatorWithBytes("image/png", []byte("not an image"))
+    log.PanicIf(err)
+
+    gw := NewGalleryWidget(NewGalleryImage("broken", erl))
+
+    _, err = gw.Thumbnails(nil)
+    if errors.Is(err, ErrImageUndecodable) != true {
+        t.Fatalf("Expected undecodable image: [%v]", err)
+    }
+}
+
+func TestGalleryWidget_Thumbnails_Published(t *testing.T) {
+    outputPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(outputPath)
+
+    sb := getLayoutTestSite(outputPath)
+    sb.Context().SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+
+    childNode, found := sb.Node("child1")
+    if found != true {
+        t.Fatalf("Child not found.")
+    }
+
+    gw := NewGalleryWidget(getTestGalleryImage("wide", 400, 100, true))
+    gw.ThumbnailStorage = PublishedThumbnailStorage
+
+    thumbnails, err := gw.Thumbnails(childNode)
+    log.PanicIf(err)
+
+    uri := thumbnails[0].ThumbnailUri
+    if strings.HasPrefix(uri, "../assets/") == false || strings.HasSuffix(uri, ".jpg") == false {
+        t.Fatalf("Thumbnail URI not correct: [%s]", uri)
+    }
+
+    err = sb.assets.writeToPath(sb.Context())
+    log.PanicIf(err)
+
+    f, err := os.Open(path.Join(outputPath, strings.TrimPrefix(uri, "../")))
+    log.PanicIf(err)
+
+    defer f.Close()
+
+    config, _, err := image.DecodeConfig(f)
+    log.PanicIf(err)
+
+    if config.Width != 200 || config.Height != 50 {
+        t.Fatalf("Published thumbnail not correct: %v", config)
+    }
+}
+
+func TestPageBuilder_AddGallery_ImagePages(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    childNode, _ := sb.Node("child2")
+
+    gw := NewGalleryWidget(
+        getTestGalleryImage("b", 10, 10, true),
+        getTestGalleryImage("a", 10, 10, true),
+    )
+
+    gw.Sort = CaptionGallerySort
+    gw.ImagePages = true
+
+    err := childNode.Builder().AddGallery(gw)
+    log.PanicIf(err)
+
+    // The pages are in the order in which the images are shown.
+    if len(childNode.Children) != 2 || childNode.Children[0].PageId != "child2-gallery1-1" || childNode.Children[0].PageTitle != "a" {
+        t.Fatalf("Image pages not correct: %v", childNode.Children)
+    }
+
+    imageNode := childNode.Children[1]
+    if imageNode.PageTitle != "b" || len(imageNode.Content.Statements) != 2 {
+        t.Fatalf("Image page not correct: %v", imageNode)
+    } else if imageNode.Content.Statements[0].StatementMetadata["image"].(ImageWidget).Locator != gw.Images[0].Locator {
+        t.Fatalf("Image page should show the image.")
+    } else if imageNode.Content.Statements[1].Type != SequenceNavigation {
+        t.Fatalf("Image page should link to the other images.")
+    }
+
+    storedGw := childNode.Content.Statements[0].StatementMetadata["gallery"].(GalleryWidget)
+    if reflect.DeepEqual(storedGw.ImagePageIds, []string{"child2-gallery1-2", "child2-gallery1-1"}) != true {
+        t.Fatalf("Image page-IDs not correct: %v", storedGw.ImagePageIds)
+    }
+
+    thumbnails, err := storedGw.Thumbnails(childNode)
+    log.PanicIf(err)
+
+    if thumbnails[0].Caption != "a" || thumbnails[0].TargetUri != "child2-gallery1-1.html" {
+        t.Fatalf("Thumbnail should link to the image page: %v", thumbnails[0])
+    }
+
+    // A second gallery gets its own pages.
+    err = childNode.Builder().AddGallery(gw)
+    log.PanicIf(err)
+
+    if len(childNode.Children) != 4 || childNode.Children[2].PageId != "child2-gallery2-1" {
+        t.Fatalf("Second gallery pages not correct: %v", childNode.Children)
+    }
+}
+
+func TestPageBuilder_AddGallery_ImagePagesConflict(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    childNode, _ := sb.Node("child2")
+
+    // The page-ID of the second image page is already used.
+    _, err := sb.Root().AddChildNode("child2-gallery1-2", "Other")
+    log.PanicIf(err)
+
+    gw := NewGalleryWidget(
+        getTestGalleryImage("a", 10, 10, true),
+        getTestGalleryImage("b", 10, 10, true),
+    )
+
+    gw.ImagePages = true
+
+    err = childNode.Builder().AddGallery(gw)
+    if err == nil {
+        t.Fatalf("Expected error for a page-ID that is already used.")
+    }
+
+    // Nothing is left behind.
+    if len(childNode.Children) != 0 || len(childNode.Content.Statements) != 0 {
+        t.Fatalf("Failed gallery should not add anything: %v", childNode.Children)
+    } else if _, found := sb.Node("child2-gallery1-1"); found == true {
+        t.Fatalf("Image page of failed gallery should not be indexed.")
+    }
+}
+
+func TestGalleryWidget_RoundTrip(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    gw := NewGalleryWidget(NewGalleryImage("a", NewLocalResourceLocator("/some/image.png")))
+    gw.Caption = "Gallery"
+    gw.Columns = 4
+    gw.Sort = FilenameGallerySort
+    gw.ThumbnailStorage = PublishedThumbnailStorage
+
+    err := sb.Root().Builder().AddGallery(gw)
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    err = sb.Save(b)
+    log.PanicIf(err)
+
+    restoredSb, err := LoadSiteBuilder(b, NewTestDialect(), NewSiteContext(""))
+    log.PanicIf(err)
+
+    restoredGw := restoredSb.Root().Content.Statements[0].StatementMetadata["gallery"].(GalleryWidget)
+
+    if restoredGw.Caption != "Gallery" || restoredGw.Columns != 4 || restoredGw.Sort != FilenameGallerySort || restoredGw.ThumbnailStorage != PublishedThumbnailStorage {
+        t.Fatalf("Gallery not restored: %v", restoredGw)
+    } else if lrl, ok := restoredGw.Images[0].Locator.(*LocalResourceLocator); ok != true || lrl.LocalFilepath != "/some/image.png" {
+        t.Fatalf("Image not restored: %v", restoredGw.Images[0])
+    }
+}
@@ -0,0 +1,81 @@
+// Package highlight renders code blocks as HTML with syntax highlighting. The
+// highlighting is done when the site is built and the styles are inline, so
+// the pages need neither a stylesheet nor any script. Every dialect that
+// produces HTML uses it so that code blocks look the same everywhere.
+package highlight
+
+import (
+    "fmt"
+    "io"
+    "strings"
+
+    "github.com/alecthomas/chroma"
+    "github.com/alecthomas/chroma/formatters/html"
+    "github.com/alecthomas/chroma/lexers"
+    "github.com/alecthomas/chroma/styles"
+    "github.com/dsoprea/go-logging"
+
+    "github.com/dsoprea/go-static-site-builder"
+)
+
+const (
+    // StyleName is the name of the Chroma style that is used.
+    StyleName = "github"
+
+    // tabWidth is the number of spaces that a tab is shown as.
+    tabWidth = 4
+)
+
+// WriteHtml writes the code as a highlighted `pre` block within a `div` of
+// class "code-block". The language, line numbers, and highlighted lines are
+// taken from the widget. The code is passed separately since it may have been
+// read from the widget's source. Code in an unknown language is written
+// without highlighting.
+func WriteHtml(w io.Writer, cbw sitebuilder.CodeBlockWidget, code string) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    lexer := lexers.Get(cbw.Language)
+    if lexer == nil {
+        lexer = lexers.Fallback
+    }
+
+    lexer = chroma.Coalesce(lexer)
+
+    style := styles.Get(StyleName)
+    if style == nil {
+        style = styles.Fallback
+    }
+
+    ranges := make([][2]int, len(cbw.HighlightedLines))
+    for i, lr := range cbw.HighlightedLines {
+        ranges[i] = [2]int{lr.Start, lr.End}
+    }
+
+    formatter := html.New(
+        html.WithClasses(false),
+        html.TabWidth(tabWidth),
+        html.WithLineNumbers(cbw.LineNumbers),
+        html.HighlightLines(ranges),
+    )
+
+    // Windows and old Mac line endings would otherwise be shown.
+    code = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(code)
+
+    iterator, err := lexer.Tokenise(nil, code)
+    log.PanicIf(err)
+
+    _, err = fmt.Fprintf(w, `<div class="code-block">`)
+    log.PanicIf(err)
+
+    err = formatter.Format(w, style, iterator)
+    log.PanicIf(err)
+
+    _, err = fmt.Fprintf(w, "</div>\n")
+    log.PanicIf(err)
+
+    return nil
+}
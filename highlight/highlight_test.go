@@ -0,0 +1,68 @@
+package highlight
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/dsoprea/go-static-site-builder"
+)
+
+func TestWriteHtml(t *testing.T) {
+    cbw := sitebuilder.NewCodeBlockWidget("go", "package main\r\n\r\nvar x = \"<b>\"\r\n")
+
+    b := new(bytes.Buffer)
+
+    err := WriteHtml(b, cbw, cbw.Code)
+    log.PanicIf(err)
+
+    actual := b.String()
+
+    if strings.HasPrefix(actual, `<div class="code-block"><pre `) == false || strings.HasSuffix(actual, "</pre></div>\n") == false {
+        t.Fatalf("Block not correct: [%s]", actual)
+    } else if strings.Contains(actual, `<span style="color:#000;font-weight:bold">package</span>`) == false {
+        t.Fatalf("Keyword not highlighted: [%s]", actual)
+    } else if strings.Contains(actual, "&#34;&lt;b&gt;&#34;") == false {
+        t.Fatalf("Code not escaped: [%s]", actual)
+    } else if strings.Contains(actual, "\r") == true {
+        t.Fatalf("Carriage returns not removed: [%q]", actual)
+    } else if strings.Contains(actual, "user-select:none") == true {
+        t.Fatalf("Lines should not be numbered: [%s]", actual)
+    }
+}
+
+func TestWriteHtml_LineNumbers(t *testing.T) {
+    cbw := sitebuilder.NewCodeBlockWidget("go", "package main\n\nvar x = 1\n")
+    cbw.LineNumbers = true
+    cbw.HighlightedLines = []sitebuilder.LineRange{sitebuilder.NewLineRange(3, 3)}
+
+    b := new(bytes.Buffer)
+
+    err := WriteHtml(b, cbw, cbw.Code)
+    log.PanicIf(err)
+
+    actual := b.String()
+
+    if strings.Count(actual, "user-select:none") != 3 {
+        t.Fatalf("Lines not numbered: [%s]", actual)
+    } else if strings.Count(actual, "background-color:#e5e5e5") != 1 {
+        t.Fatalf("Line not highlighted: [%s]", actual)
+    }
+}
+
+func TestWriteHtml_UnknownLanguage(t *testing.T) {
+    cbw := sitebuilder.NewCodeBlockWidget("not-a-language", "package main\n")
+
+    b := new(bytes.Buffer)
+
+    err := WriteHtml(b, cbw, cbw.Code)
+    log.PanicIf(err)
+
+    actual := b.String()
+
+    if strings.Contains(actual, "font-weight") == true || strings.Contains(actual, "package main") == false {
+        t.Fatalf("Code should not be highlighted: [%s]", actual)
+    }
+}
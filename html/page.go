@@ -0,0 +1,235 @@
+package htmldialect
+
+import (
+    "bytes"
+    "io"
+
+    "html/template"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/dsoprea/go-static-site-builder"
+)
+
+const (
+    documentTemplateText = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<main>
+{{.Body}}</main>
+</body>
+</html>
+`
+)
+
+var (
+    documentTemplate *template.Template
+
+    // supportedWidgetTypes are the widget types that renderStatement handles.
+    supportedWidgetTypes = map[sitebuilder.WidgetType]struct{}{
+        sitebuilder.Heading:            {},
+        sitebuilder.ContentImage:       {},
+        sitebuilder.HorizontalNavbar:   {},
+        sitebuilder.VerticalNavbar:     {},
+        sitebuilder.Link:               {},
+        sitebuilder.ChildNavbar:        {},
+        sitebuilder.Breadcrumb:         {},
+        sitebuilder.SequenceNavigation: {},
+        sitebuilder.Paragraph:          {},
+        sitebuilder.Table:              {},
+        sitebuilder.CodeBlock:          {},
+        sitebuilder.Gallery:            {},
+    }
+)
+
+// HtmlDialect produces HTML5 directly from the page statements rather than
+// going through an intermediate markup language. The intermediate output is
+// the body fragment and the final output is the complete document.
+type HtmlDialect struct {
+}
+
+func NewHtmlDialect() (hd *HtmlDialect) {
+    return &HtmlDialect{}
+}
+
+// SupportsWidgetType returns whether statements of the given type can be
+// rendered.
+func (hd *HtmlDialect) SupportsWidgetType(wt sitebuilder.WidgetType) bool {
+    _, found := supportedWidgetTypes[wt]
+    return found
+}
+
+// RenderIntermediate produces the body content of the page.
+func (hd *HtmlDialect) RenderIntermediate(sn *sitebuilder.SiteNode) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    b := new(bytes.Buffer)
+
+    h := sitebuilder.NewHeadingWidget(1, sn.PageTitle)
+
+    err = HeadingToHtml(h, b)
+    log.PanicIf(err)
+
+    for i, ps := range sn.Content.Statements {
+        err := hd.renderStatement(sn, b, ps)
+        if err != nil {
+            log.Panic(sitebuilder.NewStatementError(sn.PageId, i, err))
+        }
+    }
+
+    intermediateOutput := b.Bytes()
+    sn.SetIntermediateOutput(intermediateOutput)
+
+    return nil
+}
+
+// renderStatement renders one statement of the given node. The node is needed
+// by widgets that are derived from the node's position in the tree.
+func (hd *HtmlDialect) renderStatement(sn *sitebuilder.SiteNode, w io.Writer, ps sitebuilder.PageStatement) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    switch ps.Type {
+    case sitebuilder.Heading:
+        h := ps.StatementMetadata["heading"].(sitebuilder.HeadingWidget)
+
+        err = HeadingToHtml(h, w)
+        log.PanicIf(err)
+
+    case sitebuilder.ContentImage:
+        iw := ps.StatementMetadata["image"].(sitebuilder.ImageWidget)
+
+        err = ImageWidgetToHtmlFrom(sn, iw, w)
+        log.PanicIf(err)
+
+    case sitebuilder.HorizontalNavbar:
+        nw := ps.StatementMetadata["horizontal_navbar"].(sitebuilder.NavbarWidget)
+
+        err := NavbarToHtmlFrom(sn, nw.Items, "horizontal-navbar", w)
+        log.PanicIf(err)
+
+    case sitebuilder.VerticalNavbar:
+        nw := ps.StatementMetadata["vertical_navbar"].(sitebuilder.NavbarWidget)
+
+        err := NavbarToHtmlFrom(sn, nw.Items, "vertical-navbar", w)
+        log.PanicIf(err)
+
+    case sitebuilder.Link:
+        lw := ps.StatementMetadata["link"].(sitebuilder.LinkWidget)
+
+        err = LinkWidgetToHtmlFrom(sn, lw, w)
+        log.PanicIf(err)
+
+        _, err = w.Write([]byte{'\n'})
+        log.PanicIf(err)
+
+    case sitebuilder.ChildNavbar:
+        cnw := ps.StatementMetadata["child_navbar"].(sitebuilder.ChildNavbarWidget)
+
+        items := cnw.Items(sn)
+        if len(items) == 0 {
+            break
+        }
+
+        class := "horizontal-navbar"
+        if cnw.Style == sitebuilder.VerticalNavbarStyle {
+            class = "vertical-navbar"
+        }
+
+        err := NavbarToHtmlFrom(sn, items, class, w)
+        log.PanicIf(err)
+
+    case sitebuilder.Breadcrumb:
+        bw := ps.StatementMetadata["breadcrumb"].(sitebuilder.BreadcrumbWidget)
+
+        items, currentText := bw.Items(sn)
+
+        err := BreadcrumbToHtml(sn, items, currentText, bw.Separator, w)
+        log.PanicIf(err)
+
+    case sitebuilder.SequenceNavigation:
+        snw := ps.StatementMetadata["sequence_navigation"].(sitebuilder.SequenceNavigationWidget)
+
+        sl := snw.Links(sn)
+
+        err := SequenceNavigationToHtml(sn, snw, sl, w)
+        log.PanicIf(err)
+
+    case sitebuilder.Paragraph:
+        pw := ps.StatementMetadata["paragraph"].(sitebuilder.ParagraphWidget)
+
+        err := ParagraphToHtml(sn, pw, w)
+        log.PanicIf(err)
+
+    case sitebuilder.Table:
+        tw := ps.StatementMetadata["table"].(sitebuilder.TableWidget)
+
+        err := TableToHtml(sn, tw, w)
+        log.PanicIf(err)
+
+    case sitebuilder.CodeBlock:
+        cbw := ps.StatementMetadata["code_block"].(sitebuilder.CodeBlockWidget)
+
+        err := CodeBlockToHtml(sn, cbw, w)
+        log.PanicIf(err)
+
+    case sitebuilder.Gallery:
+        gw := ps.StatementMetadata["gallery"].(sitebuilder.GalleryWidget)
+
+        err := GalleryToHtml(sn, gw, w)
+        log.PanicIf(err)
+
+    default:
+        log.Panicf("widget not valid")
+    }
+
+    return nil
+}
+
+// RenderHtml wraps the body content in a complete document. If a layout
+// applies to the page, the layout provides the document and we only produce
+// the body content.
+func (hd *HtmlDialect) RenderHtml(sn *sitebuilder.SiteNode) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    if sn.SiteBuilder().Context().LayoutFor(sn) != nil {
+        sn.SetFinalOutput(sn.IntermediateOutput())
+        return nil
+    }
+
+    context := struct {
+        Title string
+        Body  template.HTML
+    }{
+        Title: sn.PageTitle,
+        Body:  template.HTML(sn.IntermediateOutput()),
+    }
+
+    b := new(bytes.Buffer)
+
+    err = documentTemplate.Execute(b, context)
+    log.PanicIf(err)
+
+    sn.SetFinalOutput(b.Bytes())
+
+    return nil
+}
+
+func init() {
+    documentTemplate = template.Must(template.New("document").Parse(documentTemplateText))
+}
@@ -0,0 +1,349 @@
+package htmldialect
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path"
+    "testing"
+
+    "html/template"
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/dsoprea/go-static-site-builder"
+)
+
+func TestHtmlDialect_RenderIntermediate(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    hd := NewHtmlDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site title", hd, sc)
+
+    rootNode := sb.Root()
+    pb := rootNode.Builder()
+
+    lrl := sitebuilder.NewLocalResourceLocator("/some/image/path")
+    iw := sitebuilder.NewImageWidget("image alt text", lrl, 0, 0)
+
+    err := pb.AddContentImage(iw)
+    log.PanicIf(err)
+
+    lw := sitebuilder.NewLinkWidget("Child1", sitebuilder.NewSitePageLocalResourceLocator(sb, "child1"))
+
+    err = pb.AddLink(lw)
+    log.PanicIf(err)
+
+    _, err = rootNode.AddChildNode("child1", "Child Page 1")
+    log.PanicIf(err)
+
+    err = hd.RenderIntermediate(rootNode)
+    log.PanicIf(err)
+
+    actual := string(rootNode.IntermediateOutput())
+
+    expected := `<h1>site title</h1>
+<figure class="content-image"><img src="file:///some/image/path" alt="image alt text" /></figure>
+<a href="child1.html">Child1</a>
+`
+
+    if actual != expected {
+        fmt.Printf("ACTUAL:\n=====\n%s=====\n", actual)
+        fmt.Printf("EXPECTED:\n=====\n%s=====\n", expected)
+
+        t.Fatalf("Unexpected output.")
+    }
+}
+
+func TestHtmlDialect_RenderHtml(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    hd := NewHtmlDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site <title>", hd, sc)
+    rootNode := sb.Root()
+
+    err := rootNode.Render()
+    log.PanicIf(err)
+
+    actual := string(rootNode.FinalOutput())
+
+    expected := `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>site &lt;title&gt;</title>
+</head>
+<body>
+<main>
+<h1>site &lt;title&gt;</h1>
+</main>
+</body>
+</html>
+`
+
+    if actual != expected {
+        fmt.Printf("ACTUAL:\n=====\n%s=====\n", actual)
+        fmt.Printf("EXPECTED:\n=====\n%s=====\n", expected)
+
+        t.Fatalf("Unexpected output.")
+    }
+}
+
+func TestHtmlDialect_renderStatement_VerticalNavbar(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    hd := NewHtmlDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site title", hd, sc)
+    rootNode := sitebuilder.NewSiteNode(sb, "node_id", "node title")
+
+    pb := rootNode.Builder()
+
+    items := []sitebuilder.LinkWidget{
+        sitebuilder.NewLinkWidget("Child1", sitebuilder.NewLocalResourceLocator("/some/path1")),
+        sitebuilder.NewLinkWidget("Child2", sitebuilder.NewLocalResourceLocator("/some/path2")),
+    }
+
+    nw := sitebuilder.NewNavbarWidget(items)
+
+    err := pb.AddVerticalNavbar(nw, "test heading")
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    for _, ps := range rootNode.Content.Statements {
+        err := hd.renderStatement(rootNode, b, ps)
+        log.PanicIf(err)
+    }
+
+    actual := b.String()
+    expected := `<h1>test heading</h1>
+<nav class="vertical-navbar">
+<ul>
+<li><a href="file:///some/path1">Child1</a></li>
+<li><a href="file:///some/path2">Child2</a></li>
+</ul>
+</nav>
+`
+
+    if actual != expected {
+        t.Fatalf("Vertical navbar not rendered correctly:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestHtmlDialect_renderStatement_HorizontalNavbar(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    hd := NewHtmlDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site title", hd, sc)
+    rootNode := sitebuilder.NewSiteNode(sb, "node_id", "node title")
+
+    pb := rootNode.Builder()
+
+    items := []sitebuilder.LinkWidget{
+        sitebuilder.NewLinkWidget("Child1", sitebuilder.NewLocalResourceLocator("/some/path1")),
+    }
+
+    nw := sitebuilder.NewNavbarWidget(items)
+
+    err := pb.AddHorizontalNavbar(nw)
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    err = hd.renderStatement(rootNode, b, rootNode.Content.Statements[0])
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := `<nav class="horizontal-navbar">
+<ul>
+<li><a href="file:///some/path1">Child1</a></li>
+</ul>
+</nav>
+`
+
+    if actual != expected {
+        t.Fatalf("Horizontal navbar not rendered correctly:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+// ExampleHtmlDialect_RenderHtml is a wholistic usage example. It is named in
+// such a way as to show up in the documentation.
+func ExampleHtmlDialect_RenderHtml() {
+    tempPath, err := ioutil.TempDir("", "")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sc := sitebuilder.NewSiteContext(tempPath)
+    hd := NewHtmlDialect()
+
+    sb := sitebuilder.NewSiteBuilder("Site Title", hd, sc)
+
+    rootNode := sb.Root()
+
+    // Create content.
+
+    rootPb := rootNode.Builder()
+
+    lrl := sitebuilder.NewLocalResourceLocator("/some/image/path")
+
+    iw := sitebuilder.NewImageWidget("image alt text 1", lrl, 0, 0)
+
+    err = rootPb.AddContentImage(iw)
+    log.PanicIf(err)
+
+    childNode1, err := rootNode.AddChildNode("child1", "Child Page 1")
+    log.PanicIf(err)
+
+    childPb := childNode1.Builder()
+
+    hw := sitebuilder.NewHeadingWidget(2, "Subheading")
+
+    err = childPb.AddHeading(hw)
+    log.PanicIf(err)
+
+    items := []sitebuilder.LinkWidget{
+        sitebuilder.NewLinkWidget("Child1", sitebuilder.NewSitePageLocalResourceLocator(sb, "child1")),
+    }
+
+    nw := sitebuilder.NewNavbarWidget(items)
+
+    err = rootPb.AddHorizontalNavbar(nw)
+    log.PanicIf(err)
+
+    // Render and write.
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    // Print.
+
+    files, err := ioutil.ReadDir(tempPath)
+    log.PanicIf(err)
+
+    for _, fi := range files {
+        filename := fi.Name()
+
+        fmt.Printf("%s\n", filename)
+        fmt.Printf("====================\n")
+        fmt.Printf("\n")
+
+        filepath := path.Join(tempPath, filename)
+        content, err := ioutil.ReadFile(filepath)
+        log.PanicIf(err)
+
+        _, err = os.Stdout.Write(content)
+        log.PanicIf(err)
+
+        fmt.Printf("\n")
+    }
+
+    // Output:
+    // child1.html
+    // ====================
+    //
+    // <!DOCTYPE html>
+    // <html>
+    // <head>
+    // <meta charset="utf-8">
+    // <title>Child Page 1</title>
+    // </head>
+    // <body>
+    // <main>
+    // <h1>Child Page 1</h1>
+    // <h2>Subheading</h2>
+    // </main>
+    // </body>
+    // </html>
+    //
+    // index.html
+    // ====================
+    //
+    // <!DOCTYPE html>
+    // <html>
+    // <head>
+    // <meta charset="utf-8">
+    // <title>Site Title</title>
+    // </head>
+    // <body>
+    // <main>
+    // <h1>Site Title</h1>
+    // <figure class="content-image"><img src="file:///some/image/path" alt="image alt text 1" /></figure>
+    // <nav class="horizontal-navbar">
+    // <ul>
+    // <li><a href="child1.html">Child1</a></li>
+    // </ul>
+    // </nav>
+    // </main>
+    // </body>
+    // </html>
+}
+
+func TestHtmlDialect_RenderHtml_WithLayout(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+
+    layout := template.Must(template.New("layout").Parse("<html><body>{{.Body}}</body></html>\n"))
+    sc.SetLayout(layout)
+
+    hd := NewHtmlDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site title", hd, sc)
+    rootNode := sb.Root()
+
+    err := rootNode.Render()
+    log.PanicIf(err)
+
+    actual := string(rootNode.FinalOutput())
+    expected := "<html><body><h1>site title</h1>\n</body></html>\n"
+
+    if actual != expected {
+        fmt.Printf("ACTUAL:\n=====\n%s=====\n", actual)
+        fmt.Printf("EXPECTED:\n=====\n%s=====\n", expected)
+
+        t.Fatalf("Unexpected output.")
+    }
+}
+
+func TestHtmlDialect_renderStatement_ChildNavbar(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    hd := NewHtmlDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site title", hd, sc)
+    rootNode := sb.Root()
+
+    err := rootNode.Builder().AddChildNavbar(sitebuilder.VerticalNavbarStyle)
+    log.PanicIf(err)
+
+    _, err = rootNode.AddChildNode("child1", "Child Page 1")
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    err = hd.renderStatement(rootNode, b, rootNode.Content.Statements[0])
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := `<nav class="vertical-navbar">
+<ul>
+<li><a href="child1.html">Child Page 1</a></li>
+</ul>
+</nav>
+`
+
+    if actual != expected {
+        t.Fatalf("Child navbar not rendered correctly:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestHtmlDialect_SupportsWidgetType(t *testing.T) {
+    d := NewHtmlDialect()
+
+    if d.SupportsWidgetType(sitebuilder.SequenceNavigation) != true {
+        t.Fatalf("Sequence navigation should be supported.")
+    } else if d.SupportsWidgetType(sitebuilder.WidgetType(0)) != false {
+        t.Fatalf("Unknown widget type should not be supported.")
+    }
+
+    var _ sitebuilder.WidgetTypeSupporter = d
+}
@@ -0,0 +1,560 @@
+package htmldialect
+
+import (
+    "fmt"
+    "io"
+    "path"
+
+    "html/template"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/dsoprea/go-static-site-builder"
+    "github.com/dsoprea/go-static-site-builder/highlight"
+)
+
+const (
+    widgetTemplatesText = `
+{{define "heading"}}{{.Open}}{{.Text}}{{.Close}}
+{{end}}
+
+{{define "image"}}<figure class="content-image"><img src="{{.Uri}}"{{if .Srcset}} srcset="{{.Srcset}}" sizes="{{.Sizes}}"{{end}} alt="{{.AltText}}"{{if .Width}} width="{{.Width}}"{{end}}{{if .Height}} height="{{.Height}}"{{end}}{{if .LazyLoading}} loading="lazy"{{end}} /></figure>
+{{end}}
+
+{{define "link"}}<a href="{{.Uri}}">{{.Text}}</a>{{end}}
+
+{{define "breadcrumb"}}<nav class="breadcrumb">{{range .Items}}{{template "link" .}}{{$.Separator}}{{end}}<span aria-current="page">{{.CurrentText}}</span></nav>
+{{end}}
+
+{{define "sequence_navigation"}}<nav class="sequence-navigation">{{range $i, $part := .Parts}}{{if $i}} | {{end}}<span class="{{$part.Rel}}">{{$part.Label}}: <a href="{{$part.Link.Uri}}" rel="{{$part.Rel}}">{{$part.Link.Text}}</a></span>{{end}}</nav>
+{{end}}
+
+{{define "paragraph"}}<p>{{range .Spans}}{{if .Link}}{{template "link" .Link}}{{else if eq .Tag "em"}}<em>{{.Text}}</em>{{else if eq .Tag "strong"}}<strong>{{.Text}}</strong>{{else if eq .Tag "code"}}<code>{{.Text}}</code>{{else}}{{.Text}}{{end}}{{end}}</p>
+{{end}}
+
+{{define "table"}}<table>{{if .Caption}}
+<caption>{{.Caption}}</caption>{{end}}
+<thead>
+<tr>{{range .Headers}}<th{{if .Align}} style="text-align: {{.Align}}"{{end}}>{{.Text}}</th>{{end}}</tr>
+</thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td{{if .Align}} style="text-align: {{.Align}}"{{end}}>{{if .Link}}{{template "link" .Link}}{{else if .Image}}<img src="{{.Image.Uri}}" alt="{{.Image.AltText}}" />{{else}}{{.Text}}{{end}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{end}}
+
+{{define "gallery"}}<figure class="gallery">{{if .Caption}}
+<figcaption>{{.Caption}}</figcaption>{{end}}
+<div class="gallery-grid" style="display: grid; grid-template-columns: repeat({{.Columns}}, 1fr); gap: 1em">
+{{range .Thumbnails}}<figure class="gallery-image"><a href="{{.TargetUri}}"><img src="{{.ThumbnailUri}}" alt="{{.Caption}}" width="{{.Width}}" height="{{.Height}}" /></a>{{if .Caption}}<figcaption>{{.Caption}}</figcaption>{{end}}</figure>
+{{end}}</div>
+</figure>
+{{end}}
+
+{{define "navbar"}}<nav class="{{.Class}}">
+<ul>
+{{range .Items}}<li>{{template "link" .}}</li>
+{{end}}</ul>
+</nav>
+{{end}}
+`
+)
+
+var (
+    widgetTemplates *template.Template
+)
+
+// isTrustedLocator returns whether the URIs of the locator are produced by
+// the site rather than given by the caller. Absolute local paths become
+// "file:" URIs and output-relative ones are made relative to the page, but
+// other local paths are used as given.
+func isTrustedLocator(rl sitebuilder.ResourceLocator) bool {
+    switch t := rl.(type) {
+    case *sitebuilder.SitePageLocalResourceLocator, *sitebuilder.EmbeddedResourceLocator, *sitebuilder.PublishedResourceLocator:
+        return true
+    case *sitebuilder.LocalResourceLocator:
+        return path.IsAbs(t.LocalFilepath) == true || t.OutputRelative == true
+    }
+
+    return false
+}
+
+// uriValue returns the URI for use in a template. The URIs of trusted
+// locators may legitimately be "file:" or "data:" URIs, which the template
+// would otherwise filter. Any other URI is left to the template to sanitize
+// (e.g. a "javascript:" URI).
+func uriValue(rl sitebuilder.ResourceLocator, uri string) interface{} {
+    if isTrustedLocator(rl) == true {
+        return template.URL(uri)
+    }
+
+    return uri
+}
+
+type linkContext struct {
+    Text string
+    Uri  interface{}
+}
+
+// newLinkContext resolves the link. It panics if the locator fails.
+func newLinkContext(sn *sitebuilder.SiteNode, lw sitebuilder.LinkWidget) linkContext {
+    uri, err := sitebuilder.CheckedResolveUri(lw.Locator, sn)
+    log.PanicIf(err)
+
+    return linkContext{
+        Text: lw.Text,
+        Uri:  uriValue(lw.Locator, uri),
+    }
+}
+
+// ImageWidgetToHtml renders a content image with a URI that is relative to the
+// root of the site. See ImageWidgetToHtmlFrom.
+func ImageWidgetToHtml(iw sitebuilder.ImageWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    err = ImageWidgetToHtmlFrom(nil, iw, w)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// ImageWidgetToHtmlFrom renders a content image as a figure. Dimensions that
+// were not given are read from the image and variants are offered via
+// `srcset`. The URI is relative to the page `sn`.
+func ImageWidgetToHtmlFrom(sn *sitebuilder.SiteNode, iw sitebuilder.ImageWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    ri, err := iw.Resolve(sn)
+    log.PanicIf(err)
+
+    // The variants are published by the site, but the srcset also lists the
+    // image itself.
+    var srcset interface{} = ri.Srcset
+    if isTrustedLocator(iw.Locator) == true {
+        srcset = template.Srcset(ri.Srcset)
+    }
+
+    context := struct {
+        Uri           interface{}
+        Srcset        interface{}
+        Sizes         string
+        AltText       string
+        Width, Height int
+        LazyLoading   bool
+    }{
+        Uri:         uriValue(iw.Locator, ri.Uri),
+        Srcset:      srcset,
+        Sizes:       ri.Sizes,
+        AltText:     iw.AltText,
+        Width:       ri.Width,
+        Height:      ri.Height,
+        LazyLoading: iw.LazyLoading,
+    }
+
+    err = widgetTemplates.ExecuteTemplate(w, "image", context)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// LinkWidgetToHtml renders a single anchor with a URI that is relative to the
+// root of the site. See LinkWidgetToHtmlFrom.
+func LinkWidgetToHtml(lw sitebuilder.LinkWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    err = LinkWidgetToHtmlFrom(nil, lw, w)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// LinkWidgetToHtmlFrom renders a single anchor. Links to other pages are
+// relative to the page `sn`.
+func LinkWidgetToHtmlFrom(sn *sitebuilder.SiteNode, lw sitebuilder.LinkWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    err = widgetTemplates.ExecuteTemplate(w, "link", newLinkContext(sn, lw))
+    log.PanicIf(err)
+
+    return nil
+}
+
+// HeadingToHtml renders a heading. The level is clamped to what HTML
+// supports.
+func HeadingToHtml(h sitebuilder.HeadingWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    level := h.Level
+    if level < 1 {
+        level = 1
+    } else if level > 6 {
+        level = 6
+    }
+
+    context := struct {
+        Open  template.HTML
+        Close template.HTML
+        Text  string
+    }{
+        Open:  template.HTML(fmt.Sprintf("<h%d>", level)),
+        Close: template.HTML(fmt.Sprintf("</h%d>", level)),
+        Text:  h.Text,
+    }
+
+    err = widgetTemplates.ExecuteTemplate(w, "heading", context)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// NavbarToHtml renders the links within a nav element with URIs that are
+// relative to the root of the site. See NavbarToHtmlFrom.
+func NavbarToHtml(items []sitebuilder.LinkWidget, class string, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    err = NavbarToHtmlFrom(nil, items, class, w)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// NavbarToHtmlFrom renders the links as a list within a nav element. `class`
+// is assigned to the nav element so that horizontal and vertical navbars can
+// be styled differently. Links to other pages are relative to the page `sn`.
+func NavbarToHtmlFrom(sn *sitebuilder.SiteNode, items []sitebuilder.LinkWidget, class string, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    linkContexts := make([]linkContext, len(items))
+    for i, lw := range items {
+        linkContexts[i] = newLinkContext(sn, lw)
+    }
+
+    context := struct {
+        Class string
+        Items []linkContext
+    }{
+        Class: class,
+        Items: linkContexts,
+    }
+
+    err = widgetTemplates.ExecuteTemplate(w, "navbar", context)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// BreadcrumbToHtml renders the linked items followed by the unlinked text of
+// the current page, all separated by `separator`.
+func BreadcrumbToHtml(sn *sitebuilder.SiteNode, items []sitebuilder.LinkWidget, currentText, separator string, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    linkContexts := make([]linkContext, len(items))
+    for i, lw := range items {
+        linkContexts[i] = newLinkContext(sn, lw)
+    }
+
+    context := struct {
+        Items       []linkContext
+        CurrentText string
+        Separator   string
+    }{
+        Items:       linkContexts,
+        CurrentText: currentText,
+        Separator:   separator,
+    }
+
+    err = widgetTemplates.ExecuteTemplate(w, "breadcrumb", context)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// SequenceNavigationToHtml renders the links that are present, each preceded
+// by its label. Nothing is rendered if there are no links.
+func SequenceNavigationToHtml(sn *sitebuilder.SiteNode, snw sitebuilder.SequenceNavigationWidget, sl sitebuilder.SequenceLinks, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    type part struct {
+        Rel   string
+        Label string
+        Link  linkContext
+    }
+
+    candidates := []struct {
+        rel   string
+        label string
+        lw    *sitebuilder.LinkWidget
+    }{
+        {"prev", snw.PreviousLabel, sl.Previous},
+        {"up", snw.UpLabel, sl.Up},
+        {"next", snw.NextLabel, sl.Next},
+    }
+
+    parts := make([]part, 0)
+    for _, candidate := range candidates {
+        if candidate.lw == nil {
+            continue
+        }
+
+        p := part{
+            Rel:   candidate.rel,
+            Label: candidate.label,
+            Link:  newLinkContext(sn, *candidate.lw),
+        }
+
+        parts = append(parts, p)
+    }
+
+    if len(parts) == 0 {
+        return nil
+    }
+
+    context := struct {
+        Parts []part
+    }{
+        Parts: parts,
+    }
+
+    err = widgetTemplates.ExecuteTemplate(w, "sequence_navigation", context)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// ParagraphToHtml renders the spans of the paragraph. All text is escaped.
+// Links are relative to the page `sn`.
+func ParagraphToHtml(sn *sitebuilder.SiteNode, pw sitebuilder.ParagraphWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    type span struct {
+        Tag  string
+        Text string
+        Link *linkContext
+    }
+
+    spans := make([]span, len(pw.Spans))
+    for i, ts := range pw.Spans {
+        s := span{
+            Text: ts.Text,
+        }
+
+        switch ts.Style {
+        case sitebuilder.PlainSpanStyle:
+        case sitebuilder.EmphasisSpanStyle:
+            s.Tag = "em"
+        case sitebuilder.StrongSpanStyle:
+            s.Tag = "strong"
+        case sitebuilder.CodeSpanStyle:
+            s.Tag = "code"
+        case sitebuilder.LinkSpanStyle:
+            lc := newLinkContext(sn, sitebuilder.NewLinkWidget(ts.Text, ts.Locator))
+            s.Link = &lc
+        default:
+            log.Panicf("span style not valid: (%d)", ts.Style)
+        }
+
+        spans[i] = s
+    }
+
+    context := struct {
+        Spans []span
+    }{
+        Spans: spans,
+    }
+
+    err = widgetTemplates.ExecuteTemplate(w, "paragraph", context)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// TableToHtml renders the table with a header row. Alignments other than the
+// default are applied as styles. Links and images are relative to the page
+// `sn`.
+func TableToHtml(sn *sitebuilder.SiteNode, tw sitebuilder.TableWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    err = tw.Validate()
+    log.PanicIf(err)
+
+    type image struct {
+        Uri     interface{}
+        AltText string
+    }
+
+    type cell struct {
+        Align string
+        Text  string
+        Link  *linkContext
+        Image *image
+    }
+
+    headers := make([]cell, len(tw.Columns))
+    for i, tc := range tw.Columns {
+        headers[i] = cell{
+            Align: tc.Alignment.String(),
+            Text:  tc.Header,
+        }
+    }
+
+    rows := make([][]cell, len(tw.Rows))
+    for i := range tw.Rows {
+        cells := make([]cell, len(tw.Columns))
+        for j, column := range tw.Columns {
+            tc := tw.Cell(i, j)
+
+            c := cell{
+                Align: column.Alignment.String(),
+                Text:  tc.Text,
+            }
+
+            switch tc.Type {
+            case sitebuilder.TextCellType:
+            case sitebuilder.LinkCellType:
+                lc := newLinkContext(sn, sitebuilder.NewLinkWidget(tc.Text, tc.Locator))
+                c.Link = &lc
+            case sitebuilder.ImageCellType:
+                uri, err := sitebuilder.CheckedResolveUri(tc.Locator, sn)
+                log.PanicIf(err)
+
+                c.Image = &image{
+                    Uri:     uriValue(tc.Locator, uri),
+                    AltText: tc.Text,
+                }
+            default:
+                log.Panicf("cell type not valid: (%d)", tc.Type)
+            }
+
+            cells[j] = c
+        }
+
+        rows[i] = cells
+    }
+
+    context := struct {
+        Caption string
+        Headers []cell
+        Rows    [][]cell
+    }{
+        Caption: tw.Caption,
+        Headers: headers,
+        Rows:    rows,
+    }
+
+    err = widgetTemplates.ExecuteTemplate(w, "table", context)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// GalleryToHtml renders the thumbnails of the gallery as a grid. Each
+// thumbnail links to its full-size image or image page.
+func GalleryToHtml(sn *sitebuilder.SiteNode, gw sitebuilder.GalleryWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    thumbnails, err := gw.Thumbnails(sn)
+    log.PanicIf(err)
+
+    type thumbnail struct {
+        Caption       string
+        ThumbnailUri  template.URL
+        TargetUri     interface{}
+        Width, Height int
+    }
+
+    contexts := make([]thumbnail, len(thumbnails))
+    for i, gt := range thumbnails {
+        contexts[i] = thumbnail{
+            Caption:      gt.Caption,
+            ThumbnailUri: template.URL(gt.ThumbnailUri),
+            TargetUri:    uriValue(gt.TargetLocator, gt.TargetUri),
+            Width:        gt.Width,
+            Height:       gt.Height,
+        }
+    }
+
+    context := struct {
+        Caption    string
+        Columns    int
+        Thumbnails []thumbnail
+    }{
+        Caption:    gw.Caption,
+        Columns:    gw.Columns,
+        Thumbnails: contexts,
+    }
+
+    err = widgetTemplates.ExecuteTemplate(w, "gallery", context)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// CodeBlockToHtml renders the code with syntax highlighting. The code is read
+// from the widget's source if it has one.
+func CodeBlockToHtml(sn *sitebuilder.SiteNode, cbw sitebuilder.CodeBlockWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    code, err := cbw.ReadCode(sn)
+    log.PanicIf(err)
+
+    err = highlight.WriteHtml(w, cbw, code)
+    log.PanicIf(err)
+
+    return nil
+}
+
+func init() {
+    widgetTemplates = template.Must(template.New("widgets").Parse(widgetTemplatesText))
+}
@@ -0,0 +1,417 @@
+package htmldialect
+
+import (
+    "bytes"
+    "fmt"
+    "image"
+    "os"
+    "path"
+    "strings"
+    "testing"
+
+    "image/png"
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/dsoprea/go-static-site-builder"
+    "github.com/dsoprea/go-static-site-builder/highlight"
+)
+
+func TestImageWidgetToHtml(t *testing.T) {
+    lrl := sitebuilder.NewLocalResourceLocator("/some/image/path")
+    iw := sitebuilder.NewImageWidget("alt text", lrl, 0, 0)
+
+    b := new(bytes.Buffer)
+
+    err := ImageWidgetToHtml(iw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "<figure class=\"content-image\"><img src=\"file:///some/image/path\" alt=\"alt text\" /></figure>\n"
+
+    if actual != expected {
+        t.Fatalf("Image to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestImageWidgetToHtml_Dimensions(t *testing.T) {
+    lrl := sitebuilder.NewLocalResourceLocator("/some/image/path")
+    iw := sitebuilder.NewImageWidget("alt text", lrl, 100, 50)
+
+    b := new(bytes.Buffer)
+
+    err := ImageWidgetToHtml(iw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "<figure class=\"content-image\"><img src=\"file:///some/image/path\" alt=\"alt text\" width=\"100\" height=\"50\" /></figure>\n"
+
+    if actual != expected {
+        t.Fatalf("Image to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestImageWidgetToHtml_Embedded(t *testing.T) {
+    erl, err := sitebuilder.NewEmbeddedResourceLocatorWithBytes("image/png", []byte{1, 2, 3})
+    log.PanicIf(err)
+
+    iw := sitebuilder.NewImageWidget("alt text", erl, 0, 0)
+
+    b := new(bytes.Buffer)
+
+    err = ImageWidgetToHtml(iw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "<figure class=\"content-image\"><img src=\"data:image/png;base64,AQID\" alt=\"alt text\" /></figure>\n"
+
+    if actual != expected {
+        t.Fatalf("Embedded image to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+// writeTestImage writes an opaque PNG image of the given size to a temporary
+// directory, which the caller removes.
+func writeTestImage(width, height int) (tempPath, filepath string) {
+    tempPath, err := ioutil.TempDir("", "")
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    err = png.Encode(b, image.NewGray(image.Rect(0, 0, width, height)))
+    log.PanicIf(err)
+
+    filepath = path.Join(tempPath, "image.png")
+
+    err = ioutil.WriteFile(filepath, b.Bytes(), 0644)
+    log.PanicIf(err)
+
+    return tempPath, filepath
+}
+
+func TestImageWidgetToHtml_DetectedDimensions(t *testing.T) {
+    tempPath, filepath := writeTestImage(40, 30)
+    defer os.RemoveAll(tempPath)
+
+    iw := sitebuilder.NewImageWidget("alt text", sitebuilder.NewLocalResourceLocator(filepath), 20, 0)
+    iw.LazyLoading = true
+
+    b := new(bytes.Buffer)
+
+    err := ImageWidgetToHtml(iw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := fmt.Sprintf("<figure class=\"content-image\"><img src=\"file://%s\" alt=\"alt text\" width=\"20\" height=\"15\" loading=\"lazy\" /></figure>\n", filepath)
+
+    if actual != expected {
+        t.Fatalf("Image to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestImageWidgetToHtml_Responsive(t *testing.T) {
+    tempPath, filepath := writeTestImage(40, 30)
+    defer os.RemoveAll(tempPath)
+
+    sc := sitebuilder.NewSiteContext(tempPath)
+    sb := sitebuilder.NewSiteBuilder("site title", NewHtmlDialect(), sc)
+
+    iw := sitebuilder.NewResponsiveImageWidget("alt text", sitebuilder.NewLocalResourceLocator(filepath), 20)
+
+    ri, err := iw.Resolve(sb.Root())
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    err = ImageWidgetToHtmlFrom(sb.Root(), iw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := fmt.Sprintf("<figure class=\"content-image\"><img src=\"file://%s\" srcset=\"%s\" sizes=\"(max-width: 40px) 100vw, 40px\" alt=\"alt text\" width=\"40\" height=\"30\" loading=\"lazy\" /></figure>\n", filepath, ri.Srcset)
+
+    if actual != expected {
+        t.Fatalf("Image to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    } else if strings.HasPrefix(ri.Srcset, "assets/") == false || strings.Contains(ri.Srcset, " 20w, ") == false {
+        t.Fatalf("Srcset not correct: [%s]", ri.Srcset)
+    }
+}
+
+func TestLinkWidgetToHtml(t *testing.T) {
+    lrl := sitebuilder.NewLocalResourceLocator("/some/file")
+    lw := sitebuilder.NewLinkWidget("text <b>", lrl)
+
+    b := new(bytes.Buffer)
+
+    err := LinkWidgetToHtml(lw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "<a href=\"file:///some/file\">text &lt;b&gt;</a>"
+
+    if actual != expected {
+        t.Fatalf("Link to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestLinkWidgetToHtml_UntrustedUri(t *testing.T) {
+    // Local paths that are not absolute or output-relative are used as given,
+    // so they are sanitized.
+    lrl := sitebuilder.NewLocalResourceLocator("javascript:alert(1)")
+    lw := sitebuilder.NewLinkWidget("text", lrl)
+
+    b := new(bytes.Buffer)
+
+    err := LinkWidgetToHtml(lw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "<a href=\"#ZgotmplZ\">text</a>"
+
+    if actual != expected {
+        t.Fatalf("Untrusted link not sanitized:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+
+    lrl = sitebuilder.NewLocalResourceLocator("images/image.png")
+    iw := sitebuilder.NewImageWidget("alt text", lrl, 10, 10)
+
+    b = new(bytes.Buffer)
+
+    err = ImageWidgetToHtml(iw, b)
+    log.PanicIf(err)
+
+    actual = b.String()
+    expected = "<figure class=\"content-image\"><img src=\"images/image.png\" alt=\"alt text\" width=\"10\" height=\"10\" /></figure>\n"
+
+    if actual != expected {
+        t.Fatalf("Safe relative image not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestHeadingToHtml(t *testing.T) {
+    hw := sitebuilder.NewHeadingWidget(2, "some \"heading\"")
+
+    b := new(bytes.Buffer)
+
+    err := HeadingToHtml(hw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "<h2>some &#34;heading&#34;</h2>\n"
+
+    if actual != expected {
+        t.Fatalf("Heading to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestHeadingToHtml_ClampLevel(t *testing.T) {
+    hw := sitebuilder.NewHeadingWidget(9, "some heading")
+
+    b := new(bytes.Buffer)
+
+    err := HeadingToHtml(hw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "<h6>some heading</h6>\n"
+
+    if actual != expected {
+        t.Fatalf("Heading to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestNavbarToHtml(t *testing.T) {
+    items := []sitebuilder.LinkWidget{
+        sitebuilder.NewLinkWidget("Child1", sitebuilder.NewLocalResourceLocator("/some/path1")),
+        sitebuilder.NewLinkWidget("Child2", sitebuilder.NewLocalResourceLocator("/some/path2")),
+    }
+
+    b := new(bytes.Buffer)
+
+    err := NavbarToHtml(items, "horizontal-navbar", b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := `<nav class="horizontal-navbar">
+<ul>
+<li><a href="file:///some/path1">Child1</a></li>
+<li><a href="file:///some/path2">Child2</a></li>
+</ul>
+</nav>
+`
+
+    if actual != expected {
+        t.Fatalf("Navbar to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestBreadcrumbToHtml(t *testing.T) {
+    items := []sitebuilder.LinkWidget{
+        sitebuilder.NewLinkWidget("Home", sitebuilder.NewLocalResourceLocator("index.html")),
+        sitebuilder.NewLinkWidget("Section", sitebuilder.NewLocalResourceLocator("section.html")),
+    }
+
+    b := new(bytes.Buffer)
+
+    err := BreadcrumbToHtml(nil, items, "Page", " > ", b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "<nav class=\"breadcrumb\"><a href=\"index.html\">Home</a> &gt; <a href=\"section.html\">Section</a> &gt; <span aria-current=\"page\">Page</span></nav>\n"
+
+    if actual != expected {
+        t.Fatalf("Breadcrumb to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestSequenceNavigationToHtml(t *testing.T) {
+    up := sitebuilder.NewLinkWidget("Home", sitebuilder.NewLocalResourceLocator("index.html"))
+    next := sitebuilder.NewLinkWidget("Second", sitebuilder.NewLocalResourceLocator("second.html"))
+
+    sl := sitebuilder.SequenceLinks{
+        Up:   &up,
+        Next: &next,
+    }
+
+    snw := sitebuilder.NewSequenceNavigationWidget(false)
+
+    b := new(bytes.Buffer)
+
+    err := SequenceNavigationToHtml(nil, snw, sl, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "<nav class=\"sequence-navigation\"><span class=\"up\">Up: <a href=\"index.html\" rel=\"up\">Home</a></span> | <span class=\"next\">Next: <a href=\"second.html\" rel=\"next\">Second</a></span></nav>\n"
+
+    if actual != expected {
+        t.Fatalf("Sequence navigation to HTML not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestParagraphToHtml(t *testing.T) {
+    pw := sitebuilder.NewParagraphWidget(
+        sitebuilder.NewPlainSpan("1 < 2 & "),
+        sitebuilder.NewEmphasisSpan("emphasized"),
+        sitebuilder.NewPlainSpan(" "),
+        sitebuilder.NewStrongSpan("<script>"),
+        sitebuilder.NewPlainSpan(" "),
+        sitebuilder.NewCodeSpan("a && b"),
+        sitebuilder.NewPlainSpan(" "),
+        sitebuilder.NewLinkSpan("link", sitebuilder.NewLocalResourceLocator("/some/file")),
+    )
+
+    b := new(bytes.Buffer)
+
+    err := ParagraphToHtml(nil, pw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := `<p>1 &lt; 2 &amp; <em>emphasized</em> <strong>&lt;script&gt;</strong> <code>a &amp;&amp; b</code> <a href="file:///some/file">link</a></p>
+`
+
+    if actual != expected {
+        t.Fatalf("Content not correct: [%s]", actual)
+    }
+}
+
+func TestTableToHtml(t *testing.T) {
+    columns := []sitebuilder.TableColumn{
+        sitebuilder.NewTableColumn("Name", sitebuilder.DefaultAlignment),
+        sitebuilder.NewTableColumn("Link", sitebuilder.CenterAlignment),
+        sitebuilder.NewTableColumn("Image", sitebuilder.RightAlignment),
+    }
+
+    rows := [][]sitebuilder.TableCell{
+        {
+            sitebuilder.NewTextCell("a < b"),
+            sitebuilder.NewLinkCell("link", sitebuilder.NewLocalResourceLocator("/some/file")),
+            sitebuilder.NewImageCell("image", sitebuilder.NewLocalResourceLocator("/some/image")),
+        },
+        {
+            sitebuilder.NewTextCell("c"),
+        },
+    }
+
+    tw := sitebuilder.NewTableWidget(columns, rows)
+    tw.Caption = "Some caption"
+
+    b := new(bytes.Buffer)
+
+    err := TableToHtml(nil, tw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := `<table>
+<caption>Some caption</caption>
+<thead>
+<tr><th>Name</th><th style="text-align: center">Link</th><th style="text-align: right">Image</th></tr>
+</thead>
+<tbody>
+<tr><td>a &lt; b</td><td style="text-align: center"><a href="file:///some/file">link</a></td><td style="text-align: right"><img src="file:///some/image" alt="image" /></td></tr>
+<tr><td>c</td><td style="text-align: center"></td><td style="text-align: right"></td></tr>
+</tbody>
+</table>
+`
+
+    if actual != expected {
+        t.Fatalf("Content not correct:\n%s", actual)
+    }
+}
+
+func TestCodeBlockToHtml(t *testing.T) {
+    erl, err := sitebuilder.NewEmbeddedResourceLocatorWithBytes("text/plain", []byte("x := \"<b>\"\n"))
+    log.PanicIf(err)
+
+    cbw := sitebuilder.NewCodeBlockWidgetFromSource("go", erl)
+    cbw.LineNumbers = true
+
+    b := new(bytes.Buffer)
+
+    err = CodeBlockToHtml(nil, cbw, b)
+    log.PanicIf(err)
+
+    // The code is read from the source.
+    expected := new(bytes.Buffer)
+
+    err = highlight.WriteHtml(expected, cbw, "x := \"<b>\"\n")
+    log.PanicIf(err)
+
+    if b.String() != expected.String() {
+        t.Fatalf("Content not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", b.String(), expected.String())
+    }
+}
+
+func TestGalleryToHtml(t *testing.T) {
+    b := new(bytes.Buffer)
+
+    err := png.Encode(b, image.NewGray(image.Rect(0, 0, 400, 100)))
+    log.PanicIf(err)
+
+    erl, err := sitebuilder.NewEmbeddedResourceLocatorWithBytes("image/png", b.Bytes())
+    log.PanicIf(err)
+
+    gw := sitebuilder.NewGalleryWidget(sitebuilder.NewGalleryImage("a < b", erl))
+    gw.Caption = "Gallery"
+
+    thumbnails, err := gw.Thumbnails(nil)
+    log.PanicIf(err)
+
+    b = new(bytes.Buffer)
+
+    err = GalleryToHtml(nil, gw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    // The template escapes the "+" characters of the base64 data.
+    expected := fmt.Sprintf(`<figure class="gallery">
+<figcaption>Gallery</figcaption>
+<div class="gallery-grid" style="display: grid; grid-template-columns: repeat(3, 1fr); gap: 1em">
+<figure class="gallery-image"><a href="%s"><img src="%s" alt="a &lt; b" width="200" height="50" /></a><figcaption>a &lt; b</figcaption></figure>
+</div>
+</figure>
+`, thumbnails[0].TargetUri, strings.Replace(thumbnails[0].ThumbnailUri, "+", "&#43;", -1))
+
+    if actual != expected {
+        t.Fatalf("Content not correct:\n%s", actual)
+    }
+}
@@ -0,0 +1,277 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "image"
+    "io"
+    "sort"
+    "strings"
+
+    "image/jpeg"
+    "image/png"
+
+    // Register the GIF decoder.
+    _ "image/gif"
+
+    "github.com/dsoprea/go-logging"
+    "golang.org/x/image/draw"
+)
+
+const (
+    scaledJpegQuality = 85
+)
+
+var (
+    // ErrImageUndecodable indicates an image that is not a GIF, JPEG, or PNG
+    // or that is corrupt.
+    ErrImageUndecodable = errors.New("image can not be decoded")
+
+    // ErrInvalidImage indicates an image with a variant width that is not
+    // positive.
+    ErrInvalidImage = errors.New("image not valid")
+)
+
+// decodeImage decodes a GIF, JPEG, or PNG image. Errors wrap
+// ErrImageUndecodable.
+func decodeImage(raw []byte) (img image.Image, err error) {
+    img, _, err = image.Decode(bytes.NewReader(raw))
+    if err != nil {
+        return nil, fmt.Errorf("%w: %s", ErrImageUndecodable, err.Error())
+    }
+
+    return img, nil
+}
+
+// encodeScaledImage scales the image to the given size. Opaque images are
+// encoded as JPEG and all others as PNG so that transparency is kept.
+func encodeScaledImage(original image.Image, width, height int) (data []byte, mimeType, extension string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+    draw.CatmullRom.Scale(scaled, scaled.Bounds(), original, original.Bounds(), draw.Src, nil)
+
+    b := new(bytes.Buffer)
+
+    if o, ok := original.(interface{ Opaque() bool }); ok == true && o.Opaque() == true {
+        err = jpeg.Encode(b, scaled, &jpeg.Options{Quality: scaledJpegQuality})
+        log.PanicIf(err)
+
+        return b.Bytes(), "image/jpeg", ".jpg", nil
+    }
+
+    err = png.Encode(b, scaled)
+    log.PanicIf(err)
+
+    return b.Bytes(), "image/png", ".png", nil
+}
+
+// ImageDimensions reads just the header of the image that the locator refers
+// to and returns its intrinsic size. Errors reading the image wrap
+// ErrResourceUnreadable and errors decoding it wrap ErrImageUndecodable.
+func ImageDimensions(rl ResourceLocator, from *SiteNode) (width, height int, err error) {
+    rc, err := openResource(rl, from)
+    if err != nil {
+        return 0, 0, err
+    }
+
+    defer rc.Close()
+
+    return imageHeaderDimensions(rc)
+}
+
+// imageHeaderDimensions returns the intrinsic size of the image from its
+// header.
+func imageHeaderDimensions(r io.Reader) (width, height int, err error) {
+    config, _, err := image.DecodeConfig(r)
+    if err != nil {
+        return 0, 0, fmt.Errorf("%w: %s", ErrImageUndecodable, err.Error())
+    }
+
+    return config.Width, config.Height, nil
+}
+
+// scaleDimensions fills in whichever of the width and height is missing from
+// the intrinsic size so that the aspect ratio is kept. If both are missing,
+// the intrinsic size is returned.
+func scaleDimensions(width, height, intrinsicWidth, intrinsicHeight int) (int, int) {
+    if intrinsicWidth < 1 || intrinsicHeight < 1 {
+        return width, height
+    }
+
+    if width == 0 && height == 0 {
+        return intrinsicWidth, intrinsicHeight
+    } else if height == 0 {
+        height = (width*intrinsicHeight + intrinsicWidth/2) / intrinsicWidth
+        if height < 1 {
+            height = 1
+        }
+    } else if width == 0 {
+        width = (height*intrinsicWidth + intrinsicHeight/2) / intrinsicHeight
+        if width < 1 {
+            width = 1
+        }
+    }
+
+    return width, height
+}
+
+// Validate returns ErrInvalidImage if any variant width is not positive.
+func (iw ImageWidget) Validate() error {
+    for _, width := range iw.VariantWidths {
+        if width < 1 {
+            return fmt.Errorf("%w: variant width not valid: (%d)", ErrInvalidImage, width)
+        }
+    }
+
+    return nil
+}
+
+// ResolvedImage is an image as resolved for one page.
+type ResolvedImage struct {
+    Uri           string
+    Width, Height int
+
+    // Srcset and Sizes are empty unless there are variants.
+    Srcset string
+    Sizes  string
+}
+
+// Resolve returns the URI and the dimensions of the image for use in the given
+// page. Dimensions that were not given are read from the image if it is local
+// or embedded, keeping the aspect ratio. Images that can not be read or
+// decoded (e.g. SVGs) keep the dimensions that were given.
+//
+// If there are variant widths, a downscaled copy of the image is published
+// for each one that is narrower than the image, and the srcset lists them
+// along with the image itself. The image must then be readable and
+// decodable: errors reading it wrap ErrResourceUnreadable and errors decoding
+// it wrap ErrImageUndecodable.
+func (iw ImageWidget) Resolve(sn *SiteNode) (ri ResolvedImage, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    var raw []byte
+    var uri string
+
+    // Embedded data is decoded once and used for both the URI and the
+    // dimensions.
+    if erl, ok := iw.Locator.(*EmbeddedResourceLocator); ok == true && sn != nil && sn.sb != nil {
+        raw, err = ReadResource(erl, sn)
+        if err != nil {
+            return ri, err
+        }
+
+        uri, err = erl.uriForData(sn, raw)
+    } else {
+        uri, err = CheckedResolveUri(iw.Locator, sn)
+    }
+
+    if err != nil {
+        return ri, err
+    }
+
+    ri.Uri = uri
+    ri.Width = iw.Width
+    ri.Height = iw.Height
+
+    if len(iw.VariantWidths) == 0 {
+        if iw.Width == 0 || iw.Height == 0 {
+            var intrinsicWidth, intrinsicHeight int
+            if raw != nil {
+                intrinsicWidth, intrinsicHeight, err = imageHeaderDimensions(bytes.NewReader(raw))
+            } else {
+                intrinsicWidth, intrinsicHeight, err = ImageDimensions(iw.Locator, sn)
+            }
+
+            if err == nil {
+                ri.Width, ri.Height = scaleDimensions(iw.Width, iw.Height, intrinsicWidth, intrinsicHeight)
+            }
+        }
+
+        return ri, nil
+    }
+
+    err = iw.Validate()
+    log.PanicIf(err)
+
+    if sn == nil || sn.sb == nil {
+        log.Panicf("image variants need a page of a site")
+    }
+
+    if raw == nil {
+        raw, err = ReadResource(iw.Locator, sn)
+        log.PanicIf(err)
+    }
+
+    original, err := decodeImage(raw)
+    log.PanicIf(err)
+
+    bounds := original.Bounds()
+    intrinsicWidth := bounds.Dx()
+    intrinsicHeight := bounds.Dy()
+
+    ri.Width, ri.Height = scaleDimensions(iw.Width, iw.Height, intrinsicWidth, intrinsicHeight)
+
+    widths := make([]int, 0, len(iw.VariantWidths))
+    for _, width := range iw.VariantWidths {
+        if width < intrinsicWidth {
+            widths = append(widths, width)
+        }
+    }
+
+    if len(widths) == 0 {
+        return ri, nil
+    }
+
+    sort.Ints(widths)
+
+    candidates := make([]string, 0, len(widths)+1)
+
+    for i, width := range widths {
+        if i > 0 && width == widths[i-1] {
+            continue
+        }
+
+        height := (width*intrinsicHeight + intrinsicWidth/2) / intrinsicWidth
+        if height < 1 {
+            height = 1
+        }
+
+        data, _, extension, err := encodeScaledImage(original, width, height)
+        log.PanicIf(err)
+
+        publishedPath, err := sn.sb.assets.publishData(sn.sb.Context(), data, extension)
+        log.PanicIf(err)
+
+        candidates = append(candidates, srcsetCandidate(relativeUriFrom(sn, publishedPath), width))
+    }
+
+    candidates = append(candidates, srcsetCandidate(uri, intrinsicWidth))
+
+    ri.Srcset = strings.Join(candidates, ", ")
+
+    // By default, the image is as wide as it is shown but never wider than
+    // the viewport.
+    ri.Sizes = iw.Sizes
+    if ri.Sizes == "" {
+        ri.Sizes = fmt.Sprintf("(max-width: %dpx) 100vw, %dpx", ri.Width, ri.Width)
+    }
+
+    return ri, nil
+}
+
+// srcsetCandidate returns one entry of a srcset. Whitespace would end the
+// URI, so it is encoded.
+func srcsetCandidate(uri string, width int) string {
+    uri = strings.NewReplacer(" ", "%20", "\t", "%09", "\n", "%0A").Replace(uri)
+    return fmt.Sprintf("%s %dw", uri, width)
+}
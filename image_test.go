@@ -0,0 +1,216 @@
+package sitebuilder
+
+import (
+    "errors"
+    "image"
+    "os"
+    "path"
+    "reflect"
+    "strings"
+    "testing"
+
+    "encoding/json"
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func getTestImageLocator(width, height int) *EmbeddedResourceLocator {
+    erl, err := NewEmbeddedResourceLocatorWithBytes("image/png", getTestImage(width, height, true))
+    log.PanicIf(err)
+
+    return erl
+}
+
+func TestImageDimensions(t *testing.T) {
+    width, height, err := ImageDimensions(getTestImageLocator(40, 30), nil)
+    log.PanicIf(err)
+
+    if width != 40 || height != 30 {
+        t.Fatalf("Dimensions not correct: (%d)x(%d)", width, height)
+    }
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("image/svg+xml", []byte("<svg />"))
+    log.PanicIf(err)
+
+    _, _, err = ImageDimensions(erl, nil)
+    if errors.Is(err, ErrImageUndecodable) != true {
+        t.Fatalf("Expected undecodable image: [%v]", err)
+    }
+
+    _, _, err = ImageDimensions(NewLocalResourceLocator("/invalid/path.png"), nil)
+    if errors.Is(err, ErrResourceUnreadable) != true {
+        t.Fatalf("Expected unreadable image: [%v]", err)
+    }
+}
+
+func TestImageDimensions_HeaderOnly(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    // Only the signature and the header chunk of the PNG are kept, so the
+    // image can not be decoded but its size can still be read.
+    filepath := path.Join(tempPath, "truncated.png")
+
+    err = ioutil.WriteFile(filepath, getTestImage(40, 30, true)[:33], 0644)
+    log.PanicIf(err)
+
+    width, height, err := ImageDimensions(NewLocalResourceLocator(filepath), nil)
+    log.PanicIf(err)
+
+    if width != 40 || height != 30 {
+        t.Fatalf("Dimensions not correct: (%d)x(%d)", width, height)
+    }
+}
+
+func TestScaleDimensions(t *testing.T) {
+    cases := []struct {
+        width, height, expectedWidth, expectedHeight int
+    }{
+        {0, 0, 400, 300},
+        {200, 0, 200, 150},
+        {0, 30, 40, 30},
+        {100, 100, 100, 100},
+    }
+
+    for _, c := range cases {
+        width, height := scaleDimensions(c.width, c.height, 400, 300)
+        if width != c.expectedWidth || height != c.expectedHeight {
+            t.Fatalf("Dimensions for (%d)x(%d) not correct: (%d)x(%d)", c.width, c.height, width, height)
+        }
+    }
+}
+
+func TestImageWidget_Validate(t *testing.T) {
+    iw := NewResponsiveImageWidget("alt text", NewLocalResourceLocator("/a.png"), 100, 0)
+
+    if err := iw.Validate(); errors.Is(err, ErrInvalidImage) != true {
+        t.Fatalf("Image with a zero variant width should not be valid: %v", err)
+    }
+
+    sb := getLayoutTestSite("")
+
+    err := sb.Root().Builder().AddContentImage(iw)
+    if errors.Is(err, ErrInvalidImage) != true {
+        t.Fatalf("Invalid image should not be added: %v", err)
+    }
+}
+
+func TestImageWidget_Resolve(t *testing.T) {
+    iw := NewImageWidget("alt text", getTestImageLocator(400, 300), 200, 0)
+
+    ri, err := iw.Resolve(nil)
+    log.PanicIf(err)
+
+    if ri.Uri != iw.Locator.Uri() || ri.Width != 200 || ri.Height != 150 {
+        t.Fatalf("Resolved image not correct: %v", ri)
+    } else if ri.Srcset != "" || ri.Sizes != "" {
+        t.Fatalf("Image without variants should not have a srcset: %v", ri)
+    }
+}
+
+func TestImageWidget_Resolve_Unreadable(t *testing.T) {
+    iw := NewImageWidget("alt text", NewLocalResourceLocator("/invalid/path.png"), 0, 50)
+
+    ri, err := iw.Resolve(nil)
+    log.PanicIf(err)
+
+    if ri.Width != 0 || ri.Height != 50 {
+        t.Fatalf("Given dimensions should be kept: %v", ri)
+    }
+
+    sb := getLayoutTestSite("")
+
+    iw.VariantWidths = []int{10}
+
+    _, err = iw.Resolve(sb.Root())
+    if errors.Is(err, ErrResourceUnreadable) != true {
+        t.Fatalf("Expected unreadable image: [%v]", err)
+    }
+}
+
+func TestImageWidget_Resolve_Variants(t *testing.T) {
+    outputPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(outputPath)
+
+    sb := getLayoutTestSite(outputPath)
+    sb.Context().SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+
+    childNode, found := sb.Node("child1")
+    if found != true {
+        t.Fatalf("Child not found.")
+    }
+
+    // The variant that is as wide as the image is skipped and duplicates are
+    // ignored.
+    iw := NewResponsiveImageWidget("alt text", getTestImageLocator(400, 300), 200, 100, 400, 200)
+
+    ri, err := iw.Resolve(childNode)
+    log.PanicIf(err)
+
+    if ri.Width != 400 || ri.Height != 300 {
+        t.Fatalf("Dimensions not correct: %v", ri)
+    } else if ri.Sizes != "(max-width: 400px) 100vw, 400px" {
+        t.Fatalf("Sizes not correct: [%s]", ri.Sizes)
+    }
+
+    candidates := strings.Split(ri.Srcset, ", ")
+    if len(candidates) != 3 {
+        t.Fatalf("Srcset not correct: [%s]", ri.Srcset)
+    } else if candidates[2] != ri.Uri+" 400w" {
+        t.Fatalf("Srcset should end with the image: [%s]", candidates[2])
+    }
+
+    err = sb.assets.writeToPath(sb.Context())
+    log.PanicIf(err)
+
+    for i, expectedWidth := range []int{100, 200} {
+        fields := strings.Fields(candidates[i])
+
+        if strings.HasPrefix(fields[0], "../assets/") == false || strings.HasSuffix(fields[0], ".jpg") == false {
+            t.Fatalf("Variant URI not correct: [%s]", fields[0])
+        }
+
+        f, err := os.Open(path.Join(outputPath, strings.TrimPrefix(fields[0], "../")))
+        log.PanicIf(err)
+
+        config, _, err := image.DecodeConfig(f)
+        f.Close()
+
+        log.PanicIf(err)
+
+        if config.Width != expectedWidth || config.Height != expectedWidth*3/4 {
+            t.Fatalf("Variant (%d) not correct: %v", i, config)
+        }
+    }
+
+    iw.Sizes = "50vw"
+
+    ri, err = iw.Resolve(childNode)
+    log.PanicIf(err)
+
+    if ri.Sizes != "50vw" {
+        t.Fatalf("Given sizes not used: [%s]", ri.Sizes)
+    }
+}
+
+func TestImageWidget_RoundTrip(t *testing.T) {
+    iw := NewResponsiveImageWidget("alt text", NewLocalResourceLocator("/a.png"), 100, 200)
+    iw.Sizes = "50vw"
+
+    data, err := json.Marshal(iw)
+    log.PanicIf(err)
+
+    var recovered ImageWidget
+
+    err = json.Unmarshal(data, &recovered)
+    log.PanicIf(err)
+
+    if reflect.DeepEqual(recovered, iw) != true {
+        t.Fatalf("Image not recovered correctly: %v", recovered)
+    }
+}
@@ -0,0 +1,104 @@
+package sitebuilder
+
+import (
+    "bytes"
+
+    "html/template"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// LayoutContext is the data that a layout template is executed with.
+type LayoutContext struct {
+    // Body is the HTML produced by the dialect for the page.
+    Body template.HTML
+
+    // Node is the page being rendered.
+    Node *SiteNode
+
+    // Ancestors are the nodes from the root down to (but not including) the
+    // current node.
+    Ancestors []*SiteNode
+
+    // Children are the immediate children of the current node.
+    Children []*SiteNode
+}
+
+// Uri returns the URI of the given node's page relative to the page being
+// rendered so that layouts can link to other pages.
+func (lc LayoutContext) Uri(sn *SiteNode) string {
+    return sn.UriFrom(lc.Node)
+}
+
+// SetLayout sets the layout that is applied to the output of every page,
+// regardless of dialect. The template is executed with a LayoutContext.
+func (sc *SiteContext) SetLayout(layout *template.Template) {
+    sc.layout = layout
+}
+
+// LayoutFor returns the layout that applies to the given node, or nil if the
+// dialect output is to be used as-is. A layout set directly on the node takes
+// precedence, followed by the section layout of the nearest node in the path
+// from the root (including the node itself), followed by the site-wide layout.
+func (sc *SiteContext) LayoutFor(sn *SiteNode) *template.Template {
+    if sn.layout != nil {
+        return sn.layout
+    }
+
+    if sn.sectionLayout != nil {
+        return sn.sectionLayout
+    }
+
+    ancestors := sn.Ancestors()
+    for i := len(ancestors) - 1; i >= 0; i-- {
+        if ancestors[i].sectionLayout != nil {
+            return ancestors[i].sectionLayout
+        }
+    }
+
+    return sc.layout
+}
+
+// SetLayout sets a layout for this page only. This overrides the section and
+// site-wide layouts. Templates can not be stored, so node and section layouts
+// are not saved by Save and must be set again after LoadSiteBuilder.
+func (sn *SiteNode) SetLayout(layout *template.Template) {
+    sn.layout = layout
+}
+
+// SetSectionLayout sets a layout for this page and all of its descendants.
+// This overrides the site-wide layout and the section layouts of any
+// ancestors. Like node layouts, it is not saved by Save.
+func (sn *SiteNode) SetSectionLayout(layout *template.Template) {
+    sn.sectionLayout = layout
+}
+
+// applyLayout wraps the final output in the applicable layout, if any.
+func (sn *SiteNode) applyLayout() (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    layout := sn.sb.Context().LayoutFor(sn)
+    if layout == nil {
+        return nil
+    }
+
+    lc := LayoutContext{
+        Body:      template.HTML(sn.FinalOutput()),
+        Node:      sn,
+        Ancestors: sn.Ancestors(),
+        Children:  sn.Children,
+    }
+
+    b := new(bytes.Buffer)
+
+    err = layout.Execute(b, lc)
+    log.PanicIf(err)
+
+    sn.SetFinalOutput(b.Bytes())
+
+    return nil
+}
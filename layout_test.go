@@ -0,0 +1,216 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "fmt"
+    "os"
+    "path"
+    "testing"
+
+    "html/template"
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+const (
+    testLayoutText = `<html>
+<head><meta charset="utf-8"><title>{{.Node.PageTitle}}</title></head>
+<body>
+<nav>{{range .Ancestors}}<a href="{{$.Uri .}}">{{.PageTitle}}</a> {{end}}</nav>
+{{.Body}}<ul>{{range .Children}}<li><a href="{{$.Uri .}}">{{.PageTitle}}</a></li>{{end}}</ul>
+</body>
+</html>
+`
+)
+
+func getLayoutTestSite(htmlOutputPath string) (sb *SiteBuilder) {
+    sc := NewSiteContext(htmlOutputPath)
+
+    td := NewTestDialect()
+    sb = NewSiteBuilder("site title", td, sc)
+
+    rootNode := sb.Root()
+
+    childNode1, err := rootNode.AddChildNode("child1", "Child1")
+    log.PanicIf(err)
+
+    _, err = rootNode.AddChildNode("child2", "Child2")
+    log.PanicIf(err)
+
+    _, err = childNode1.AddChildNode("childChild1", "ChildChild1")
+    log.PanicIf(err)
+
+    return sb
+}
+
+func TestSiteNode_Render_SiteLayout(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    layout := template.Must(template.New("layout").Parse(testLayoutText))
+    sb.Context().SetLayout(layout)
+
+    err := sb.Root().Render()
+    log.PanicIf(err)
+
+    childChildNode1 := sb.Root().Children[0].Children[0]
+    actual := string(childChildNode1.FinalOutput())
+
+    expected := `<html>
+<head><meta charset="utf-8"><title>ChildChild1</title></head>
+<body>
+<nav><a href="index.html">site title</a> <a href="child1.html">Child1</a> </nav>
+<header>ChildChild1</header>
+<footer>ChildChild1</footer>
+<ul></ul>
+</body>
+</html>
+`
+
+    if actual != expected {
+        fmt.Printf("ACTUAL:\n=====\n%s=====\n", actual)
+        fmt.Printf("EXPECTED:\n=====\n%s=====\n", expected)
+
+        t.Fatalf("Unexpected output.")
+    }
+
+    actual = string(sb.Root().FinalOutput())
+
+    expected = `<html>
+<head><meta charset="utf-8"><title>site title</title></head>
+<body>
+<nav></nav>
+<header>site title</header>
+<footer>site title</footer>
+<ul><li><a href="child1.html">Child1</a></li><li><a href="child2.html">Child2</a></li></ul>
+</body>
+</html>
+`
+
+    if actual != expected {
+        fmt.Printf("ACTUAL:\n=====\n%s=====\n", actual)
+        fmt.Printf("EXPECTED:\n=====\n%s=====\n", expected)
+
+        t.Fatalf("Unexpected output.")
+    }
+}
+
+func TestSiteNode_Render_NoLayout(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    err := sb.Root().Render()
+    log.PanicIf(err)
+
+    actual := string(sb.Root().FinalOutput())
+    expected := "<header>site title</header>\n<footer>site title</footer>\n"
+
+    if actual != expected {
+        t.Fatalf("Unexpected output: [%s]", actual)
+    }
+}
+
+func TestSiteContext_LayoutFor(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    siteLayout := template.Must(template.New("site").Parse("site"))
+    sectionLayout := template.Must(template.New("section").Parse("section"))
+    nodeLayout := template.Must(template.New("node").Parse("node"))
+
+    sc := sb.Context()
+    sc.SetLayout(siteLayout)
+
+    rootNode := sb.Root()
+    childNode1 := rootNode.Children[0]
+    childNode2 := rootNode.Children[1]
+    childChildNode1 := childNode1.Children[0]
+
+    childNode1.SetSectionLayout(sectionLayout)
+    childNode2.SetLayout(nodeLayout)
+
+    if sc.LayoutFor(rootNode) != siteLayout {
+        t.Fatalf("Root should use the site layout.")
+    } else if sc.LayoutFor(childNode1) != sectionLayout {
+        t.Fatalf("Section node should use its own section layout.")
+    } else if sc.LayoutFor(childChildNode1) != sectionLayout {
+        t.Fatalf("Descendant should inherit the section layout.")
+    } else if sc.LayoutFor(childNode2) != nodeLayout {
+        t.Fatalf("Node should use its own layout.")
+    }
+
+    childChildNode1.SetLayout(nodeLayout)
+
+    if sc.LayoutFor(childChildNode1) != nodeLayout {
+        t.Fatalf("Node layout should override the section layout.")
+    }
+}
+
+func TestSiteBuilder_Save_Layouts(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    siteLayout := template.Must(template.New("site").Parse("site"))
+    sectionLayout := template.Must(template.New("section").Parse("section"))
+    nodeLayout := template.Must(template.New("node").Parse("node"))
+
+    sc := sb.Context()
+    sc.SetLayout(siteLayout)
+
+    sb.Root().Children[0].SetSectionLayout(sectionLayout)
+    sb.Root().Children[1].SetLayout(nodeLayout)
+
+    b := new(bytes.Buffer)
+
+    err := sb.Save(b)
+    log.PanicIf(err)
+
+    loadedSb, err := LoadSiteBuilder(b, NewTestDialect(), sc)
+    log.PanicIf(err)
+
+    // Node and section layouts are not stored, so only the site-wide layout
+    // of the context that was passed applies.
+
+    for _, sn := range loadedSb.rootNode.flatten() {
+        if sc.LayoutFor(sn) != siteLayout {
+            t.Fatalf("Node [%s] should use the site layout after loading.", sn.PageId)
+        }
+    }
+
+    // They can be set again.
+
+    childNode1 := loadedSb.Root().Children[0]
+    childNode1.SetSectionLayout(sectionLayout)
+
+    if sc.LayoutFor(childNode1.Children[0]) != sectionLayout {
+        t.Fatalf("Section layout not applied after being set again.")
+    }
+}
+
+func TestSiteBuilder_WriteToPath_SectionLayout(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getLayoutTestSite(tempPath)
+
+    sectionLayout := template.Must(template.New("section").Parse("<section>{{.Body}}</section>\n"))
+    sb.Root().Children[0].SetSectionLayout(sectionLayout)
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    expected := map[string]string{
+        "index.html":       "<header>site title</header>\n<footer>site title</footer>\n",
+        "child1.html":      "<section><header>Child1</header>\n<footer>Child1</footer>\n</section>\n",
+        "child2.html":      "<header>Child2</header>\n<footer>Child2</footer>\n",
+        "childChild1.html": "<section><header>ChildChild1</header>\n<footer>ChildChild1</footer>\n</section>\n",
+    }
+
+    for filename, expectedContent := range expected {
+        actual, err := ioutil.ReadFile(path.Join(tempPath, filename))
+        log.PanicIf(err)
+
+        if string(actual) != expectedContent {
+            t.Fatalf("File [%s] not correct:\n%s", filename, actual)
+        }
+    }
+}
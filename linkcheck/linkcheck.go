@@ -0,0 +1,482 @@
+// Package linkcheck checks the links of a site after it has been written.
+// Every HTML file in the output path is parsed and every `href`, `src`, and
+// `srcset` reference is resolved against the output path. Fragments are
+// checked against the element IDs of the target page.
+package linkcheck
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path"
+    "sort"
+    "strings"
+
+    "net/url"
+    "path/filepath"
+
+    "github.com/dsoprea/go-logging"
+    "golang.org/x/net/html"
+)
+
+const (
+    indexFilename = "index.html"
+)
+
+var (
+    // ErrTargetNotFound indicates a link to a file that does not exist in the
+    // output path.
+    ErrTargetNotFound = errors.New("link target not found")
+
+    // ErrAnchorNotFound indicates a link to a fragment that does not match
+    // the ID of any element of the target page.
+    ErrAnchorNotFound = errors.New("link anchor not found")
+
+    // ErrOutsideOutputPath indicates a relative link that leaves the output
+    // path.
+    ErrOutsideOutputPath = errors.New("link target outside of output path")
+)
+
+var (
+    // linkAttributes are the attributes that refer to other resources.
+    linkAttributes = map[string]struct{}{
+        "href":   {},
+        "src":    {},
+        "srcset": {},
+    }
+
+    // ignoredSchemes are schemes that do not refer to anything that can be
+    // checked.
+    ignoredSchemes = map[string]struct{}{
+        "data":       {},
+        "mailto":     {},
+        "tel":        {},
+        "javascript": {},
+    }
+)
+
+// ExternalChecker checks links that refer outside of the site (e.g. with an
+// "http" scheme). A nil error means that the link is alive.
+type ExternalChecker interface {
+    CheckExternal(u *url.URL) error
+}
+
+// ExternalCheckerFunc adapts a function to an ExternalChecker.
+type ExternalCheckerFunc func(u *url.URL) error
+
+func (f ExternalCheckerFunc) CheckExternal(u *url.URL) error {
+    return f(u)
+}
+
+// DeadLink describes one reference that could not be resolved.
+type DeadLink struct {
+    // Attribute is the attribute that the reference was found in.
+    Attribute string
+
+    // Target is the reference as written.
+    Target string
+
+    Err error
+}
+
+func (dl DeadLink) String() string {
+    return fmt.Sprintf("%s=[%s]: %s", dl.Attribute, dl.Target, dl.Err.Error())
+}
+
+// PageResult lists the dead links of a single page. The path is relative to
+// the output path.
+type PageResult struct {
+    Path      string
+    DeadLinks []DeadLink
+}
+
+// Result is the outcome of a check.
+type Result struct {
+    // Pages has an entry for every page that has dead links, in the order of
+    // their paths.
+    Pages []PageResult
+
+    // PageCount is the number of pages that were parsed.
+    PageCount int
+
+    // LinkCount is the number of references that were checked.
+    LinkCount int
+
+    // SkippedCount is the number of external references that were not
+    // checked because there is no ExternalChecker.
+    SkippedCount int
+}
+
+// DeadLinkCount returns the number of dead links across all pages.
+func (r *Result) DeadLinkCount() (count int) {
+    for _, pr := range r.Pages {
+        count += len(pr.DeadLinks)
+    }
+
+    return count
+}
+
+// HasDeadLinks returns whether any dead links were found.
+func (r *Result) HasDeadLinks() bool {
+    return len(r.Pages) > 0
+}
+
+// WriteSummary writes a human-readable report of the dead links.
+func (r *Result) WriteSummary(w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    _, err = fmt.Fprintf(w, "Checked (%d) link(s) on (%d) page(s). Skipped (%d) external link(s). Found (%d) dead link(s).\n", r.LinkCount, r.PageCount, r.SkippedCount, r.DeadLinkCount())
+    log.PanicIf(err)
+
+    for _, pr := range r.Pages {
+        _, err = fmt.Fprintf(w, "\n%s\n", pr.Path)
+        log.PanicIf(err)
+
+        for _, dl := range pr.DeadLinks {
+            _, err = fmt.Fprintf(w, "  %s\n", dl)
+            log.PanicIf(err)
+        }
+    }
+
+    return nil
+}
+
+// Summary returns the same report as WriteSummary.
+func (r *Result) Summary() string {
+    sb := new(strings.Builder)
+
+    err := r.WriteSummary(sb)
+    log.PanicIf(err)
+
+    return sb.String()
+}
+
+// Checker checks the links of the HTML files in an output path.
+type Checker struct {
+    outputPath      string
+    externalChecker ExternalChecker
+
+    // ids caches the element IDs of every page that has been parsed, keyed
+    // by the path relative to the output path.
+    ids map[string]map[string]struct{}
+}
+
+// NewChecker returns a checker for the given output path. External links are
+// skipped unless an ExternalChecker is set.
+func NewChecker(outputPath string) *Checker {
+    return &Checker{
+        outputPath: outputPath,
+    }
+}
+
+// SetExternalChecker sets the checker that is used for external links.
+func (c *Checker) SetExternalChecker(ec ExternalChecker) {
+    c.externalChecker = ec
+}
+
+// Check parses every HTML file under the output path and checks all of its
+// references.
+func (c *Checker) Check() (result *Result, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    c.ids = make(map[string]map[string]struct{})
+
+    pagePaths := make([]string, 0)
+
+    err = filepath.Walk(c.outputPath, func(currentPath string, info os.FileInfo, err error) error {
+        if err != nil {
+            return err
+        }
+
+        if info.IsDir() == true || isHtmlFile(currentPath) == false {
+            return nil
+        }
+
+        relPath, err := filepath.Rel(c.outputPath, currentPath)
+        if err != nil {
+            return err
+        }
+
+        pagePaths = append(pagePaths, filepath.ToSlash(relPath))
+
+        return nil
+    })
+
+    log.PanicIf(err)
+
+    sort.Strings(pagePaths)
+
+    result = &Result{
+        Pages:     make([]PageResult, 0),
+        PageCount: len(pagePaths),
+    }
+
+    for _, pagePath := range pagePaths {
+        deadLinks, err := c.checkPage(pagePath, result)
+        log.PanicIf(err)
+
+        if len(deadLinks) > 0 {
+            pr := PageResult{
+                Path:      pagePath,
+                DeadLinks: deadLinks,
+            }
+
+            result.Pages = append(result.Pages, pr)
+        }
+    }
+
+    return result, nil
+}
+
+// reference is one link found in a page.
+type reference struct {
+    attribute string
+    target    string
+}
+
+func (c *Checker) checkPage(pagePath string, result *Result) (deadLinks []DeadLink, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    references, err := c.parse(pagePath)
+    log.PanicIf(err)
+
+    deadLinks = make([]DeadLink, 0)
+
+    for _, r := range references {
+        u, err := url.Parse(strings.TrimSpace(r.target))
+        if err != nil {
+            result.LinkCount++
+            deadLinks = append(deadLinks, DeadLink{r.attribute, r.target, err})
+
+            continue
+        }
+
+        if _, found := ignoredSchemes[strings.ToLower(u.Scheme)]; found == true {
+            continue
+        }
+
+        // Absolute local paths are written as "file" URIs.
+        if strings.ToLower(u.Scheme) == "file" {
+            result.LinkCount++
+
+            if _, err := os.Stat(filepath.FromSlash(u.Path)); err != nil {
+                err = fmt.Errorf("%w: [%s]", ErrTargetNotFound, u.Path)
+                deadLinks = append(deadLinks, DeadLink{r.attribute, r.target, err})
+            }
+
+            continue
+        }
+
+        if u.Scheme != "" || u.Host != "" {
+            if c.externalChecker == nil {
+                result.SkippedCount++
+                continue
+            }
+
+            result.LinkCount++
+
+            if err := c.externalChecker.CheckExternal(u); err != nil {
+                deadLinks = append(deadLinks, DeadLink{r.attribute, r.target, err})
+            }
+
+            continue
+        }
+
+        result.LinkCount++
+
+        if err := c.checkLocal(pagePath, u); err != nil {
+            deadLinks = append(deadLinks, DeadLink{r.attribute, r.target, err})
+        }
+    }
+
+    return deadLinks, nil
+}
+
+// checkLocal checks a reference within the output path.
+func (c *Checker) checkLocal(pagePath string, u *url.URL) (err error) {
+    targetPath := pagePath
+
+    if u.Path != "" {
+        if strings.HasPrefix(u.Path, "/") == true {
+            targetPath = path.Clean(strings.TrimPrefix(u.Path, "/"))
+        } else {
+            targetPath = path.Join(path.Dir(pagePath), u.Path)
+        }
+
+        if targetPath == ".." || strings.HasPrefix(targetPath, "../") == true {
+            return fmt.Errorf("%w: [%s]", ErrOutsideOutputPath, targetPath)
+        }
+
+        fi, err := os.Stat(filepath.Join(c.outputPath, filepath.FromSlash(targetPath)))
+        if err != nil {
+            return fmt.Errorf("%w: [%s]", ErrTargetNotFound, targetPath)
+        }
+
+        if fi.IsDir() == true {
+            targetPath = path.Join(targetPath, indexFilename)
+
+            if _, err := os.Stat(filepath.Join(c.outputPath, filepath.FromSlash(targetPath))); err != nil {
+                return fmt.Errorf("%w: [%s]", ErrTargetNotFound, targetPath)
+            }
+        }
+    }
+
+    // An empty fragment and "top" always refer to the top of the page.
+    if u.Fragment == "" || strings.ToLower(u.Fragment) == "top" || isHtmlFile(targetPath) == false {
+        return nil
+    }
+
+    ids, err := c.pageIds(targetPath)
+    if err != nil {
+        return err
+    }
+
+    if _, found := ids[u.Fragment]; found == false {
+        return fmt.Errorf("%w: [%s#%s]", ErrAnchorNotFound, targetPath, u.Fragment)
+    }
+
+    return nil
+}
+
+// pageIds returns the element IDs of the given page, parsing it if it has not
+// yet been.
+func (c *Checker) pageIds(pagePath string) (ids map[string]struct{}, err error) {
+    if ids, found := c.ids[pagePath]; found == true {
+        return ids, nil
+    }
+
+    if _, err := c.parse(pagePath); err != nil {
+        return nil, err
+    }
+
+    return c.ids[pagePath], nil
+}
+
+// parse returns the references of the given page and records its element
+// IDs.
+func (c *Checker) parse(pagePath string) (references []reference, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    f, err := os.Open(filepath.Join(c.outputPath, filepath.FromSlash(pagePath)))
+    log.PanicIf(err)
+
+    defer f.Close()
+
+    root, err := html.Parse(f)
+    log.PanicIf(err)
+
+    references = make([]reference, 0)
+    ids := make(map[string]struct{})
+
+    var walk func(n *html.Node)
+    walk = func(n *html.Node) {
+        if n.Type == html.ElementNode {
+            for _, attr := range n.Attr {
+                key := strings.ToLower(attr.Key)
+
+                if key == "id" || (key == "name" && n.Data == "a") {
+                    ids[attr.Val] = struct{}{}
+                    continue
+                }
+
+                if _, found := linkAttributes[key]; found == false {
+                    continue
+                }
+
+                if key == "srcset" {
+                    for _, target := range parseSrcset(attr.Val) {
+                        references = append(references, reference{key, target})
+                    }
+                } else {
+                    references = append(references, reference{key, attr.Val})
+                }
+            }
+        }
+
+        for child := n.FirstChild; child != nil; child = child.NextSibling {
+            walk(child)
+        }
+    }
+
+    walk(root)
+
+    c.ids[pagePath] = ids
+
+    return references, nil
+}
+
+// parseSrcset returns the URLs of a `srcset` attribute, dropping the width
+// and density descriptors. As in the HTML specification, a URL runs until
+// whitespace, so URLs may contain commas (e.g. "data:" URIs). Only the commas
+// that end a URL or that follow its descriptors separate the candidates.
+func parseSrcset(srcset string) (targets []string) {
+    targets = make([]string, 0)
+
+    isSpace := func(c byte) bool {
+        return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+    }
+
+    i := 0
+    for {
+        for i < len(srcset) && (isSpace(srcset[i]) == true || srcset[i] == ',') {
+            i++
+        }
+
+        if i >= len(srcset) {
+            break
+        }
+
+        start := i
+        for i < len(srcset) && isSpace(srcset[i]) == false {
+            i++
+        }
+
+        url := srcset[start:i]
+
+        // A URL that ends with commas has no descriptors.
+        if strings.HasSuffix(url, ",") == true {
+            targets = append(targets, strings.TrimRight(url, ","))
+            continue
+        }
+
+        targets = append(targets, url)
+
+        // Skip the descriptors. Commas within parentheses do not end them.
+        inParens := false
+        for ; i < len(srcset); i++ {
+            c := srcset[i]
+            if c == '(' {
+                inParens = true
+            } else if c == ')' {
+                inParens = false
+            } else if c == ',' && inParens == false {
+                i++
+                break
+            }
+        }
+    }
+
+    return targets
+}
+
+func isHtmlFile(filename string) bool {
+    ext := strings.ToLower(path.Ext(filename))
+    return ext == ".html" || ext == ".htm"
+}
@@ -0,0 +1,217 @@
+package linkcheck
+
+import (
+    "errors"
+    "os"
+    "path"
+    "reflect"
+    "testing"
+
+    "io/ioutil"
+    "net/url"
+
+    "github.com/dsoprea/go-logging"
+
+    "github.com/dsoprea/go-static-site-builder"
+    "github.com/dsoprea/go-static-site-builder/html"
+)
+
+func writeTestFiles(outputPath string, files map[string]string) {
+    for relPath, content := range files {
+        filepath := path.Join(outputPath, relPath)
+
+        err := os.MkdirAll(path.Dir(filepath), 0755)
+        log.PanicIf(err)
+
+        err = ioutil.WriteFile(filepath, []byte(content), 0644)
+        log.PanicIf(err)
+    }
+}
+
+func TestChecker_Check(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    files := map[string]string{
+        "index.html": `<html><body>
+<a href="docs/">Docs</a>
+<a href="docs/page.html#section">Section</a>
+<a href="docs/page.html#missing">Missing anchor</a>
+<a href="#top">Top</a>
+<a href="#local">Local</a>
+<a href="gone.html">Gone</a>
+<a href="https://example.com/">External</a>
+<a href="mailto:someone@example.com">Mail</a>
+<img src="image.png" srcset="image.png 1x, image@2x.png 2x" alt="image">
+<img src="image.png" srcset="data:image/png;base64,iVBORw0KGgo= 100w, image.png 200w" alt="embedded">
+<p id="local"></p>
+</body></html>`,
+        "docs/index.html": `<html><body><a href="../index.html">Home</a><a href="/image.png">Image</a><a href="../../outside.html">Outside</a></body></html>`,
+        "docs/page.html":  `<html><body><h2 id="section">Section</h2></body></html>`,
+        "image.png":       "",
+    }
+
+    writeTestFiles(tempPath, files)
+
+    c := NewChecker(tempPath)
+
+    result, err := c.Check()
+    log.PanicIf(err)
+
+    if result.PageCount != 3 {
+        t.Fatalf("Page count not correct: (%d)", result.PageCount)
+    } else if result.LinkCount != 14 {
+        t.Fatalf("Link count not correct: (%d)", result.LinkCount)
+    } else if result.SkippedCount != 1 {
+        t.Fatalf("Skipped count not correct: (%d)", result.SkippedCount)
+    } else if result.DeadLinkCount() != 4 {
+        t.Fatalf("Dead-link count not correct:\n%s", result.Summary())
+    }
+
+    expected := []struct {
+        pagePath string
+        target   string
+        err      error
+    }{
+        {"docs/index.html", "../../outside.html", ErrOutsideOutputPath},
+        {"index.html", "docs/page.html#missing", ErrAnchorNotFound},
+        {"index.html", "gone.html", ErrTargetNotFound},
+        {"index.html", "image@2x.png", ErrTargetNotFound},
+    }
+
+    i := 0
+    for _, pr := range result.Pages {
+        for _, dl := range pr.DeadLinks {
+            e := expected[i]
+
+            if pr.Path != e.pagePath || dl.Target != e.target || errors.Is(dl.Err, e.err) != true {
+                t.Fatalf("Dead link (%d) not correct: [%s] %s", i, pr.Path, dl)
+            }
+
+            i++
+        }
+    }
+
+    expectedSummary := `Checked (14) link(s) on (3) page(s). Skipped (1) external link(s). Found (4) dead link(s).
+
+docs/index.html
+  href=[../../outside.html]: link target outside of output path: [../outside.html]
+
+index.html
+  href=[docs/page.html#missing]: link anchor not found: [docs/page.html#missing]
+  href=[gone.html]: link target not found: [gone.html]
+  srcset=[image@2x.png]: link target not found: [image@2x.png]
+`
+
+    if summary := result.Summary(); summary != expectedSummary {
+        t.Fatalf("Summary not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", summary, expectedSummary)
+    }
+}
+
+func TestChecker_Check_ExternalChecker(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    files := map[string]string{
+        "index.html": `<a href="https://example.com/alive">Alive</a><a href="https://example.com/dead">Dead</a>`,
+    }
+
+    writeTestFiles(tempPath, files)
+
+    errDead := errors.New("dead")
+
+    checked := make([]string, 0)
+    ec := ExternalCheckerFunc(func(u *url.URL) error {
+        checked = append(checked, u.String())
+
+        if u.Path == "/dead" {
+            return errDead
+        }
+
+        return nil
+    })
+
+    c := NewChecker(tempPath)
+    c.SetExternalChecker(ec)
+
+    result, err := c.Check()
+    log.PanicIf(err)
+
+    if len(checked) != 2 {
+        t.Fatalf("External links not checked: %v", checked)
+    } else if result.SkippedCount != 0 {
+        t.Fatalf("No links should be skipped: (%d)", result.SkippedCount)
+    } else if result.DeadLinkCount() != 1 || result.Pages[0].DeadLinks[0].Err != errDead {
+        t.Fatalf("External dead link not reported:\n%s", result.Summary())
+    }
+}
+
+func TestChecker_Check_WrittenSite(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sc := sitebuilder.NewSiteContext(tempPath)
+    sc.SetOutputPathStrategy(sitebuilder.NewDirectoryOutputPathStrategy())
+
+    sb := sitebuilder.NewSiteBuilder("site title", htmldialect.NewHtmlDialect(), sc)
+
+    rootNode := sb.Root()
+
+    childNode, err := rootNode.AddChildNode("child", "Child")
+    log.PanicIf(err)
+
+    lw := sitebuilder.NewLinkWidget("Child", sitebuilder.NewSitePageLocalResourceLocator(sb, "child"))
+
+    err = rootNode.Builder().AddLink(lw)
+    log.PanicIf(err)
+
+    // A hand-written target that is never written.
+    lw = sitebuilder.NewLinkWidget("Removed", sitebuilder.NewOutputRelativeLocalResourceLocator("removed/index.html"))
+
+    err = childNode.Builder().AddLink(lw)
+    log.PanicIf(err)
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    result, err := NewChecker(tempPath).Check()
+    log.PanicIf(err)
+
+    if result.DeadLinkCount() != 1 {
+        t.Fatalf("Dead-link count not correct:\n%s", result.Summary())
+    }
+
+    pr := result.Pages[0]
+    if pr.Path != "child/index.html" || pr.DeadLinks[0].Target != "../removed/index.html" {
+        t.Fatalf("Dead link not correct:\n%s", result.Summary())
+    }
+}
+
+func TestParseSrcset(t *testing.T) {
+    cases := []struct {
+        srcset   string
+        expected []string
+    }{
+        {"image.png", []string{"image.png"}},
+        {"a.png 1x, b.png 2x", []string{"a.png", "b.png"}},
+        {"a.png, b.png", []string{"a.png", "b.png"}},
+        {" a.png 100w ,, b.png 200w, ", []string{"a.png", "b.png"}},
+        {"data:image/png;base64,iVBORw0KGgo= 100w, b.png 200w", []string{"data:image/png;base64,iVBORw0KGgo=", "b.png"}},
+        {"a,b.png 1x", []string{"a,b.png"}},
+        {"a.png,, b.png", []string{"a.png", "b.png"}},
+        {"a.png 1x (x, y), b.png", []string{"a.png", "b.png"}},
+    }
+
+    for _, c := range cases {
+        targets := parseSrcset(c.srcset)
+        if reflect.DeepEqual(targets, c.expected) != true {
+            t.Fatalf("Targets of [%s] not correct: %q", c.srcset, targets)
+        }
+    }
+}
@@ -0,0 +1,403 @@
+package sitebuilder
+
+import (
+    "fmt"
+    "os"
+    "path"
+    "sort"
+
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+const (
+    // ManifestFilename is the name of the file in the output path that
+    // records what was written by the last incremental build.
+    ManifestFilename = ".sitebuilder-manifest.json"
+
+    manifestVersion = 2
+)
+
+// buildManifest records the pages written by an incremental build. Published
+// resources are not recorded, so assets that are no longer referenced are not
+// deleted by incremental builds. Since they have content-hashed names, they
+// never conflict with the current ones and may be removed by clearing the
+// assets subdirectory and doing a full build.
+type buildManifest struct {
+    Version int
+    Pages   map[string]manifestPage
+}
+
+type manifestPage struct {
+    // Filename is the path of the page relative to the output path.
+    Filename string
+
+    // Hash identifies the content that the page was rendered from.
+    Hash string
+
+    // EmbeddedSize is the size of the resources embedded in the page. It is
+    // counted against the site-wide embedded budget by builds that do not
+    // rewrite the page.
+    EmbeddedSize int64
+}
+
+// BuildReport describes what an incremental build did. Each list contains
+// page-IDs and is sorted.
+type BuildReport struct {
+    Added     []string
+    Updated   []string
+    Unchanged []string
+    Deleted   []string
+}
+
+func (br BuildReport) String() string {
+    return fmt.Sprintf("BuildReport<ADDED=(%d) UPDATED=(%d) UNCHANGED=(%d) DELETED=(%d)>", len(br.Added), len(br.Updated), len(br.Unchanged), len(br.Deleted))
+}
+
+func readManifest(manifestFilepath string) (bm *buildManifest, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    bm = &buildManifest{
+        Version: manifestVersion,
+        Pages:   make(map[string]manifestPage),
+    }
+
+    raw, err := ioutil.ReadFile(manifestFilepath)
+    if err != nil {
+        if os.IsNotExist(err) == true {
+            return bm, nil
+        }
+
+        log.Panic(err)
+    }
+
+    err = json.Unmarshal(raw, bm)
+    log.PanicIf(err)
+
+    // If the format changed, treat everything as new.
+    if bm.Version != manifestVersion || bm.Pages == nil {
+        bm.Version = manifestVersion
+        bm.Pages = make(map[string]manifestPage)
+    }
+
+    return bm, nil
+}
+
+func (bm *buildManifest) write(manifestFilepath string) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    raw, err := json.MarshalIndent(bm, "", "  ")
+    log.PanicIf(err)
+
+    err = ioutil.WriteFile(manifestFilepath, raw, 0644)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// resourceFingerprint returns a string that changes when the resource that
+// the locator refers to changes. Files are identified by their size and
+// modification time rather than their content so that large files do not
+// have to be reread.
+func resourceFingerprint(rl ResourceLocator) string {
+    fileFingerprint := func(filepath string) string {
+        fi, err := os.Stat(filepath)
+        if err != nil {
+            return fmt.Sprintf("file:%s:missing", filepath)
+        }
+
+        return fmt.Sprintf("file:%s:%d:%d", filepath, fi.Size(), fi.ModTime().UnixNano())
+    }
+
+    switch rl.(type) {
+    case *LocalResourceLocator:
+        return fileFingerprint(rl.(*LocalResourceLocator).LocalFilepath)
+    case *PublishedResourceLocator:
+        return fileFingerprint(rl.(*PublishedResourceLocator).LocalFilepath)
+    case *EmbeddedResourceLocator:
+        erl := rl.(*EmbeddedResourceLocator)
+        if erl.Filepath != "" {
+            return fileFingerprint(erl.Filepath)
+        }
+
+        // The data is part of the statements and is hashed with them.
+        return "embedded"
+    case *SitePageLocalResourceLocator:
+        splrl := rl.(*SitePageLocalResourceLocator)
+        if sn, found := splrl.sb.Node(splrl.PageId); found == true {
+            return fmt.Sprintf("page:%s:%s", splrl.PageId, sn.OutputPath())
+        }
+
+        return fmt.Sprintf("page:%s:missing", splrl.PageId)
+    default:
+        return fmt.Sprintf("%T", rl)
+    }
+}
+
+// contentHash returns a hash of everything that the node's page is rendered
+// from: its statements, title, metadata, child list, referenced resources,
+// and the other pages that its navigation links to. Changes to layouts are
+// not detected.
+func (sn *SiteNode) contentHash() (hash string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    children := make([][2]string, len(sn.Children))
+    for i, childNode := range sn.Children {
+        children[i] = [2]string{childNode.PageId, childNode.PageTitle}
+    }
+
+    resources := make([]string, 0)
+    for _, ps := range sn.Content.Statements {
+        for _, rl := range ps.Locators() {
+            resources = append(resources, resourceFingerprint(rl))
+        }
+
+        // Sequence navigation depends on pages other than the children.
+        if ps.Type == SequenceNavigation {
+            snw := ps.StatementMetadata["sequence_navigation"].(SequenceNavigationWidget)
+            sl := snw.Links(sn)
+
+            for _, lw := range []*LinkWidget{sl.Previous, sl.Up, sl.Next} {
+                if lw == nil {
+                    resources = append(resources, "none")
+                } else {
+                    resources = append(resources, fmt.Sprintf("%s:%s", resourceFingerprint(lw.Locator), lw.Text))
+                }
+            }
+        }
+
+        // Breadcrumbs depend on the titles and paths of the ancestors.
+        if ps.Type == Breadcrumb {
+            bw := ps.StatementMetadata["breadcrumb"].(BreadcrumbWidget)
+            items, _ := bw.Items(sn)
+
+            for _, lw := range items {
+                resources = append(resources, fmt.Sprintf("%s:%s", resourceFingerprint(lw.Locator), lw.Text))
+            }
+        }
+    }
+
+    // The links to the feeds of this node and its ancestors are injected
+    // into the page.
+    feedLinks, err := sn.feedLinks()
+    log.PanicIf(err)
+
+    fingerprint := struct {
+        Dialect      string
+        PageTitle    string
+        Statements   []PageStatement
+        PageMetadata map[string]interface{}
+        Children     [][2]string
+        Resources    []string
+        FeedLinks    []string
+    }{
+        Dialect:      fmt.Sprintf("%T", sn.sb.dialect),
+        PageTitle:    sn.PageTitle,
+        Statements:   sn.Content.Statements,
+        PageMetadata: sn.Content.PageMetadata,
+        Children:     children,
+        Resources:    resources,
+        FeedLinks:    feedLinks,
+    }
+
+    raw, err := json.Marshal(fingerprint)
+    log.PanicIf(err)
+
+    digest := sha256.Sum256(raw)
+    hash = hex.EncodeToString(digest[:])
+
+    return hash, nil
+}
+
+// WriteChangedToPath writes only the pages whose content has changed since
+// the last call, and deletes the pages of nodes that no longer exist as well
+// as the old files of pages whose output path changed. What was written is
+// recorded in a manifest in the output path. If any pages fail, their last
+// successful entries are kept in the manifest (so that they are retried next
+// time and their old output is not mistaken for that of a removed page) and a
+// *WriteError is returned along with the report. Published resources that are
+// no longer used are not deleted (see buildManifest).
+func (sb *SiteBuilder) WriteChangedToPath(options WriteOptions) (report BuildReport, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    err = sb.checkOutputPaths()
+    log.PanicIf(err)
+
+    err = os.MkdirAll(sb.siteContext.htmlOutputPath, 0755)
+    log.PanicIf(err)
+
+    defer sb.startBuild()()
+    defer sb.assets.startWriting()()
+
+    manifestFilepath := path.Join(sb.siteContext.htmlOutputPath, ManifestFilename)
+
+    previous, err := readManifest(manifestFilepath)
+    log.PanicIf(err)
+
+    current := &buildManifest{
+        Version: manifestVersion,
+        Pages:   make(map[string]manifestPage),
+    }
+
+    report = BuildReport{
+        Added:     make([]string, 0),
+        Updated:   make([]string, 0),
+        Unchanged: make([]string, 0),
+        Deleted:   make([]string, 0),
+    }
+
+    changed := make([]*SiteNode, 0)
+
+    nodes := sb.rootNode.flatten()
+
+    // Old files are not deleted if something is now written in their place.
+    outputPaths := make(map[string]struct{}, len(nodes))
+    for _, sn := range nodes {
+        outputPaths[sn.OutputPath()] = struct{}{}
+
+        for _, feedPath := range sn.feedPaths() {
+            outputPaths[feedPath] = struct{}{}
+        }
+    }
+
+    removeOldFile := func(filename string) {
+        if _, found := outputPaths[filename]; found == true {
+            return
+        }
+
+        // Only files inside the output path are deleted, whatever the
+        // manifest says.
+        if err := checkOutputPath(filename, sb.siteContext.assetsPath); err != nil {
+            return
+        }
+
+        err := os.Remove(path.Join(sb.siteContext.htmlOutputPath, filename))
+        if err != nil && os.IsNotExist(err) == false {
+            log.Panic(err)
+        }
+    }
+
+    for _, sn := range nodes {
+        hash, err := sn.contentHash()
+        log.PanicIf(err)
+
+        filename := sn.OutputPath()
+
+        current.Pages[sn.PageId] = manifestPage{
+            Filename: filename,
+            Hash:     hash,
+        }
+
+        mp, found := previous.Pages[sn.PageId]
+        if found == false {
+            report.Added = append(report.Added, sn.PageId)
+            changed = append(changed, sn)
+
+            continue
+        }
+
+        _, statErr := os.Stat(path.Join(sb.siteContext.htmlOutputPath, filename))
+
+        if mp.Hash != hash || mp.Filename != filename || statErr != nil {
+            report.Updated = append(report.Updated, sn.PageId)
+            changed = append(changed, sn)
+
+            continue
+        }
+
+        current.Pages[sn.PageId] = manifestPage{
+            Filename:     filename,
+            Hash:         hash,
+            EmbeddedSize: mp.EmbeddedSize,
+        }
+
+        sb.embedded.seedPage(sn.PageId, mp.EmbeddedSize)
+
+        report.Unchanged = append(report.Unchanged, sn.PageId)
+    }
+
+    we := sb.writeNodes(changed, options)
+
+    failed := make(map[string]struct{})
+    if we != nil {
+        for _, ne := range we.NodeErrors {
+            failed[ne.PageId] = struct{}{}
+        }
+    }
+
+    for _, sn := range changed {
+        if _, found := failed[sn.PageId]; found == true {
+            // Keep the last good entry, if any. The hash no longer matches,
+            // so the page is retried next time.
+            if mp, found := previous.Pages[sn.PageId]; found == true {
+                current.Pages[sn.PageId] = mp
+            } else {
+                delete(current.Pages, sn.PageId)
+            }
+
+            continue
+        }
+
+        mp := current.Pages[sn.PageId]
+        mp.EmbeddedSize = sb.embedded.pageSize(sn.PageId)
+        current.Pages[sn.PageId] = mp
+
+        // The page was written somewhere else last time.
+        if previousMp, found := previous.Pages[sn.PageId]; found == true && previousMp.Filename != mp.Filename {
+            removeOldFile(previousMp.Filename)
+        }
+    }
+
+    for pageId, mp := range previous.Pages {
+        if _, found := current.Pages[pageId]; found == true {
+            continue
+        }
+
+        removeOldFile(mp.Filename)
+
+        report.Deleted = append(report.Deleted, pageId)
+    }
+
+    sort.Strings(report.Added)
+    sort.Strings(report.Updated)
+    sort.Strings(report.Unchanged)
+    sort.Strings(report.Deleted)
+
+    err = sb.assets.writeToPath(sb.siteContext)
+    log.PanicIf(err)
+
+    err = sb.writeSearchMetadata()
+    log.PanicIf(err)
+
+    err = sb.writeFeeds()
+    log.PanicIf(err)
+
+    err = current.write(manifestFilepath)
+    log.PanicIf(err)
+
+    if we != nil {
+        return report, we
+    }
+
+    return report, nil
+}
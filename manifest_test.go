@@ -0,0 +1,344 @@
+package sitebuilder
+
+import (
+    "fmt"
+    "os"
+    "path"
+    "testing"
+
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func getIncrementalTestSite(htmlOutputPath string, includeChild2 bool) (sb *SiteBuilder) {
+    sc := NewSiteContext(htmlOutputPath)
+    sb = NewSiteBuilder("site title", NewTestDialect(), sc)
+
+    rootNode := sb.Root()
+
+    iw := NewImageWidget("root image", NewLocalResourceLocator("/some/image/path"), 0, 0)
+
+    err := rootNode.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    childNode1, err := rootNode.AddChildNode("child1", "Child1")
+    log.PanicIf(err)
+
+    if includeChild2 == true {
+        _, err = rootNode.AddChildNode("child2", "Child2")
+        log.PanicIf(err)
+    }
+
+    _, err = childNode1.AddChildNode("childChild1", "ChildChild1")
+    log.PanicIf(err)
+
+    return sb
+}
+
+func checkReport(t *testing.T, report BuildReport, expected string) {
+    actual := fmt.Sprintf("A=%v U=%v N=%v D=%v", report.Added, report.Updated, report.Unchanged, report.Deleted)
+    if actual != expected {
+        t.Fatalf("Report not correct:\nACTUAL:   %s\nEXPECTED: %s", actual, expected)
+    }
+}
+
+func TestSiteBuilder_WriteChangedToPath(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    options := WriteOptions{}
+
+    // First build. Everything is new.
+
+    sb := getIncrementalTestSite(tempPath, true)
+
+    report, err := sb.WriteChangedToPath(options)
+    log.PanicIf(err)
+
+    checkReport(t, report, "A=[child1 child2 childChild1 index] U=[] N=[] D=[]")
+
+    _, err = os.Stat(path.Join(tempPath, ManifestFilename))
+    log.PanicIf(err)
+
+    // Nothing changed.
+
+    sb = getIncrementalTestSite(tempPath, true)
+
+    report, err = sb.WriteChangedToPath(options)
+    log.PanicIf(err)
+
+    checkReport(t, report, "A=[] U=[] N=[child1 child2 childChild1 index] D=[]")
+
+    // Change a leaf.
+
+    sb = getIncrementalTestSite(tempPath, true)
+
+    childChildNode1 := sb.Root().Children[0].Children[0]
+
+    iw := NewImageWidget("new image", NewLocalResourceLocator("/some/image/path"), 0, 0)
+
+    err = childChildNode1.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    report, err = sb.WriteChangedToPath(options)
+    log.PanicIf(err)
+
+    checkReport(t, report, "A=[] U=[childChild1] N=[child1 child2 index] D=[]")
+
+    actual, err := ioutil.ReadFile(path.Join(tempPath, "childChild1.html"))
+    log.PanicIf(err)
+
+    expected := "<header>ChildChild1</header>\n<widget>new image | file:///some/image/path</widget>\n<footer>ChildChild1</footer>\n"
+    if string(actual) != expected {
+        t.Fatalf("Updated page not correct:\n%s", actual)
+    }
+
+    // Remove a node. Its page is deleted and its parent's child list changed.
+
+    sb = getIncrementalTestSite(tempPath, false)
+
+    err = sb.Root().Children[0].Children[0].Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    report, err = sb.WriteChangedToPath(options)
+    log.PanicIf(err)
+
+    checkReport(t, report, "A=[] U=[index] N=[child1 childChild1] D=[child2]")
+
+    _, err = os.Stat(path.Join(tempPath, "child2.html"))
+    if os.IsNotExist(err) == false {
+        t.Fatalf("Page of removed node was not deleted.")
+    }
+}
+
+func TestSiteBuilder_WriteChangedToPath_MissingOutput(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    options := WriteOptions{}
+
+    sb := getIncrementalTestSite(tempPath, true)
+
+    _, err = sb.WriteChangedToPath(options)
+    log.PanicIf(err)
+
+    err = os.Remove(path.Join(tempPath, "child2.html"))
+    log.PanicIf(err)
+
+    report, err := sb.WriteChangedToPath(options)
+    log.PanicIf(err)
+
+    checkReport(t, report, "A=[] U=[child2] N=[child1 childChild1 index] D=[]")
+
+    _, err = os.Stat(path.Join(tempPath, "child2.html"))
+    log.PanicIf(err)
+}
+
+func TestSiteBuilder_WriteChangedToPath_Moved(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    options := WriteOptions{}
+
+    sb := getIncrementalTestSite(tempPath, true)
+
+    _, err = sb.WriteChangedToPath(options)
+    log.PanicIf(err)
+
+    // child2 moves, and child1 is now written where child2 was.
+
+    sb = getIncrementalTestSite(tempPath, true)
+
+    paths := map[string]string{
+        "child1": "child2.html",
+        "child2": "moved/child2.html",
+    }
+
+    sb.Context().SetOutputPathStrategy(NewMappedOutputPathStrategy(paths, NewFlatOutputPathStrategy("%s.html")))
+
+    report, err := sb.WriteChangedToPath(options)
+    log.PanicIf(err)
+
+    checkReport(t, report, "A=[] U=[child1 child2] N=[childChild1 index] D=[]")
+
+    if _, err := os.Stat(path.Join(tempPath, "child1.html")); os.IsNotExist(err) == false {
+        t.Fatalf("Old file of moved page was not deleted.")
+    }
+
+    actual := readOutputFile(t, tempPath, "child2.html")
+    if actual != "<header>Child1</header>\n<footer>Child1</footer>\n" {
+        t.Fatalf("File that is written in place of a moved page should be kept:\n%s", actual)
+    }
+
+    _, err = os.Stat(path.Join(tempPath, "moved/child2.html"))
+    log.PanicIf(err)
+}
+
+func TestSiteBuilder_WriteChangedToPath_Failed(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    options := WriteOptions{}
+
+    sb := getIncrementalTestSite(tempPath, true)
+
+    _, err = sb.WriteChangedToPath(options)
+    log.PanicIf(err)
+
+    // child2 now fails. Its last good output and entry are kept.
+
+    sb = getIncrementalTestSite(tempPath, true)
+
+    iw := NewImageWidget("image", NewSitePageLocalResourceLocator(sb, "missing"), 0, 0)
+
+    err = sb.Root().Children[1].Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    report, err := sb.WriteChangedToPath(options)
+    if _, ok := err.(*WriteError); ok != true {
+        t.Fatalf("Expected a WriteError: [%v]", err)
+    }
+
+    checkReport(t, report, "A=[] U=[child2] N=[child1 childChild1 index] D=[]")
+
+    _, err = os.Stat(path.Join(tempPath, "child2.html"))
+    log.PanicIf(err)
+
+    // Once fixed, the page is updated rather than added again.
+
+    sb = getIncrementalTestSite(tempPath, true)
+
+    iw = NewImageWidget("image", NewSitePageLocalResourceLocator(sb, "child1"), 0, 0)
+
+    err = sb.Root().Children[1].Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    report, err = sb.WriteChangedToPath(options)
+    log.PanicIf(err)
+
+    checkReport(t, report, "A=[] U=[child2] N=[child1 childChild1 index] D=[]")
+}
+
+func TestSiteBuilder_WriteChangedToPath_ResourceChanged(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sourcePath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(sourcePath)
+
+    assetFilepath := writeTestAsset(sourcePath, "image.png")
+
+    build := func() BuildReport {
+        sb := getIncrementalTestSite(tempPath, true)
+
+        iw := NewImageWidget("image", NewLocalResourceLocator(assetFilepath), 0, 0)
+
+        err := sb.Root().Children[1].Builder().AddContentImage(iw)
+        log.PanicIf(err)
+
+        report, err := sb.WriteChangedToPath(WriteOptions{})
+        log.PanicIf(err)
+
+        return report
+    }
+
+    build()
+
+    err = ioutil.WriteFile(assetFilepath, []byte{1, 2, 3, 4}, 0644)
+    log.PanicIf(err)
+
+    report := build()
+
+    checkReport(t, report, "A=[] U=[child2] N=[child1 childChild1 index] D=[]")
+}
+
+func TestSiteNode_contentHash(t *testing.T) {
+    sb1 := getIncrementalTestSite("", true)
+    sb2 := getIncrementalTestSite("", true)
+
+    hash1, err := sb1.Root().contentHash()
+    log.PanicIf(err)
+
+    hash2, err := sb2.Root().contentHash()
+    log.PanicIf(err)
+
+    if hash1 != hash2 {
+        t.Fatalf("Identical nodes should have identical hashes.")
+    }
+
+    sb2.Root().Children[1].PageTitle = "Renamed"
+
+    hash2, err = sb2.Root().contentHash()
+    log.PanicIf(err)
+
+    if hash1 == hash2 {
+        t.Fatalf("Renaming a child should change the parent's hash.")
+    }
+}
+
+func TestSiteNode_contentHash_SequenceNavigation(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    childNode1 := sb.Root().Children[0]
+
+    err := childNode1.Builder().AddSequenceNavigation(NewSequenceNavigationWidget(false))
+    log.PanicIf(err)
+
+    hash1, err := childNode1.contentHash()
+    log.PanicIf(err)
+
+    sb.Root().Children[1].PageTitle = "Renamed"
+
+    hash2, err := childNode1.contentHash()
+    log.PanicIf(err)
+
+    if hash1 == hash2 {
+        t.Fatalf("Renaming a sibling should change the hash of a page that navigates to it.")
+    }
+}
+
+func TestSiteNode_contentHash_Breadcrumb(t *testing.T) {
+    sb := getIncrementalTestSite("", true)
+
+    childChildNode1, found := sb.Node("childChild1")
+    if found != true {
+        t.Fatalf("Node not found.")
+    }
+
+    err := childChildNode1.Builder().AddBreadcrumb(NewBreadcrumbWidget())
+    log.PanicIf(err)
+
+    hash1, err := childChildNode1.contentHash()
+    log.PanicIf(err)
+
+    sb.Root().Children[0].PageTitle = "Renamed"
+
+    hash2, err := childChildNode1.contentHash()
+    log.PanicIf(err)
+
+    if hash1 == hash2 {
+        t.Fatalf("Renaming a parent should change the hash of a page with a breadcrumb.")
+    }
+
+    sb.Root().PageTitle = "Renamed"
+
+    hash3, err := childChildNode1.contentHash()
+    log.PanicIf(err)
+
+    if hash3 == hash2 {
+        t.Fatalf("Renaming the root should change the hash of a page with a breadcrumb.")
+    }
+}
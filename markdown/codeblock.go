@@ -0,0 +1,158 @@
+package markdowndialect
+
+import (
+    "fmt"
+    "io"
+    "regexp"
+    "strconv"
+    "strings"
+
+    "github.com/dsoprea/go-logging"
+    "gopkg.in/russross/blackfriday.v2"
+
+    "github.com/dsoprea/go-static-site-builder"
+    "github.com/dsoprea/go-static-site-builder/highlight"
+)
+
+const (
+    // plainLanguage is written as the language of code blocks that do not
+    // have one so that the options that follow are not taken as the
+    // language.
+    plainLanguage = "text"
+
+    lineNumbersOption      = "linenos"
+    highlightedLinesOption = "hl_lines="
+)
+
+var (
+    // languageRe matches the language names that can be written in the info
+    // string of a fenced code block without being escaped.
+    languageRe = regexp.MustCompile(`^[A-Za-z0-9_+#.-]+$`)
+)
+
+// CodeBlockToMarkdown writes the code as a fenced code block. The code is read
+// from the widget's source if it has one. The info string has the language
+// followed by the line-number and highlighting options (e.g.
+// "{go linenos hl_lines=2-3,5}"), which are applied when the block is
+// highlighted as part of the conversion to HTML.
+func CodeBlockToMarkdown(sn *sitebuilder.SiteNode, cbw sitebuilder.CodeBlockWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    code, err := cbw.ReadCode(sn)
+    log.PanicIf(err)
+
+    code = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(code)
+    if strings.HasSuffix(code, "\n") == false {
+        code += "\n"
+    }
+
+    // The closing fence must match the opening fence exactly, so a fence that
+    // is longer than any run of backticks can not be closed by the code.
+    fence := strings.Repeat("`", longestRun(code, '`')+1)
+    if len(fence) < 3 {
+        fence = "```"
+    }
+
+    _, err = fmt.Fprintf(w, "%s{%s}\n%s%s\n\n", fence, codeBlockInfo(cbw), code, fence)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// codeBlockInfo returns the info string that describes the code block.
+func codeBlockInfo(cbw sitebuilder.CodeBlockWidget) string {
+    parts := make([]string, 0)
+
+    if languageRe.MatchString(cbw.Language) == true {
+        parts = append(parts, cbw.Language)
+    } else {
+        parts = append(parts, plainLanguage)
+    }
+
+    if cbw.LineNumbers == true {
+        parts = append(parts, lineNumbersOption)
+    }
+
+    if len(cbw.HighlightedLines) > 0 {
+        ranges := make([]string, len(cbw.HighlightedLines))
+        for i, lr := range cbw.HighlightedLines {
+            ranges[i] = lr.String()
+        }
+
+        parts = append(parts, highlightedLinesOption+strings.Join(ranges, ","))
+    }
+
+    return strings.Join(parts, " ")
+}
+
+// parseCodeBlockInfo returns a code block with the language and options of
+// the given info string. Options that are not understood are ignored.
+func parseCodeBlockInfo(info string) (cbw sitebuilder.CodeBlockWidget) {
+    fields := strings.Fields(info)
+    if len(fields) == 0 {
+        return cbw
+    }
+
+    cbw.Language = fields[0]
+
+    for _, field := range fields[1:] {
+        if field == lineNumbersOption {
+            cbw.LineNumbers = true
+        } else if strings.HasPrefix(field, highlightedLinesOption) == true {
+            for _, phrase := range strings.Split(strings.TrimPrefix(field, highlightedLinesOption), ",") {
+                if lr, ok := parseLineRange(phrase); ok == true {
+                    cbw.HighlightedLines = append(cbw.HighlightedLines, lr)
+                }
+            }
+        }
+    }
+
+    return cbw
+}
+
+// parseLineRange parses a single line ("5") or a range of lines ("2-3").
+func parseLineRange(phrase string) (lr sitebuilder.LineRange, ok bool) {
+    parts := strings.SplitN(phrase, "-", 2)
+
+    start, err := strconv.Atoi(parts[0])
+    if err != nil {
+        return lr, false
+    }
+
+    end := start
+    if len(parts) == 2 {
+        end, err = strconv.Atoi(parts[1])
+        if err != nil {
+            return lr, false
+        }
+    }
+
+    if start < 1 || end < start {
+        return lr, false
+    }
+
+    return sitebuilder.NewLineRange(start, end), true
+}
+
+// highlightingRenderer renders fenced code blocks with syntax highlighting
+// and everything else as the HTML renderer does.
+type highlightingRenderer struct {
+    *blackfriday.HTMLRenderer
+}
+
+func (hr highlightingRenderer) RenderNode(w io.Writer, node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+    if node.Type != blackfriday.CodeBlock || node.IsFenced == false {
+        return hr.HTMLRenderer.RenderNode(w, node, entering)
+    }
+
+    cbw := parseCodeBlockInfo(string(node.Info))
+
+    err := highlight.WriteHtml(w, cbw, string(node.Literal))
+    log.PanicIf(err)
+
+    return blackfriday.GoToNext
+}
@@ -0,0 +1,130 @@
+package markdowndialect
+
+import (
+    "strings"
+    "unicode"
+
+    "html"
+)
+
+const (
+    // escapedCharacters are the characters that have a meaning to the
+    // Markdown parser and that it allows to be escaped with a backslash.
+    // Since "<" is always escaped, ">" only has a meaning at the start of a
+    // line (as a blockquote) and is handled separately.
+    escapedCharacters = "\\`*_{}[]()#+-.!:|&<~"
+)
+
+var (
+    // uriReplacer encodes the characters that would end a link destination
+    // early, or a table cell.
+    uriReplacer = strings.NewReplacer(
+        " ", "%20",
+        "(", "%28",
+        ")", "%29",
+        "<", "%3C",
+        ">", "%3E",
+        "\"", "%22",
+        "'", "%27",
+        "\\", "%5C",
+        "|", "%7C",
+    )
+
+    // attributeReplacer escapes a pipe, which would end a table cell even
+    // within inline HTML.
+    attributeReplacer = strings.NewReplacer(
+        "|", "&#124;",
+    )
+)
+
+// sanitizeText folds line breaks and tabs into spaces so that text can not
+// start a new block (e.g. a list or a heading), drops all other control
+// characters, and replaces invalid UTF-8 with the replacement character.
+func sanitizeText(text string) string {
+    b := new(strings.Builder)
+    for _, r := range text {
+        if r == '\n' || r == '\r' || r == '\t' {
+            b.WriteRune(' ')
+        } else if unicode.IsControl(r) == false {
+            b.WriteRune(r)
+        }
+    }
+
+    return b.String()
+}
+
+// EscapeText escapes the given text so that it is rendered literally rather
+// than being interpreted as Markdown or HTML. The text may start a line.
+func EscapeText(text string) string {
+    return escapeText(text, true)
+}
+
+// escapeText escapes the given text. If `lineStart` is false, the text is
+// known to follow other content on the same line.
+func escapeText(text string, lineStart bool) string {
+    text = sanitizeText(text)
+
+    b := new(strings.Builder)
+    for i, r := range text {
+        if strings.ContainsRune(escapedCharacters, r) == true || (r == '>' && lineStart == true && strings.TrimSpace(text[:i]) == "") {
+            b.WriteRune('\\')
+        }
+
+        b.WriteRune(r)
+    }
+
+    return b.String()
+}
+
+// EscapeAttribute escapes the given text for use as an attribute value of
+// inline HTML.
+func EscapeAttribute(text string) string {
+    return attributeReplacer.Replace(html.EscapeString(sanitizeText(text)))
+}
+
+// escapeUri makes the given URI safe to use as a link destination.
+func escapeUri(uri string) string {
+    return uriReplacer.Replace(sanitizeText(uri))
+}
+
+// titleIsSafe returns whether the text can be used as the title of a link or
+// image. Titles can not be escaped, so those that contain a backslash or a
+// closing parenthesis would be cut short or shown with the backslashes.
+func titleIsSafe(text string) bool {
+    return strings.ContainsAny(text, "\\)") == false
+}
+
+// codeSpan returns the text as a code span. Code spans are not escaped, so
+// the fence is made longer than any run of backticks in the text. The parser
+// trims the text, so text that is only whitespace is returned as-is.
+func codeSpan(text string) string {
+    text = sanitizeText(text)
+
+    if strings.TrimSpace(text) == "" {
+        return text
+    }
+
+    fence := strings.Repeat("`", longestRun(text, '`')+1)
+
+    // The parser trims the padding.
+    return fence + " " + text + " " + fence
+}
+
+// longestRun returns the length of the longest run of the given character in
+// the text.
+func longestRun(text string, c rune) int {
+    longest := 0
+    current := 0
+    for _, r := range text {
+        if r == c {
+            current++
+            if current > longest {
+                longest = current
+            }
+        } else {
+            current = 0
+        }
+    }
+
+    return longest
+}
@@ -0,0 +1,272 @@
+package markdowndialect
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+    "unicode"
+
+    "github.com/dsoprea/go-logging"
+    "golang.org/x/net/html"
+
+    "github.com/dsoprea/go-static-site-builder"
+)
+
+var (
+    escapeSeeds = []string{
+        "",
+        "plain text",
+        "*star* _under_ **strong** __strong__ ~~strike~~",
+        "<script>alert(1)</script>",
+        "<b onclick=\"x\">bold</b>",
+        "# heading",
+        "C#",
+        "C# #",
+        "text {#id}",
+        "[text](uri) ![alt](src \"title\")",
+        "[ref]: http://example.com",
+        "a\\",
+        "\\*",
+        "\\\\*",
+        "`code` ``code``",
+        "1. item",
+        "- item",
+        "+ item",
+        "> quote",
+        "   > indented quote",
+        "    indented code",
+        "&amp; &lt; &#34; &",
+        "\"double\" 'single'",
+        "--- ... -- (c) (tm) 1/2 3/4",
+        "http://example.com www.example.com someone@example.com",
+        "| a | b |",
+        "tab\there",
+        "line\nbreak\r\nand\rreturns",
+        "trailing spaces  \nhard break",
+        "nul\x00byte",
+        "invalid \xff utf-8",
+        "^[footnote]",
+        "===",
+        "ünïcödé ✓ 漢字",
+    }
+)
+
+// expectedText returns the text as it is expected to be shown: control
+// characters are dropped, invalid UTF-8 is replaced, and whitespace is
+// collapsed as it would be by a browser.
+func expectedText(s string) string {
+    b := new(strings.Builder)
+    for _, r := range s {
+        if r == '\n' || r == '\r' || r == '\t' || unicode.IsControl(r) == false {
+            b.WriteRune(r)
+        }
+    }
+
+    return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// renderedElements converts the Markdown to HTML and returns every element in
+// the body, in document order.
+func renderedElements(markdown []byte) (elements []*html.Node) {
+    doc, err := html.Parse(bytes.NewReader(markdownToHtml(markdown)))
+    log.PanicIf(err)
+
+    elements = make([]*html.Node, 0)
+
+    var walk func(n *html.Node)
+    walk = func(n *html.Node) {
+        if n.Type == html.ElementNode && n.Data != "html" && n.Data != "head" && n.Data != "body" {
+            elements = append(elements, n)
+        }
+
+        for child := n.FirstChild; child != nil; child = child.NextSibling {
+            walk(child)
+        }
+    }
+
+    walk(doc)
+
+    return elements
+}
+
+// textContent returns the collapsed text of the node and its descendants.
+func textContent(n *html.Node) string {
+    b := new(strings.Builder)
+
+    var walk func(n *html.Node)
+    walk = func(n *html.Node) {
+        if n.Type == html.TextNode {
+            b.WriteString(n.Data)
+        }
+
+        for child := n.FirstChild; child != nil; child = child.NextSibling {
+            walk(child)
+        }
+    }
+
+    walk(n)
+
+    return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func attribute(n *html.Node, key string) string {
+    for _, attr := range n.Attr {
+        if attr.Key == key {
+            return attr.Val
+        }
+    }
+
+    return ""
+}
+
+// checkElements fails unless exactly the given elements were rendered.
+func checkElements(t *testing.T, s string, markdown []byte, elements []*html.Node, names ...string) {
+    actual := make([]string, len(elements))
+    for i, n := range elements {
+        actual[i] = n.Data
+    }
+
+    if strings.Join(actual, ",") != strings.Join(names, ",") {
+        t.Fatalf("Elements not correct for [%q]: %v\nMARKDOWN:\n%s", s, actual, markdown)
+    }
+}
+
+func FuzzEscapeText_Paragraph(f *testing.F) {
+    for _, seed := range escapeSeeds {
+        f.Add(seed)
+    }
+
+    f.Fuzz(func(t *testing.T, s string) {
+        expected := expectedText(s)
+        if expected == "" {
+            return
+        }
+
+        pw := sitebuilder.NewParagraphWidget(
+            sitebuilder.NewPlainSpan(s),
+            sitebuilder.NewEmphasisSpan(s),
+            sitebuilder.NewStrongSpan(s),
+            sitebuilder.NewCodeSpan(s),
+        )
+
+        b := new(bytes.Buffer)
+
+        err := ParagraphToMarkdown(nil, pw, b)
+        log.PanicIf(err)
+
+        elements := renderedElements(b.Bytes())
+        checkElements(t, s, b.Bytes(), elements, "p", "em", "strong", "code")
+
+        for _, n := range elements[1:] {
+            if actual := textContent(n); actual != expected {
+                t.Fatalf("Text of [%s] not correct: [%q] != [%q]\nMARKDOWN:\n%s", n.Data, actual, expected, b.Bytes())
+            }
+        }
+    })
+}
+
+func FuzzHeadingToMarkdown(f *testing.F) {
+    for _, seed := range escapeSeeds {
+        f.Add(seed)
+    }
+
+    f.Fuzz(func(t *testing.T, s string) {
+        expected := expectedText(s)
+        if expected == "" {
+            return
+        }
+
+        b := new(bytes.Buffer)
+
+        err := HeadingToMarkdown(sitebuilder.NewHeadingWidget(2, s), b)
+        log.PanicIf(err)
+
+        elements := renderedElements(b.Bytes())
+        checkElements(t, s, b.Bytes(), elements, "h2")
+
+        if actual := textContent(elements[0]); actual != expected {
+            t.Fatalf("Heading text not correct: [%q] != [%q]\nMARKDOWN:\n%s", actual, expected, b.Bytes())
+        }
+    })
+}
+
+func FuzzLinkWidgetToMarkdown(f *testing.F) {
+    for _, seed := range escapeSeeds {
+        f.Add(seed)
+    }
+
+    f.Fuzz(func(t *testing.T, s string) {
+        expected := expectedText(s)
+        if expected == "" {
+            return
+        }
+
+        lw := sitebuilder.NewLinkWidget(s, sitebuilder.NewLocalResourceLocator("/some (odd) \"path\""))
+
+        b := new(bytes.Buffer)
+
+        err := LinkWidgetToMarkdown(lw, b)
+        log.PanicIf(err)
+
+        elements := renderedElements(b.Bytes())
+        checkElements(t, s, b.Bytes(), elements, "p", "a")
+
+        if actual := textContent(elements[1]); actual != expected {
+            t.Fatalf("Link text not correct: [%q] != [%q]\nMARKDOWN:\n%s", actual, expected, b.Bytes())
+        } else if href := attribute(elements[1], "href"); href != "file:///some%20%28odd%29%20%22path%22" {
+            t.Fatalf("Link URI not correct: [%s]", href)
+        }
+    })
+}
+
+func FuzzImageWidgetToMarkdown(f *testing.F) {
+    for _, seed := range escapeSeeds {
+        f.Add(seed)
+    }
+
+    f.Fuzz(func(t *testing.T, s string) {
+        expected := expectedText(s)
+        if expected == "" {
+            return
+        }
+
+        lrl := sitebuilder.NewLocalResourceLocator("/some/image")
+
+        for _, width := range []int{0, 100} {
+            b := new(bytes.Buffer)
+
+            err := ImageWidgetToMarkdown(sitebuilder.NewImageWidget(s, lrl, width, 0), b)
+            log.PanicIf(err)
+
+            elements := renderedElements(b.Bytes())
+
+            if width == 0 {
+                checkElements(t, s, b.Bytes(), elements, "p", "img")
+            } else {
+                checkElements(t, s, b.Bytes(), elements, "p", "img", "br", "br")
+            }
+
+            img := elements[1]
+
+            if actual := strings.Join(strings.Fields(attribute(img, "alt")), " "); actual != expected {
+                t.Fatalf("Alt text not correct: [%q] != [%q]\nMARKDOWN:\n%s", actual, expected, b.Bytes())
+            } else if title := attribute(img, "title"); title != "" && strings.Join(strings.Fields(title), " ") != expected {
+                t.Fatalf("Title not correct: [%q] != [%q]\nMARKDOWN:\n%s", title, expected, b.Bytes())
+            }
+        }
+    })
+}
+
+func TestEscapeText(t *testing.T) {
+    actual := EscapeText("> 1. *a* <b>")
+    expected := "\\> 1\\. \\*a\\* \\<b>"
+
+    if actual != expected {
+        t.Fatalf("Escaped text not correct: [%s] != [%s]", actual, expected)
+    }
+
+    // Only a ">" that starts a line has a meaning.
+    if actual := escapeText("> a > b", false); actual != "> a > b" {
+        t.Fatalf("Escaped inline text not correct: [%s]", actual)
+    }
+}
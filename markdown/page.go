@@ -11,6 +11,33 @@ import (
     "github.com/dsoprea/go-static-site-builder"
 )
 
+var (
+    // htmlRendererFlags are the defaults less the typographic substitutions
+    // (e.g. of quotes and dashes). All of the Markdown is generated from text
+    // that is meant to be shown literally.
+    htmlRendererFlags = blackfriday.UseXHTML
+
+    // markdownExtensions are the defaults. Tables are named explicitly since
+    // the table widget is written as a GFM table.
+    markdownExtensions = blackfriday.CommonExtensions | blackfriday.Tables
+
+    // supportedWidgetTypes are the widget types that renderStatment handles.
+    supportedWidgetTypes = map[sitebuilder.WidgetType]struct{}{
+        sitebuilder.Heading:            {},
+        sitebuilder.ContentImage:       {},
+        sitebuilder.HorizontalNavbar:   {},
+        sitebuilder.VerticalNavbar:     {},
+        sitebuilder.Link:               {},
+        sitebuilder.ChildNavbar:        {},
+        sitebuilder.Breadcrumb:         {},
+        sitebuilder.SequenceNavigation: {},
+        sitebuilder.Paragraph:          {},
+        sitebuilder.Table:              {},
+        sitebuilder.CodeBlock:          {},
+        sitebuilder.Gallery:            {},
+    }
+)
+
 type MarkdownDialect struct {
 }
 
@@ -18,6 +45,13 @@ func NewMarkdownDialect() (md *MarkdownDialect) {
     return &MarkdownDialect{}
 }
 
+// SupportsWidgetType returns whether statements of the given type can be
+// rendered.
+func (md *MarkdownDialect) SupportsWidgetType(wt sitebuilder.WidgetType) bool {
+    _, found := supportedWidgetTypes[wt]
+    return found
+}
+
 // RenderIntermediate produces dialect-specific content that can be passed to
 // RenderHtml.
 func (md *MarkdownDialect) RenderIntermediate(sn *sitebuilder.SiteNode) (err error) {
@@ -30,12 +64,14 @@ func (md *MarkdownDialect) RenderIntermediate(sn *sitebuilder.SiteNode) (err err
     // TODO(dustin): !! This is an overflow concern, especially with large embedded images.
     b := new(bytes.Buffer)
 
-    _, err = fmt.Fprintf(b, "# %s\n\n", sn.PageTitle)
+    _, err = fmt.Fprintf(b, "# %s\n\n", escapeText(sn.PageTitle, false))
     log.PanicIf(err)
 
-    for _, ps := range sn.Content.Statements {
-        err := md.renderStatment(b, ps)
-        log.PanicIf(err)
+    for i, ps := range sn.Content.Statements {
+        err := md.renderStatment(sn, b, ps)
+        if err != nil {
+            log.Panic(sitebuilder.NewStatementError(sn.PageId, i, err))
+        }
     }
 
     _, err = fmt.Fprintf(b, "\n")
@@ -45,15 +81,12 @@ func (md *MarkdownDialect) RenderIntermediate(sn *sitebuilder.SiteNode) (err err
 
     sn.SetIntermediateOutput(intermediateOutput)
 
-    for _, childNode := range sn.Children {
-        err := md.RenderIntermediate(childNode)
-        log.PanicIf(err)
-    }
-
     return nil
 }
 
-func (md *MarkdownDialect) renderStatment(w io.Writer, ps sitebuilder.PageStatement) (err error) {
+// renderStatment renders one statement of the given node. The node is needed
+// by widgets that are derived from the node's position in the tree.
+func (md *MarkdownDialect) renderStatment(sn *sitebuilder.SiteNode, w io.Writer, ps sitebuilder.PageStatement) (err error) {
     defer func() {
         if state := recover(); state != nil {
             err = log.Wrap(state.(error))
@@ -70,25 +103,81 @@ func (md *MarkdownDialect) renderStatment(w io.Writer, ps sitebuilder.PageStatem
     case sitebuilder.ContentImage:
         iw := ps.StatementMetadata["image"].(sitebuilder.ImageWidget)
 
-        err = ImageWidgetToMarkdown(iw, w)
+        err = ImageWidgetToMarkdownFrom(sn, iw, w)
         log.PanicIf(err)
 
     case sitebuilder.HorizontalNavbar:
         nw := ps.StatementMetadata["horizontal_navbar"].(sitebuilder.NavbarWidget)
 
-        err := InlineLinkListToMarkdown(nw.Items, w)
+        err := InlineLinkListToMarkdownFrom(sn, nw.Items, w)
         log.PanicIf(err)
 
     case sitebuilder.VerticalNavbar:
         nw := ps.StatementMetadata["vertical_navbar"].(sitebuilder.NavbarWidget)
 
-        err := BulletedLinkListToMarkdown(nw.Items, w)
+        err := BulletedLinkListToMarkdownFrom(sn, nw.Items, w)
         log.PanicIf(err)
 
     case sitebuilder.Link:
         lw := ps.StatementMetadata["link"].(sitebuilder.LinkWidget)
 
-        err = LinkWidgetToMarkdown(lw, w)
+        err = LinkWidgetToMarkdownFrom(sn, lw, w)
+        log.PanicIf(err)
+
+    case sitebuilder.ChildNavbar:
+        cnw := ps.StatementMetadata["child_navbar"].(sitebuilder.ChildNavbarWidget)
+
+        items := cnw.Items(sn)
+        if len(items) == 0 {
+            break
+        }
+
+        if cnw.Style == sitebuilder.VerticalNavbarStyle {
+            err := BulletedLinkListToMarkdownFrom(sn, items, w)
+            log.PanicIf(err)
+        } else {
+            err := InlineLinkListToMarkdownFrom(sn, items, w)
+            log.PanicIf(err)
+        }
+
+    case sitebuilder.Breadcrumb:
+        bw := ps.StatementMetadata["breadcrumb"].(sitebuilder.BreadcrumbWidget)
+
+        items, currentText := bw.Items(sn)
+
+        err := BreadcrumbToMarkdown(sn, items, currentText, bw.Separator, w)
+        log.PanicIf(err)
+
+    case sitebuilder.SequenceNavigation:
+        snw := ps.StatementMetadata["sequence_navigation"].(sitebuilder.SequenceNavigationWidget)
+
+        sl := snw.Links(sn)
+
+        err := SequenceNavigationToMarkdown(sn, snw, sl, w)
+        log.PanicIf(err)
+
+    case sitebuilder.Paragraph:
+        pw := ps.StatementMetadata["paragraph"].(sitebuilder.ParagraphWidget)
+
+        err := ParagraphToMarkdown(sn, pw, w)
+        log.PanicIf(err)
+
+    case sitebuilder.Table:
+        tw := ps.StatementMetadata["table"].(sitebuilder.TableWidget)
+
+        err := TableToMarkdown(sn, tw, w)
+        log.PanicIf(err)
+
+    case sitebuilder.CodeBlock:
+        cbw := ps.StatementMetadata["code_block"].(sitebuilder.CodeBlockWidget)
+
+        err := CodeBlockToMarkdown(sn, cbw, w)
+        log.PanicIf(err)
+
+    case sitebuilder.Gallery:
+        gw := ps.StatementMetadata["gallery"].(sitebuilder.GalleryWidget)
+
+        err := GalleryToMarkdown(sn, gw, w)
         log.PanicIf(err)
 
     default:
@@ -106,10 +195,23 @@ func (md *MarkdownDialect) RenderHtml(sn *sitebuilder.SiteNode) (err error) {
         }
     }()
 
-    intermediateOutput := sn.IntermediateOutput()
-    output := blackfriday.Run(intermediateOutput)
+    output := markdownToHtml(sn.IntermediateOutput())
 
     sn.SetFinalOutput(output)
 
     return nil
 }
+
+// markdownToHtml converts the intermediate content to HTML. Fenced code blocks
+// are highlighted.
+func markdownToHtml(intermediateOutput []byte) []byte {
+    parameters := blackfriday.HTMLRendererParameters{
+        Flags: htmlRendererFlags,
+    }
+
+    renderer := highlightingRenderer{
+        HTMLRenderer: blackfriday.NewHTMLRenderer(parameters),
+    }
+
+    return blackfriday.Run(intermediateOutput, blackfriday.WithExtensions(markdownExtensions), blackfriday.WithRenderer(renderer))
+}
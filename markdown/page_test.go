@@ -2,6 +2,7 @@ package markdowndialect
 
 import (
     "bytes"
+    "errors"
     "fmt"
     "os"
     "path"
@@ -159,7 +160,7 @@ func ExampleMarkdownDialect_RenderHtml() {
     // childChild1.html
     // ====================
     //
-    // <h1>Child&rsquo;s Child Page 1</h1>
+    // <h1>Child's Child Page 1</h1>
     //
     // <p><img src="file:///some/image/path" alt="image alt text 4" title="image alt text 4" /></p>
     //
@@ -271,7 +272,7 @@ func TestMarkdownDialect_renderStatement_Image(t *testing.T) {
 
     b := new(bytes.Buffer)
 
-    err = md.renderStatment(b, ps)
+    err = md.renderStatment(rootNode, b, ps)
     log.PanicIf(err)
 
     actual := b.String()
@@ -310,7 +311,7 @@ func TestMarkdownDialect_renderStatement_HorizontalNavbar(t *testing.T) {
 
     b := new(bytes.Buffer)
 
-    err = md.renderStatment(b, ps)
+    err = md.renderStatment(rootNode, b, ps)
     log.PanicIf(err)
 
     actual := b.String()
@@ -349,12 +350,12 @@ func TestMarkdownDialect_renderStatement_VerticalNavbar(t *testing.T) {
 
     ps1 := rootNode.Content.Statements[0]
 
-    err = md.renderStatment(b, ps1)
+    err = md.renderStatment(rootNode, b, ps1)
     log.PanicIf(err)
 
     ps2 := rootNode.Content.Statements[1]
 
-    err = md.renderStatment(b, ps2)
+    err = md.renderStatment(rootNode, b, ps2)
     log.PanicIf(err)
 
     actual := b.String()
@@ -393,7 +394,7 @@ func TestMarkdownDialect_renderStatement_Link(t *testing.T) {
 
     b := new(bytes.Buffer)
 
-    err = md.renderStatment(b, ps)
+    err = md.renderStatment(rootNode, b, ps)
     log.PanicIf(err)
 
     actual := b.String()
@@ -427,7 +428,7 @@ func TestMarkdownDialect_renderStatement_Heading(t *testing.T) {
 
     b := new(bytes.Buffer)
 
-    err = md.renderStatment(b, ps)
+    err = md.renderStatment(rootNode, b, ps)
     log.PanicIf(err)
 
     actual := b.String()
@@ -439,3 +440,183 @@ func TestMarkdownDialect_renderStatement_Heading(t *testing.T) {
 }
 
 // TODO(dustin): Also, test that we get an error with the link widget if not a valid page-ID.
+
+func TestMarkdownDialect_RenderIntermediate_ChildNavbar(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    md := NewMarkdownDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site title", md, sc)
+    rootNode := sb.Root()
+    pb := rootNode.Builder()
+
+    err := pb.AddChildNavbar(sitebuilder.HorizontalNavbarStyle)
+    log.PanicIf(err)
+
+    err = pb.AddChildNavbar(sitebuilder.VerticalNavbarStyle)
+    log.PanicIf(err)
+
+    // The children are added after the navbars.
+
+    _, err = rootNode.AddChildNode("child1", "Child Page 1")
+    log.PanicIf(err)
+
+    _, err = rootNode.AddChildNode("child2", "Child Page 2")
+    log.PanicIf(err)
+
+    err = md.RenderIntermediate(rootNode)
+    log.PanicIf(err)
+
+    actual := string(rootNode.IntermediateOutput())
+
+    expected := `# site title
+
+[Child Page 1](child1.html) [Child Page 2](child2.html) 
+
+- [Child Page 1](child1.html)
+- [Child Page 2](child2.html)
+
+
+`
+
+    if actual != expected {
+        fmt.Printf("ACTUAL:\n=====\n%s=====\n", actual)
+        fmt.Printf("EXPECTED:\n=====\n%s=====\n", expected)
+
+        t.Fatalf("Unexpected output.")
+    }
+}
+
+func TestMarkdownDialect_RenderIntermediate_ChildNavbar_NoChildren(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    md := NewMarkdownDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site title", md, sc)
+    rootNode := sb.Root()
+
+    err := rootNode.Builder().AddChildNavbar(sitebuilder.HorizontalNavbarStyle)
+    log.PanicIf(err)
+
+    err = md.RenderIntermediate(rootNode)
+    log.PanicIf(err)
+
+    actual := string(rootNode.IntermediateOutput())
+    expected := "# site title\n\n\n"
+
+    if actual != expected {
+        t.Fatalf("Unexpected output: [%s]", actual)
+    }
+}
+
+func TestMarkdownDialect_Render_Breadcrumb(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    md := NewMarkdownDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site title", md, sc)
+    rootNode := sb.Root()
+
+    sectionNode, err := rootNode.AddChildNode("section", "Section")
+    log.PanicIf(err)
+
+    pageNode, err := sectionNode.AddChildNode("page", "Page")
+    log.PanicIf(err)
+
+    bw := sitebuilder.NewBreadcrumbWidget()
+    bw.RootText = "Home"
+
+    err = pageNode.Builder().AddBreadcrumb(bw)
+    log.PanicIf(err)
+
+    err = pageNode.Render()
+    log.PanicIf(err)
+
+    actual := string(pageNode.IntermediateOutput())
+    expected := "# Page\n\n[Home](index.html) > [Section](section.html) > Page\n\n\n"
+
+    if actual != expected {
+        t.Fatalf("Unexpected intermediate output: [%s]", actual)
+    }
+
+    actual = string(pageNode.FinalOutput())
+    expected = "<h1>Page</h1>\n\n<p><a href=\"index.html\">Home</a> &gt; <a href=\"section.html\">Section</a> &gt; Page</p>\n"
+
+    if actual != expected {
+        t.Fatalf("Unexpected final output: [%s]", actual)
+    }
+}
+
+func TestMarkdownDialect_RenderIntermediate_Breadcrumb_DirectoryOutputPathStrategy(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    sc.SetOutputPathStrategy(sitebuilder.NewDirectoryOutputPathStrategy())
+    sc.SetPrettyUrls(true)
+
+    md := NewMarkdownDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site title", md, sc)
+    rootNode := sb.Root()
+
+    sectionNode, err := rootNode.AddChildNode("section", "Section")
+    log.PanicIf(err)
+
+    pageNode, err := sectionNode.AddChildNode("page", "Page")
+    log.PanicIf(err)
+
+    bw := sitebuilder.NewBreadcrumbWidget()
+    bw.RootText = "Home"
+
+    err = pageNode.Builder().AddBreadcrumb(bw)
+    log.PanicIf(err)
+
+    err = md.RenderIntermediate(pageNode)
+    log.PanicIf(err)
+
+    actual := string(pageNode.IntermediateOutput())
+    expected := "# Page\n\n[Home](../../) > [Section](../) > Page\n\n\n"
+
+    if actual != expected {
+        t.Fatalf("Unexpected intermediate output: [%s]", actual)
+    }
+}
+
+func TestMarkdownDialect_RenderIntermediate_StatementError(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    md := NewMarkdownDialect()
+
+    sb := sitebuilder.NewSiteBuilder("site title", md, sc)
+    rootNode := sb.Root()
+    pb := rootNode.Builder()
+
+    err := pb.AddHeading(sitebuilder.NewHeadingWidget(2, "heading"))
+    log.PanicIf(err)
+
+    lw := sitebuilder.NewLinkWidget("Missing", sitebuilder.NewSitePageLocalResourceLocator(sb, "missing"))
+
+    err = pb.AddLink(lw)
+    log.PanicIf(err)
+
+    err = md.RenderIntermediate(rootNode)
+    if err == nil {
+        t.Fatalf("Expected error.")
+    }
+
+    statementErrors := sitebuilder.StatementErrors(err)
+
+    if len(statementErrors) != 1 {
+        t.Fatalf("Expected one statement error: [%s]", err)
+    } else if se := statementErrors[0]; se.PageId != "index" || se.StatementIndex != 1 {
+        t.Fatalf("Statement error not correct: [%s]", se)
+    } else if errors.Is(se, sitebuilder.ErrPageNotFound) != true {
+        t.Fatalf("Expected missing page: [%s]", se)
+    }
+}
+
+func TestMarkdownDialect_SupportsWidgetType(t *testing.T) {
+    d := NewMarkdownDialect()
+
+    if d.SupportsWidgetType(sitebuilder.SequenceNavigation) != true {
+        t.Fatalf("Sequence navigation should be supported.")
+    } else if d.SupportsWidgetType(sitebuilder.WidgetType(0)) != false {
+        t.Fatalf("Unknown widget type should not be supported.")
+    }
+
+    var _ sitebuilder.WidgetTypeSupporter = d
+}
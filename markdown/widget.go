@@ -10,40 +10,133 @@ import (
     "github.com/dsoprea/go-static-site-builder"
 )
 
+// ImageWidgetToMarkdown writes the image with a URI that is relative to the
+// root of the site. See ImageWidgetToMarkdownFrom.
 func ImageWidgetToMarkdown(iw sitebuilder.ImageWidget, w io.Writer) (err error) {
-    uri := iw.Locator.Uri()
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
 
-    if iw.Width != 0 || iw.Height != 0 {
-        if iw.Width != 0 && iw.Height == 0 {
-            _, err = fmt.Fprintf(w, `<img src="%s" width="%d" alt="%s" />`, uri, iw.Width, iw.AltText)
-            log.PanicIf(err)
-        } else if iw.Width == 0 && iw.Height != 0 {
-            _, err = fmt.Fprintf(w, `<img src="%s" height="%d" alt="%s" />`, uri, iw.Height, iw.AltText)
-            log.PanicIf(err)
-        } else if iw.Width != 0 && iw.Height != 0 {
-            _, err = fmt.Fprintf(w, `<img src="%s" width="%d" height="%d" alt="%s" />`, uri, iw.Width, iw.Height, iw.AltText)
-            log.PanicIf(err)
+    err = ImageWidgetToMarkdownFrom(nil, iw, w)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// ImageWidgetToMarkdownFrom writes the image. The alt text is escaped. It is
+// also used as the title if it can be represented as one. Images with
+// dimensions, variants, or lazy loading are written as HTML. Dimensions that
+// were not given are read from the image. The URI is relative to the page
+// `sn`.
+func ImageWidgetToMarkdownFrom(sn *sitebuilder.SiteNode, iw sitebuilder.ImageWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    ri, err := iw.Resolve(sn)
+    log.PanicIf(err)
+
+    if ri.Width != 0 || ri.Height != 0 || ri.Srcset != "" || iw.LazyLoading == true {
+        attributes := fmt.Sprintf(`src="%s"`, EscapeAttribute(escapeUri(ri.Uri)))
+
+        if ri.Width != 0 {
+            attributes += fmt.Sprintf(` width="%d"`, ri.Width)
+        }
+
+        if ri.Height != 0 {
+            attributes += fmt.Sprintf(` height="%d"`, ri.Height)
+        }
+
+        attributes += fmt.Sprintf(` alt="%s"`, EscapeAttribute(iw.AltText))
+
+        if ri.Srcset != "" {
+            attributes += fmt.Sprintf(` srcset="%s" sizes="%s"`, EscapeAttribute(ri.Srcset), EscapeAttribute(ri.Sizes))
         }
 
-        _, err = fmt.Fprintf(w, "<br /><br />\n\n")
+        if iw.LazyLoading == true {
+            attributes += ` loading="lazy"`
+        }
+
+        _, err = fmt.Fprintf(w, "<img %s /><br /><br />\n\n", attributes)
         log.PanicIf(err)
     } else {
-        _, err = fmt.Fprintf(w, "![%s](%s \"%s\")\n\n", iw.AltText, uri, iw.AltText)
+        _, err = fmt.Fprintf(w, "%s\n\n", imageToMarkdown(iw.AltText, ri.Uri))
         log.PanicIf(err)
     }
 
     return nil
 }
 
+// imageToMarkdown returns the image without a size. The alt text is also used
+// as the title if it can be represented as one.
+func imageToMarkdown(altText, uri string) string {
+    altText = sanitizeText(altText)
+
+    // The alt text of a Markdown image is not unescaped, so brackets and
+    // backslashes (and pipes, within a table) can only be represented in
+    // HTML. Whether entities in the alt text and title are decoded differs
+    // between parser versions, so ampersands and angle brackets are also only
+    // represented in HTML.
+    if strings.ContainsAny(altText, "[]\\|&<") == true {
+        return fmt.Sprintf(`<img src="%s" alt="%s" />`, EscapeAttribute(escapeUri(uri)), EscapeAttribute(altText))
+    } else if altText != "" && titleIsSafe(altText) == true {
+        return fmt.Sprintf("![%s](%s \"%s\")", altText, escapeUri(uri), altText)
+    }
+
+    return fmt.Sprintf("![%s](%s)", altText, escapeUri(uri))
+}
+
+// LinkWidgetToMarkdown writes the link with a URI that is relative to the
+// root of the site. See LinkWidgetToMarkdownFrom.
 func LinkWidgetToMarkdown(lw sitebuilder.LinkWidget, w io.Writer) (err error) {
-    uri := lw.Locator.Uri()
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
 
-    _, err = fmt.Fprintf(w, "[%s](%s)", lw.Text, uri)
+    err = LinkWidgetToMarkdownFrom(nil, lw, w)
     log.PanicIf(err)
 
     return nil
 }
 
+// LinkWidgetToMarkdownFrom writes the link. The text is escaped. Links to
+// other pages are relative to the page `sn`.
+func LinkWidgetToMarkdownFrom(sn *sitebuilder.SiteNode, lw sitebuilder.LinkWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    uri, err := sitebuilder.CheckedResolveUri(lw.Locator, sn)
+    log.PanicIf(err)
+
+    _, err = w.Write([]byte(linkToMarkdown(lw.Text, uri)))
+    log.PanicIf(err)
+
+    return nil
+}
+
+// linkToMarkdown returns the link with the text escaped. Links without text
+// or whose text ends with a backslash can not be represented in Markdown, so
+// they are returned as HTML.
+func linkToMarkdown(text, uri string) string {
+    text = sanitizeText(text)
+
+    if text == "" || strings.HasSuffix(text, "\\") == true {
+        return fmt.Sprintf(`<a href="%s">%s</a>`, EscapeAttribute(escapeUri(uri)), escapeText(text, false))
+    }
+
+    return fmt.Sprintf("[%s](%s)", escapeText(text, false), escapeUri(uri))
+}
+
+// HeadingToMarkdown writes the heading. The text is escaped.
 func HeadingToMarkdown(h sitebuilder.HeadingWidget, w io.Writer) (err error) {
     defer func() {
         if state := recover(); state != nil {
@@ -53,7 +146,7 @@ func HeadingToMarkdown(h sitebuilder.HeadingWidget, w io.Writer) (err error) {
 
     prefix := strings.Repeat("#", h.Level)
 
-    _, err = fmt.Fprintf(w, "%s %s\n\n", prefix, h.Text)
+    _, err = fmt.Fprintf(w, "%s %s\n\n", prefix, escapeText(h.Text, false))
     log.PanicIf(err)
 
     return nil
@@ -85,6 +178,8 @@ func WriteDoubleNewline(w io.Writer) (err error) {
     return nil
 }
 
+// InlineLinkListToMarkdown writes the links on one line with URIs that are
+// relative to the root of the site.
 func InlineLinkListToMarkdown(items []sitebuilder.LinkWidget, w io.Writer) (err error) {
     defer func() {
         if state := recover(); state != nil {
@@ -92,8 +187,23 @@ func InlineLinkListToMarkdown(items []sitebuilder.LinkWidget, w io.Writer) (err
         }
     }()
 
+    err = InlineLinkListToMarkdownFrom(nil, items, w)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// InlineLinkListToMarkdownFrom writes the links on one line. Links to other
+// pages are relative to the page `sn`.
+func InlineLinkListToMarkdownFrom(sn *sitebuilder.SiteNode, items []sitebuilder.LinkWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
     for _, lw := range items {
-        err = LinkWidgetToMarkdown(lw, w)
+        err = LinkWidgetToMarkdownFrom(sn, lw, w)
         log.PanicIf(err)
 
         _, err = w.Write([]byte{' '})
@@ -106,6 +216,8 @@ func InlineLinkListToMarkdown(items []sitebuilder.LinkWidget, w io.Writer) (err
     return nil
 }
 
+// BulletedLinkListToMarkdown writes the links as a list with URIs that are
+// relative to the root of the site.
 func BulletedLinkListToMarkdown(items []sitebuilder.LinkWidget, w io.Writer) (err error) {
     defer func() {
         if state := recover(); state != nil {
@@ -113,11 +225,26 @@ func BulletedLinkListToMarkdown(items []sitebuilder.LinkWidget, w io.Writer) (er
         }
     }()
 
+    err = BulletedLinkListToMarkdownFrom(nil, items, w)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// BulletedLinkListToMarkdownFrom writes the links as a list. Links to other
+// pages are relative to the page `sn`.
+func BulletedLinkListToMarkdownFrom(sn *sitebuilder.SiteNode, items []sitebuilder.LinkWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
     for _, lw := range items {
         _, err = w.Write([]byte("- "))
         log.PanicIf(err)
 
-        err = LinkWidgetToMarkdown(lw, w)
+        err = LinkWidgetToMarkdownFrom(sn, lw, w)
         log.PanicIf(err)
 
         err = WriteNewline(w)
@@ -129,3 +256,267 @@ func BulletedLinkListToMarkdown(items []sitebuilder.LinkWidget, w io.Writer) (er
 
     return nil
 }
+
+// BreadcrumbToMarkdown writes the linked items followed by the unlinked text
+// of the current page, all separated by `separator`.
+func BreadcrumbToMarkdown(sn *sitebuilder.SiteNode, items []sitebuilder.LinkWidget, currentText, separator string, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    for _, lw := range items {
+        err = LinkWidgetToMarkdownFrom(sn, lw, w)
+        log.PanicIf(err)
+
+        _, err = w.Write([]byte(escapeText(separator, false)))
+        log.PanicIf(err)
+    }
+
+    _, err = w.Write([]byte(escapeText(currentText, len(items) == 0)))
+    log.PanicIf(err)
+
+    err = WriteDoubleNewline(w)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// SequenceNavigationToMarkdown writes the links that are present, each
+// preceded by its label. Nothing is written if there are no links.
+func SequenceNavigationToMarkdown(sn *sitebuilder.SiteNode, snw sitebuilder.SequenceNavigationWidget, sl sitebuilder.SequenceLinks, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    labels := []string{snw.PreviousLabel, snw.UpLabel, snw.NextLabel}
+    links := []*sitebuilder.LinkWidget{sl.Previous, sl.Up, sl.Next}
+
+    written := 0
+    for i, lw := range links {
+        if lw == nil {
+            continue
+        }
+
+        if written > 0 {
+            _, err = w.Write([]byte(" | "))
+            log.PanicIf(err)
+        }
+
+        _, err = fmt.Fprintf(w, "%s: ", escapeText(labels[i], written == 0))
+        log.PanicIf(err)
+
+        err = LinkWidgetToMarkdownFrom(sn, *lw, w)
+        log.PanicIf(err)
+
+        written++
+    }
+
+    if written > 0 {
+        err = WriteDoubleNewline(w)
+        log.PanicIf(err)
+    }
+
+    return nil
+}
+
+// ParagraphToMarkdown writes the spans of the paragraph. All text is escaped.
+// Links are relative to the page `sn`. Emphasis is written as inline HTML
+// since the Markdown delimiters are not recognized next to some characters
+// (e.g. within a word or after a backslash).
+func ParagraphToMarkdown(sn *sitebuilder.SiteNode, pw sitebuilder.ParagraphWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    b := new(strings.Builder)
+
+    for _, ts := range pw.Spans {
+        switch ts.Style {
+        case sitebuilder.PlainSpanStyle:
+            b.WriteString(escapeText(ts.Text, strings.TrimSpace(b.String()) == ""))
+        case sitebuilder.EmphasisSpanStyle:
+            fmt.Fprintf(b, "<em>%s</em>", escapeText(ts.Text, false))
+        case sitebuilder.StrongSpanStyle:
+            fmt.Fprintf(b, "<strong>%s</strong>", escapeText(ts.Text, false))
+        case sitebuilder.CodeSpanStyle:
+            if ts.Text != "" {
+                b.WriteString(codeSpan(ts.Text))
+            }
+        case sitebuilder.LinkSpanStyle:
+            uri, err := sitebuilder.CheckedResolveUri(ts.Locator, sn)
+            log.PanicIf(err)
+
+            b.WriteString(linkToMarkdown(ts.Text, uri))
+        default:
+            log.Panicf("span style not valid: (%d)", ts.Style)
+        }
+    }
+
+    // Leading indentation would make the paragraph a code block.
+    paragraph := strings.TrimLeft(b.String(), " \t")
+    if paragraph == "" {
+        return nil
+    }
+
+    _, err = fmt.Fprintf(w, "%s\n\n", paragraph)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// TableToMarkdown writes the table as a GFM table. The caption, if any, is
+// written as a paragraph before the table since Markdown has no captions. All
+// text is escaped. Links and images are relative to the page `sn`.
+func TableToMarkdown(sn *sitebuilder.SiteNode, tw sitebuilder.TableWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    err = tw.Validate()
+    log.PanicIf(err)
+
+    b := new(strings.Builder)
+
+    if caption := strings.TrimSpace(sanitizeText(tw.Caption)); caption != "" {
+        fmt.Fprintf(b, "%s\n\n", escapeText(caption, true))
+    }
+
+    headers := make([]string, len(tw.Columns))
+    delimiters := make([]string, len(tw.Columns))
+    for i, tc := range tw.Columns {
+        headers[i] = escapeText(tc.Header, false)
+
+        switch tc.Alignment {
+        case sitebuilder.LeftAlignment:
+            delimiters[i] = ":---"
+        case sitebuilder.CenterAlignment:
+            delimiters[i] = ":---:"
+        case sitebuilder.RightAlignment:
+            delimiters[i] = "---:"
+        default:
+            delimiters[i] = "---"
+        }
+    }
+
+    writeTableRow(b, headers)
+    writeTableRow(b, delimiters)
+
+    for i := range tw.Rows {
+        cells := make([]string, len(tw.Columns))
+        for j := range tw.Columns {
+            cell, err := tableCellToMarkdown(sn, tw.Cell(i, j))
+            log.PanicIf(err)
+
+            cells[j] = cell
+        }
+
+        writeTableRow(b, cells)
+    }
+
+    _, err = fmt.Fprintf(w, "%s\n", b.String())
+    log.PanicIf(err)
+
+    return nil
+}
+
+// writeTableRow writes one row of a table. The cells are padded with spaces
+// so that a trailing backslash in a cell can not escape the pipe after it.
+func writeTableRow(b *strings.Builder, cells []string) {
+    b.WriteString("|")
+
+    for _, cell := range cells {
+        fmt.Fprintf(b, " %s |", cell)
+    }
+
+    b.WriteString("\n")
+}
+
+// tableCellToMarkdown returns the content of a single cell.
+func tableCellToMarkdown(sn *sitebuilder.SiteNode, tc sitebuilder.TableCell) (cell string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    switch tc.Type {
+    case sitebuilder.TextCellType:
+        return escapeText(tc.Text, false), nil
+    case sitebuilder.LinkCellType:
+        uri, err := sitebuilder.CheckedResolveUri(tc.Locator, sn)
+        log.PanicIf(err)
+
+        return linkToMarkdown(tc.Text, uri), nil
+    case sitebuilder.ImageCellType:
+        uri, err := sitebuilder.CheckedResolveUri(tc.Locator, sn)
+        log.PanicIf(err)
+
+        return imageToMarkdown(tc.Text, uri), nil
+    }
+
+    log.Panicf("cell type not valid: (%d)", tc.Type)
+    return "", nil
+}
+
+// GalleryToMarkdown writes the thumbnails of the gallery as a table with the
+// given number of columns. The caption, if any, is written as a paragraph
+// before the table. Each thumbnail links to its full-size image or image page
+// and is followed by its caption.
+func GalleryToMarkdown(sn *sitebuilder.SiteNode, gw sitebuilder.GalleryWidget, w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    thumbnails, err := gw.Thumbnails(sn)
+    log.PanicIf(err)
+
+    b := new(strings.Builder)
+
+    if caption := strings.TrimSpace(sanitizeText(gw.Caption)); caption != "" {
+        fmt.Fprintf(b, "%s\n\n", escapeText(caption, true))
+    }
+
+    // A table must have a header row.
+    writeTableRow(b, make([]string, gw.Columns))
+
+    delimiters := make([]string, gw.Columns)
+    for i := range delimiters {
+        delimiters[i] = "---"
+    }
+
+    writeTableRow(b, delimiters)
+
+    for i := 0; i < len(thumbnails); i += gw.Columns {
+        cells := make([]string, gw.Columns)
+        for j := 0; j < gw.Columns && i+j < len(thumbnails); j++ {
+            gt := thumbnails[i+j]
+
+            cell := fmt.Sprintf(
+                `<a href="%s"><img src="%s" alt="%s" width="%d" height="%d" /></a>`,
+                EscapeAttribute(escapeUri(gt.TargetUri)), EscapeAttribute(escapeUri(gt.ThumbnailUri)), EscapeAttribute(gt.Caption), gt.Width, gt.Height)
+
+            if caption := sanitizeText(gt.Caption); strings.TrimSpace(caption) != "" {
+                cell += "<br />" + escapeText(caption, false)
+            }
+
+            cells[j] = cell
+        }
+
+        writeTableRow(b, cells)
+    }
+
+    _, err = fmt.Fprintf(w, "%s\n", b.String())
+    log.PanicIf(err)
+
+    return nil
+}
@@ -2,11 +2,18 @@ package markdowndialect
 
 import (
     "bytes"
+    "fmt"
+    "image"
+    "strings"
     "testing"
 
+    "image/png"
+
     "github.com/dsoprea/go-logging"
+    "gopkg.in/russross/blackfriday.v2"
 
     "github.com/dsoprea/go-static-site-builder"
+    "github.com/dsoprea/go-static-site-builder/highlight"
 )
 
 func TestImageWidgetToMarkdown(t *testing.T) {
@@ -26,6 +33,31 @@ func TestImageWidgetToMarkdown(t *testing.T) {
     }
 }
 
+func TestImageWidgetToMarkdown_DetectedDimensions(t *testing.T) {
+    b := new(bytes.Buffer)
+
+    err := png.Encode(b, image.NewGray(image.Rect(0, 0, 40, 30)))
+    log.PanicIf(err)
+
+    erl, err := sitebuilder.NewEmbeddedResourceLocatorWithBytes("image/png", b.Bytes())
+    log.PanicIf(err)
+
+    iw := sitebuilder.NewImageWidget("alt text", erl, 0, 15)
+    iw.LazyLoading = true
+
+    b = new(bytes.Buffer)
+
+    err = ImageWidgetToMarkdown(iw, b)
+    log.PanicIf(err)
+
+    content := b.String()
+    expected := fmt.Sprintf("<img src=\"%s\" width=\"20\" height=\"15\" alt=\"alt text\" loading=\"lazy\" /><br /><br />\n\n", erl.Uri())
+
+    if content != expected {
+        t.Fatalf("Content not correct: [%s]", content)
+    }
+}
+
 func TestLinkWidgetToMarkdown(t *testing.T) {
     text := "text"
     lrl := sitebuilder.NewLocalResourceLocator("/some/file")
@@ -43,6 +75,60 @@ func TestLinkWidgetToMarkdown(t *testing.T) {
     }
 }
 
+func TestLinkWidgetToMarkdownFrom(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    sc.SetOutputPathStrategy(sitebuilder.NewDirectoryOutputPathStrategy())
+
+    sb := sitebuilder.NewSiteBuilder("site title", NewMarkdownDialect(), sc)
+
+    childNode1, err := sb.Root().AddChildNode("child1", "Child1")
+    log.PanicIf(err)
+
+    _, err = sb.Root().AddChildNode("child2", "Child2")
+    log.PanicIf(err)
+
+    lw := sitebuilder.NewLinkWidget("text", sitebuilder.NewSitePageLocalResourceLocator(sb, "child2"))
+
+    // Without a page, the link is relative to the root.
+
+    b := new(bytes.Buffer)
+
+    err = LinkWidgetToMarkdown(lw, b)
+    log.PanicIf(err)
+
+    if b.String() != "[text](child2/index.html)" {
+        t.Fatalf("Link relative to the root not correct: [%s]", b.String())
+    }
+
+    b = new(bytes.Buffer)
+
+    err = LinkWidgetToMarkdownFrom(childNode1, lw, b)
+    log.PanicIf(err)
+
+    if b.String() != "[text](../child2/index.html)" {
+        t.Fatalf("Link relative to the page not correct: [%s]", b.String())
+    }
+}
+
+func TestLinkWidgetToMarkdown_Unresolvable(t *testing.T) {
+    sc := sitebuilder.NewSiteContext("")
+    sb := sitebuilder.NewSiteBuilder("site title", NewMarkdownDialect(), sc)
+
+    splrl := sitebuilder.NewSitePageLocalResourceLocator(sb, "missing")
+
+    // The failure is returned rather than panicking.
+
+    err := LinkWidgetToMarkdownFrom(sb.Root(), sitebuilder.NewLinkWidget("text", splrl), new(bytes.Buffer))
+    if err == nil {
+        t.Fatalf("Expected error for a missing page.")
+    }
+
+    err = ImageWidgetToMarkdownFrom(sb.Root(), sitebuilder.NewImageWidget("alt text", splrl, 0, 0), new(bytes.Buffer))
+    if err == nil {
+        t.Fatalf("Expected error for a missing page.")
+    }
+}
+
 func TestInlineLinkListToMarkdown(t *testing.T) {
     items := []sitebuilder.LinkWidget{
         sitebuilder.NewLinkWidget("Child1", sitebuilder.NewLocalResourceLocator("/some/image/path1")),
@@ -124,3 +210,289 @@ func TestWriteDoubleNewline(t *testing.T) {
         t.Fatalf("Double-newline to Markdown not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
     }
 }
+
+func TestBreadcrumbToMarkdown(t *testing.T) {
+    items := []sitebuilder.LinkWidget{
+        sitebuilder.NewLinkWidget("Home", sitebuilder.NewLocalResourceLocator("index.html")),
+        sitebuilder.NewLinkWidget("Section", sitebuilder.NewLocalResourceLocator("section.html")),
+    }
+
+    b := new(bytes.Buffer)
+
+    err := BreadcrumbToMarkdown(nil, items, "Page", " > ", b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "[Home](index.html) > [Section](section.html) > Page\n\n"
+
+    if actual != expected {
+        t.Fatalf("Breadcrumb to Markdown not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+}
+
+func TestSequenceNavigationToMarkdown(t *testing.T) {
+    previous := sitebuilder.NewLinkWidget("First", sitebuilder.NewLocalResourceLocator("first.html"))
+    up := sitebuilder.NewLinkWidget("Home", sitebuilder.NewLocalResourceLocator("index.html"))
+
+    sl := sitebuilder.SequenceLinks{
+        Previous: &previous,
+        Up:       &up,
+    }
+
+    snw := sitebuilder.NewSequenceNavigationWidget(false)
+
+    b := new(bytes.Buffer)
+
+    err := SequenceNavigationToMarkdown(nil, snw, sl, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "Previous: [First](first.html) | Up: [Home](index.html)\n\n"
+
+    if actual != expected {
+        t.Fatalf("Sequence navigation to Markdown not correct:\nACTUAL:\n[%s]\n\nEXPECTED:\n[%s]", actual, expected)
+    }
+
+    b = new(bytes.Buffer)
+
+    err = SequenceNavigationToMarkdown(nil, snw, sitebuilder.SequenceLinks{}, b)
+    log.PanicIf(err)
+
+    if b.Len() != 0 {
+        t.Fatalf("Expected no output without links: [%s]", b.String())
+    }
+}
+
+func TestParagraphToMarkdown(t *testing.T) {
+    pw := sitebuilder.NewParagraphWidget(
+        sitebuilder.NewPlainSpan("Some "),
+        sitebuilder.NewEmphasisSpan("emphasized "),
+        sitebuilder.NewStrongSpan("strong"),
+        sitebuilder.NewPlainSpan(" and "),
+        sitebuilder.NewCodeSpan("a `b` c"),
+        sitebuilder.NewPlainSpan(" text with a "),
+        sitebuilder.NewLinkSpan("link", sitebuilder.NewLocalResourceLocator("/some (file)")),
+        sitebuilder.NewPlainSpan("."),
+    )
+
+    b := new(bytes.Buffer)
+
+    err := ParagraphToMarkdown(nil, pw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "Some <em>emphasized </em><strong>strong</strong> and `` a `b` c `` text with a [link](file:///some%20%28file%29)\\.\n\n"
+
+    if actual != expected {
+        t.Fatalf("Content not correct: [%s]", actual)
+    }
+}
+
+func TestParagraphToMarkdown_Escaping(t *testing.T) {
+    pw := sitebuilder.NewParagraphWidget(
+        sitebuilder.NewPlainSpan("    # 1. *not* <b>bold</b> [x](y) &amp;\n- item"),
+        sitebuilder.NewStrongSpan("**"),
+    )
+
+    b := new(bytes.Buffer)
+
+    err := ParagraphToMarkdown(nil, pw, b)
+    log.PanicIf(err)
+
+    actual := string(blackfriday.Run(b.Bytes()))
+    expected := "<p># 1. *not* &lt;b&gt;bold&lt;/b&gt; [x](y) &amp;amp; - item<strong>**</strong></p>\n"
+
+    if actual != expected {
+        t.Fatalf("HTML not correct: [%s]", actual)
+    }
+}
+
+func TestTableToMarkdown(t *testing.T) {
+    columns := []sitebuilder.TableColumn{
+        sitebuilder.NewTableColumn("Name", sitebuilder.DefaultAlignment),
+        sitebuilder.NewTableColumn("Left", sitebuilder.LeftAlignment),
+        sitebuilder.NewTableColumn("Center", sitebuilder.CenterAlignment),
+        sitebuilder.NewTableColumn("Right", sitebuilder.RightAlignment),
+    }
+
+    rows := [][]sitebuilder.TableCell{
+        {
+            sitebuilder.NewTextCell("a | b"),
+            sitebuilder.NewLinkCell("link", sitebuilder.NewLocalResourceLocator("/some|file")),
+            sitebuilder.NewImageCell("image", sitebuilder.NewLocalResourceLocator("/some/image")),
+            sitebuilder.NewTextCell("back\\"),
+        },
+        {
+            sitebuilder.NewImageCell("x|y", sitebuilder.NewLocalResourceLocator("/some/image")),
+        },
+    }
+
+    tw := sitebuilder.NewTableWidget(columns, rows)
+    tw.Caption = "Some *caption*"
+
+    b := new(bytes.Buffer)
+
+    err := TableToMarkdown(nil, tw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := `Some \*caption\*
+
+| Name | Left | Center | Right |
+| --- | :--- | :---: | ---: |
+| a \| b | [link](file:///some%7Cfile) | ![image](file:///some/image "image") | back\\ |
+| <img src="file:///some/image" alt="x&#124;y" /> |  |  |  |
+
+`
+
+    if actual != expected {
+        t.Fatalf("Content not correct:\n%s", actual)
+    }
+
+    actual = string(markdownToHtml(b.Bytes()))
+    expected = `<p>Some *caption*</p>
+
+<table>
+<thead>
+<tr>
+<th>Name</th>
+<th align="left">Left</th>
+<th align="center">Center</th>
+<th align="right">Right</th>
+</tr>
+</thead>
+
+<tbody>
+<tr>
+<td>a | b</td>
+<td align="left"><a href="file:///some%7Cfile">link</a></td>
+<td align="center"><img src="file:///some/image" alt="image" title="image" /></td>
+<td align="right">back\</td>
+</tr>
+
+<tr>
+<td><img src="file:///some/image" alt="x&#124;y" /></td>
+<td align="left"></td>
+<td align="center"></td>
+<td align="right"></td>
+</tr>
+</tbody>
+</table>
+`
+
+    if actual != expected {
+        t.Fatalf("HTML not correct:\n%s", actual)
+    }
+}
+
+func TestCodeBlockToMarkdown(t *testing.T) {
+    cbw := sitebuilder.NewCodeBlockWidget("go", "// Not `closed` by ```\nvar x = 1")
+    cbw.LineNumbers = true
+    cbw.HighlightedLines = []sitebuilder.LineRange{sitebuilder.NewLineRange(1, 1), sitebuilder.NewLineRange(2, 3)}
+
+    b := new(bytes.Buffer)
+
+    err := CodeBlockToMarkdown(nil, cbw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "````{go linenos hl_lines=1,2-3}\n// Not `closed` by ```\nvar x = 1\n````\n\n"
+
+    if actual != expected {
+        t.Fatalf("Content not correct: [%s]", actual)
+    }
+
+    // The options are carried through to the highlighting.
+    hb := new(bytes.Buffer)
+
+    err = highlight.WriteHtml(hb, cbw, "// Not `closed` by ```\nvar x = 1\n")
+    log.PanicIf(err)
+
+    if html := string(markdownToHtml(b.Bytes())); html != hb.String() {
+        t.Fatalf("HTML not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", html, hb.String())
+    }
+}
+
+func TestCodeBlockToMarkdown_NoLanguage(t *testing.T) {
+    cbw := sitebuilder.NewCodeBlockWidget("not a language", "a\n")
+
+    b := new(bytes.Buffer)
+
+    err := CodeBlockToMarkdown(nil, cbw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := "```{text}\na\n```\n\n"
+
+    if actual != expected {
+        t.Fatalf("Content not correct: [%s]", actual)
+    }
+}
+
+func TestParseCodeBlockInfo(t *testing.T) {
+    cbw := parseCodeBlockInfo("yaml unknown linenos hl_lines=2,x,4-5,0,7-6")
+
+    if cbw.Language != "yaml" || cbw.LineNumbers != true {
+        t.Fatalf("Code block not correct: %v", cbw)
+    } else if len(cbw.HighlightedLines) != 2 || cbw.HighlightedLines[0] != sitebuilder.NewLineRange(2, 2) || cbw.HighlightedLines[1] != sitebuilder.NewLineRange(4, 5) {
+        t.Fatalf("Highlighted lines not correct: %v", cbw.HighlightedLines)
+    }
+}
+
+func TestGalleryToMarkdown(t *testing.T) {
+    images := make([]sitebuilder.GalleryImage, 3)
+    for i, caption := range []string{"a|b", "", "c"} {
+        b := new(bytes.Buffer)
+
+        err := png.Encode(b, image.NewGray(image.Rect(0, 0, 2, 1)))
+        log.PanicIf(err)
+
+        erl, err := sitebuilder.NewEmbeddedResourceLocatorWithBytes("image/png", b.Bytes())
+        log.PanicIf(err)
+
+        images[i] = sitebuilder.NewGalleryImage(caption, erl)
+    }
+
+    gw := sitebuilder.NewGalleryWidget(images...)
+    gw.Caption = "Some *gallery*"
+    gw.Columns = 2
+
+    thumbnails, err := gw.Thumbnails(nil)
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    err = GalleryToMarkdown(nil, gw, b)
+    log.PanicIf(err)
+
+    actual := b.String()
+    expected := fmt.Sprintf(`Some \*gallery\*
+
+|  |  |
+| --- | --- |
+| <a href="%[1]s"><img src="%[2]s" alt="a&#124;b" width="2" height="1" /></a><br />a\|b | <a href="%[3]s"><img src="%[4]s" alt="" width="2" height="1" /></a> |
+| <a href="%[5]s"><img src="%[6]s" alt="c" width="2" height="1" /></a><br />c |  |
+
+`, thumbnails[0].TargetUri, thumbnails[0].ThumbnailUri, thumbnails[1].TargetUri, thumbnails[1].ThumbnailUri, thumbnails[2].TargetUri, thumbnails[2].ThumbnailUri)
+
+    if actual != expected {
+        t.Fatalf("Content not correct:\n%s", actual)
+    }
+
+    elements := renderedElements(b.Bytes())
+
+    imageCount := 0
+    for _, n := range elements {
+        if n.Data == "img" {
+            imageCount++
+        } else if n.Data == "td" && strings.Contains(textContent(n), "a|b") == true {
+            if attribute(n.FirstChild.FirstChild, "alt") != "a|b" {
+                t.Fatalf("Alt text not correct.")
+            }
+        }
+    }
+
+    if imageCount != 3 {
+        t.Fatalf("Images not rendered: (%d)", imageCount)
+    }
+}
@@ -0,0 +1,246 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "net/http"
+    "path/filepath"
+
+    "github.com/dsoprea/go-logging"
+)
+
+const (
+    // sniffLength is the most content that is considered when detecting its
+    // type.
+    sniffLength = 512
+
+    unknownMimeType = "application/octet-stream"
+)
+
+var (
+    // ErrMimeTypeMismatch indicates a file whose content is not of the type
+    // that its extension implies.
+    ErrMimeTypeMismatch = errors.New("content does not match extension")
+)
+
+var (
+    resourceLogger = log.NewLogger("sitebuilder.resource")
+)
+
+var (
+    // mimeTypesByExtension is used instead of the host's MIME tables so that
+    // builds do not depend on where they are run.
+    mimeTypesByExtension = map[string]string{
+        ".avif":  "image/avif",
+        ".bmp":   "image/bmp",
+        ".css":   "text/css;charset=utf-8",
+        ".csv":   "text/csv;charset=utf-8",
+        ".gif":   "image/gif",
+        ".htm":   "text/html;charset=utf-8",
+        ".html":  "text/html;charset=utf-8",
+        ".ico":   "image/x-icon",
+        ".jpeg":  "image/jpeg",
+        ".jpg":   "image/jpeg",
+        ".js":    "text/javascript;charset=utf-8",
+        ".json":  "application/json",
+        ".md":    "text/markdown;charset=utf-8",
+        ".mjs":   "text/javascript;charset=utf-8",
+        ".mp3":   "audio/mpeg",
+        ".mp4":   "video/mp4",
+        ".oga":   "audio/ogg",
+        ".ogg":   "audio/ogg",
+        ".otf":   "font/otf",
+        ".pdf":   "application/pdf",
+        ".png":   "image/png",
+        ".svg":   "image/svg+xml",
+        ".ttf":   "font/ttf",
+        ".txt":   "text/plain;charset=utf-8",
+        ".wasm":  "application/wasm",
+        ".wav":   "audio/wav",
+        ".webm":  "video/webm",
+        ".webp":  "image/webp",
+        ".woff":  "font/woff",
+        ".woff2": "font/woff2",
+        ".xml":   "text/xml;charset=utf-8",
+        ".zip":   "application/zip",
+    }
+
+    // extensionsByMimeType is the extension that is given to published data
+    // of each type.
+    extensionsByMimeType = map[string]string{
+        "application/json": ".json",
+        "application/pdf":  ".pdf",
+        "application/wasm": ".wasm",
+        "application/zip":  ".zip",
+        "audio/mpeg":       ".mp3",
+        "audio/ogg":        ".ogg",
+        "audio/wav":        ".wav",
+        "font/otf":         ".otf",
+        "font/ttf":         ".ttf",
+        "font/woff":        ".woff",
+        "font/woff2":       ".woff2",
+        "image/avif":       ".avif",
+        "image/bmp":        ".bmp",
+        "image/gif":        ".gif",
+        "image/jpeg":       ".jpg",
+        "image/png":        ".png",
+        "image/svg+xml":    ".svg",
+        "image/webp":       ".webp",
+        "image/x-icon":     ".ico",
+        "text/css":         ".css",
+        "text/csv":         ".csv",
+        "text/html":        ".html",
+        "text/javascript":  ".js",
+        "text/markdown":    ".md",
+        "text/plain":       ".txt",
+        "text/xml":         ".xml",
+        "video/mp4":        ".mp4",
+        "video/webm":       ".webm",
+    }
+
+    // detectedMimeTypeAliases maps the types that are detected to the types
+    // in the tables where they differ.
+    detectedMimeTypeAliases = map[string]string{
+        "application/ogg":          "audio/ogg",
+        "audio/wave":               "audio/wav",
+        "image/vnd.microsoft.icon": "image/x-icon",
+    }
+)
+
+// MimeTypeMismatchPolicy determines what happens to an embedded file whose
+// content is not of the type that its extension implies.
+type MimeTypeMismatchPolicy int
+
+const (
+    // WarnMimeTypeMismatchPolicy logs a warning when the locator is created.
+    WarnMimeTypeMismatchPolicy MimeTypeMismatchPolicy = iota
+
+    // FailMimeTypeMismatchPolicy also fails the page with
+    // ErrMimeTypeMismatch and has SiteBuilder.Validate report it.
+    FailMimeTypeMismatchPolicy
+)
+
+// SetMimeTypeMismatchPolicy sets what happens to embedded files whose content
+// does not match their extension.
+func (sc *SiteContext) SetMimeTypeMismatchPolicy(policy MimeTypeMismatchPolicy) {
+    sc.mimeTypeMismatchPolicy = policy
+}
+
+func (sc *SiteContext) MimeTypeMismatchPolicy() MimeTypeMismatchPolicy {
+    return sc.mimeTypeMismatchPolicy
+}
+
+// MimeTypeByExtension returns the type of files with the given extension
+// (e.g. ".png") or an empty string if it is not known. The same table is used
+// on every host.
+func MimeTypeByExtension(extension string) string {
+    return mimeTypesByExtension[strings.ToLower(extension)]
+}
+
+// extensionForMimeType returns an extension for files of the given type or
+// an empty string if there is none.
+func extensionForMimeType(mimeType string) string {
+    return extensionsByMimeType[baseMimeType(mimeType)]
+}
+
+// baseMimeType returns the type without its parameters (e.g. "text/plain"
+// for "text/plain;charset=utf-8").
+func baseMimeType(mimeType string) string {
+    if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+        mimeType = mimeType[:i]
+    }
+
+    return strings.ToLower(strings.TrimSpace(mimeType))
+}
+
+// DetectMimeType returns the type of the content as determined from its first
+// bytes, as browsers do. SVG images are also recognized. Content that can not
+// be identified is "application/octet-stream".
+func DetectMimeType(data []byte) string {
+    if len(data) > sniffLength {
+        data = data[:sniffLength]
+    }
+
+    // Parameters are written without spaces so that they can be used in
+    // "data:" URIs.
+    detected := strings.Replace(http.DetectContentType(data), "; ", ";", -1)
+
+    if alias, found := detectedMimeTypeAliases[detected]; found == true {
+        return alias
+    }
+
+    if base := baseMimeType(detected); base == "text/xml" || base == "text/plain" {
+        if bytes.Contains(bytes.ToLower(data), []byte("<svg")) == true {
+            return "image/svg+xml"
+        }
+    }
+
+    return detected
+}
+
+// isConclusive returns whether the detected type identifies the content. Most
+// text formats (e.g. CSS and JSON) are only detected as plain text.
+func isConclusive(detectedMimeType string) bool {
+    base := baseMimeType(detectedMimeType)
+    return base != unknownMimeType && base != "text/plain" && base != "text/xml"
+}
+
+// checkMimeType returns ErrMimeTypeMismatch if the content is identified as
+// a different type than the extension of the file-path implies. Unknown
+// extensions and content that can not be identified never mismatch.
+func checkMimeType(localFilepath string, data []byte) error {
+    expected := MimeTypeByExtension(filepath.Ext(localFilepath))
+    if expected == "" {
+        return nil
+    }
+
+    detected := DetectMimeType(data)
+    if isConclusive(detected) == false || baseMimeType(detected) == baseMimeType(expected) {
+        return nil
+    }
+
+    return fmt.Errorf("%w: [%s] is [%s] but has content of type [%s]", ErrMimeTypeMismatch, localFilepath, baseMimeType(expected), baseMimeType(detected))
+}
+
+// mimeTypeForFile returns the type of the file from its extension or, if the
+// extension is missing or not known, from its content. If the content is
+// identified as a different type than the extension implies, a warning is
+// logged and the type of the content is used.
+func mimeTypeForFile(localFilepath string, head []byte) string {
+    err := checkMimeType(localFilepath, head)
+    if err != nil {
+        resourceLogger.Warningf(nil, "%s", err.Error())
+        return DetectMimeType(head)
+    }
+
+    if mimeType := MimeTypeByExtension(filepath.Ext(localFilepath)); mimeType != "" {
+        return mimeType
+    }
+
+    return DetectMimeType(head)
+}
+
+// readFileHead returns as much of the beginning of the file as is needed to
+// detect its type.
+func readFileHead(localFilepath string) (head []byte, err error) {
+    f, err := os.Open(localFilepath)
+    if err != nil {
+        return nil, err
+    }
+
+    defer f.Close()
+
+    head = make([]byte, sniffLength)
+
+    n, err := io.ReadFull(f, head)
+    if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+        return nil, err
+    }
+
+    return head[:n], nil
+}
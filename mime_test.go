@@ -0,0 +1,157 @@
+package sitebuilder
+
+import (
+    "errors"
+    "os"
+    "path"
+    "testing"
+
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func TestMimeTypeByExtension(t *testing.T) {
+    if mimeType := MimeTypeByExtension(".JPG"); mimeType != "image/jpeg" {
+        t.Fatalf("Type not correct: [%s]", mimeType)
+    } else if mimeType := MimeTypeByExtension(".unknown"); mimeType != "" {
+        t.Fatalf("Unknown extension should not have a type: [%s]", mimeType)
+    }
+
+    if extension := extensionForMimeType("text/plain;charset=utf-8"); extension != ".txt" {
+        t.Fatalf("Extension not correct: [%s]", extension)
+    }
+}
+
+func TestDetectMimeType(t *testing.T) {
+    cases := []struct {
+        data     []byte
+        expected string
+    }{
+        {getTestImage(1, 1, true), "image/png"},
+        {[]byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"></svg>`), "image/svg+xml"},
+        {[]byte("plain text"), "text/plain;charset=utf-8"},
+        {[]byte{0, 1, 2, 3}, "application/octet-stream"},
+    }
+
+    for i, c := range cases {
+        if mimeType := DetectMimeType(c.data); mimeType != c.expected {
+            t.Fatalf("Type of case (%d) not correct: [%s]", i, mimeType)
+        }
+    }
+}
+
+func TestCheckMimeType(t *testing.T) {
+    png := getTestImage(1, 1, true)
+
+    err := checkMimeType("image.jpg", png)
+    if errors.Is(err, ErrMimeTypeMismatch) != true {
+        t.Fatalf("Expected a mismatch: [%v]", err)
+    }
+
+    // Matching content, text that can not be told apart, and unknown
+    // extensions do not mismatch.
+    cases := []struct {
+        filepath string
+        data     []byte
+    }{
+        {"image.PNG", png},
+        {"style.css", []byte("body { color: red; }")},
+        {"image.unknown", png},
+    }
+
+    for _, c := range cases {
+        if err := checkMimeType(c.filepath, c.data); err != nil {
+            t.Fatalf("Expected no mismatch for [%s]: [%v]", c.filepath, err)
+        }
+    }
+}
+
+func TestNewEmbeddedResourceLocator_DetectMimeType(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    png := getTestImage(1, 1, true)
+
+    cases := []struct {
+        filename string
+        expected string
+    }{
+        {"image.svg", "image/svg+xml"},
+        {"image", "image/png"},
+
+        // The content is used if it does not match the extension.
+        {"image.jpg", "image/png"},
+    }
+
+    for _, c := range cases {
+        filepath := path.Join(tempPath, c.filename)
+
+        data := png
+        if c.filename == "image.svg" {
+            data = []byte("<svg></svg>")
+        }
+
+        err := ioutil.WriteFile(filepath, data, 0644)
+        log.PanicIf(err)
+
+        erl, err := NewEmbeddedResourceLocator(filepath, "", false)
+        log.PanicIf(err)
+
+        if erl.MimeType != c.expected {
+            t.Fatalf("Type of [%s] not correct: [%s]", c.filename, erl.MimeType)
+        }
+    }
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("", png)
+    log.PanicIf(err)
+
+    if erl.MimeType != "image/png" {
+        t.Fatalf("Type of bytes not correct: [%s]", erl.MimeType)
+    }
+}
+
+func TestEmbeddedResourceLocator_CheckedUriFor_MimeTypeMismatch(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    filepath := path.Join(tempPath, "image.jpg")
+
+    err = ioutil.WriteFile(filepath, getTestImage(1, 1, true), 0644)
+    log.PanicIf(err)
+
+    erl, err := NewEmbeddedResourceLocator(filepath, "", false)
+    log.PanicIf(err)
+
+    sb := getLayoutTestSite(tempPath)
+
+    err = sb.Root().Builder().AddContentImage(NewImageWidget("alt text", erl, 0, 0))
+    log.PanicIf(err)
+
+    // By default, the mismatch is only a warning.
+    _, err = erl.CheckedUriFor(sb.Root())
+    log.PanicIf(err)
+
+    err = sb.Validate()
+    log.PanicIf(err)
+
+    sb.Context().SetMimeTypeMismatchPolicy(FailMimeTypeMismatchPolicy)
+
+    _, err = erl.CheckedUriFor(sb.Root())
+    if errors.Is(err, ErrMimeTypeMismatch) != true {
+        t.Fatalf("Expected a mismatch: [%v]", err)
+    }
+
+    err = sb.Validate()
+
+    ve, ok := err.(*ValidationError)
+    if ok != true {
+        t.Fatalf("Expected a ValidationError: [%v]", err)
+    } else if len(ve.Problems) != 1 || errors.Is(ve.Problems[0], ErrMimeTypeMismatch) != true {
+        t.Fatalf("Problems not correct: %s", ve)
+    }
+}
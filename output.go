@@ -0,0 +1,312 @@
+package sitebuilder
+
+import (
+    "errors"
+    "fmt"
+    "path"
+    "strings"
+
+    "net/url"
+)
+
+const (
+    indexFilename = "index.html"
+)
+
+var (
+    // ErrInvalidOutputPath indicates a page that would be written outside of
+    // the output path, into the assets subdirectory, or to the same path as
+    // another page.
+    ErrInvalidOutputPath = errors.New("output path not valid")
+)
+
+// OutputPathStrategy determines where each page is written. Paths are
+// relative to the HTML output path and use forward slashes. They must stay
+// inside the output path, outside of the assets subdirectory, and be unique;
+// otherwise nothing is written and SiteBuilder.Validate reports
+// ErrInvalidOutputPath.
+type OutputPathStrategy interface {
+    PagePath(sn *SiteNode) string
+}
+
+// OutputPathStrategyFunc adapts a function to an OutputPathStrategy.
+type OutputPathStrategyFunc func(sn *SiteNode) string
+
+func (f OutputPathStrategyFunc) PagePath(sn *SiteNode) string {
+    return f(sn)
+}
+
+// FlatOutputPathStrategy writes every page directly into the output path
+// under a filename derived from its page-ID. This is the default.
+type FlatOutputPathStrategy struct {
+    // Format is the filename template that the page-ID is plugged into.
+    Format string
+}
+
+func NewFlatOutputPathStrategy(format string) *FlatOutputPathStrategy {
+    return &FlatOutputPathStrategy{
+        Format: format,
+    }
+}
+
+func (fops *FlatOutputPathStrategy) PagePath(sn *SiteNode) string {
+    return fmt.Sprintf(fops.Format, sn.PageId)
+}
+
+// DirectoryOutputPathStrategy writes every page as the index of a directory
+// named after its page-ID, nested to mirror the tree (e.g.
+// "parent/child/index.html"). The root is written as the index of the output
+// path.
+type DirectoryOutputPathStrategy struct {
+}
+
+func NewDirectoryOutputPathStrategy() *DirectoryOutputPathStrategy {
+    return &DirectoryOutputPathStrategy{}
+}
+
+func (dops *DirectoryOutputPathStrategy) PagePath(sn *SiteNode) string {
+    if sn.parent == nil {
+        return indexFilename
+    }
+
+    ancestors := sn.Ancestors()
+
+    parts := make([]string, 0, len(ancestors)+1)
+
+    // The root does not get a directory of its own.
+    for _, ancestor := range ancestors[1:] {
+        parts = append(parts, ancestor.PageId)
+    }
+
+    parts = append(parts, sn.PageId, indexFilename)
+
+    return strings.Join(parts, "/")
+}
+
+// MappedOutputPathStrategy writes the pages that it has paths for to those
+// paths and defers to another strategy for all others. The paths are checked
+// like those of any other strategy.
+type MappedOutputPathStrategy struct {
+    // Paths maps page-IDs to paths relative to the output path.
+    Paths map[string]string
+
+    Fallback OutputPathStrategy
+}
+
+func NewMappedOutputPathStrategy(paths map[string]string, fallback OutputPathStrategy) *MappedOutputPathStrategy {
+    return &MappedOutputPathStrategy{
+        Paths:    paths,
+        Fallback: fallback,
+    }
+}
+
+func (mops *MappedOutputPathStrategy) PagePath(sn *SiteNode) string {
+    if pagePath, found := mops.Paths[sn.PageId]; found == true {
+        return pagePath
+    }
+
+    return mops.Fallback.PagePath(sn)
+}
+
+// SetOutputPathStrategy sets how the paths of pages are determined.
+func (sc *SiteContext) SetOutputPathStrategy(ops OutputPathStrategy) {
+    sc.outputPathStrategy = ops
+}
+
+func (sc *SiteContext) OutputPathStrategy() OutputPathStrategy {
+    return sc.outputPathStrategy
+}
+
+// SetPrettyUrls causes links to pages that are written as "index.html" to
+// refer to their directory instead (e.g. "child/" rather than
+// "child/index.html"). This requires a web server that serves the index of a
+// directory.
+func (sc *SiteContext) SetPrettyUrls(prettyUrls bool) {
+    sc.prettyUrls = prettyUrls
+}
+
+// OutputPath returns the path that this page is written to, relative to the
+// HTML output path.
+func (sn *SiteNode) OutputPath() string {
+    return path.Clean(sn.sb.siteContext.outputPathStrategy.PagePath(sn))
+}
+
+// isInsideOutputPath returns whether the cleaned path, which is relative to
+// the HTML output path, refers to something inside of it (and not to the
+// output path itself).
+func isInsideOutputPath(cleaned string) bool {
+    return path.IsAbs(cleaned) == false && cleaned != "." && cleaned != ".." && strings.HasPrefix(cleaned, "../") == false
+}
+
+// checkOutputPath returns ErrInvalidOutputPath if the path, which is relative
+// to the HTML output path, is absolute, leaves the output path, or is inside
+// the given assets subdirectory.
+func checkOutputPath(outputPath, assetsPath string) error {
+    cleaned := path.Clean(outputPath)
+
+    if isInsideOutputPath(cleaned) == false {
+        return fmt.Errorf("%w: [%s] is not inside the output path", ErrInvalidOutputPath, outputPath)
+    } else if cleaned == assetsPath || strings.HasPrefix(cleaned, assetsPath+"/") == true {
+        return fmt.Errorf("%w: [%s] is inside the assets subdirectory", ErrInvalidOutputPath, outputPath)
+    }
+
+    return nil
+}
+
+// outputPathProblems checks the output path of every node, and of the feeds
+// of feed sources, and returns a problem for each one that is not valid, that
+// is the same as, or a directory of, an earlier output path, or that is where
+// the assets subdirectory needs a directory.
+func outputPathProblems(nodes []*SiteNode, assetsPath string) (problems []ValidationProblem) {
+    problems = make([]ValidationProblem, 0)
+
+    files := make(map[string]string, len(nodes))
+    directories := make(map[string]string)
+
+    for dir := path.Dir(assetsPath); dir != "."; dir = path.Dir(dir) {
+        directories[dir] = assetsPath
+    }
+
+    for _, sn := range nodes {
+        outputPaths := append([]string{sn.OutputPath()}, sn.feedPaths()...)
+
+        for _, outputPath := range outputPaths {
+            if err := checkOutputPath(outputPath, assetsPath); err != nil {
+                problems = append(problems, ValidationProblem{sn.PageId, -1, err})
+                continue
+            }
+
+            if otherPageId, found := files[outputPath]; found == true {
+                err := fmt.Errorf("%w: [%s] is also used by [%s]", ErrInvalidOutputPath, outputPath, otherPageId)
+                problems = append(problems, ValidationProblem{sn.PageId, -1, err})
+
+                continue
+            } else if otherPageId, found := directories[outputPath]; found == true {
+                err := fmt.Errorf("%w: [%s] is a directory of [%s]", ErrInvalidOutputPath, outputPath, otherPageId)
+                problems = append(problems, ValidationProblem{sn.PageId, -1, err})
+
+                continue
+            }
+
+            conflict := ""
+            for dir := path.Dir(outputPath); dir != "."; dir = path.Dir(dir) {
+                if otherPageId, found := files[dir]; found == true {
+                    conflict = otherPageId
+                    break
+                }
+            }
+
+            if conflict != "" {
+                err := fmt.Errorf("%w: [%s] is inside the file of [%s]", ErrInvalidOutputPath, outputPath, conflict)
+                problems = append(problems, ValidationProblem{sn.PageId, -1, err})
+
+                continue
+            }
+
+            files[outputPath] = sn.PageId
+
+            for dir := path.Dir(outputPath); dir != "."; dir = path.Dir(dir) {
+                directories[dir] = sn.PageId
+            }
+        }
+    }
+
+    return problems
+}
+
+// checkOutputPaths returns the first problem found by outputPathProblems, if
+// any. Nothing is written if a page would be written to the wrong place.
+func (sb *SiteBuilder) checkOutputPaths() error {
+    if problems := outputPathProblems(sb.rootNode.flatten(), sb.siteContext.assetsPath); len(problems) > 0 {
+        return problems[0]
+    }
+
+    return nil
+}
+
+// UriFrom returns the relative URI that links to this page from the given
+// page. If `from` is nil, the URI is relative to the root of the output path.
+func (sn *SiteNode) UriFrom(from *SiteNode) string {
+    fromDir := ""
+    if from != nil {
+        fromDir = path.Dir(from.OutputPath())
+    }
+
+    targetParts := strings.Split(sn.OutputPath(), "/")
+
+    pretty := sn.sb.siteContext.prettyUrls == true && targetParts[len(targetParts)-1] == indexFilename
+    if pretty == true {
+        targetParts = targetParts[:len(targetParts)-1]
+    }
+
+    return relativeUri(fromDir, targetParts, pretty)
+}
+
+// relativeUriFrom returns the URI of the given path, which is relative to the
+// root of the output path, relative to the page `from`. If `from` is nil, the
+// path is returned as a URI as-is.
+func relativeUriFrom(from *SiteNode, outputRelativePath string) string {
+    fromDir := ""
+    if from != nil {
+        fromDir = path.Dir(from.OutputPath())
+    }
+
+    targetParts := strings.Split(path.Clean(outputRelativePath), "/")
+
+    return relativeUri(fromDir, targetParts, false)
+}
+
+// relativeUri returns the URI of the path made from targetParts relative to
+// the directory fromDir. If isDirectory is true, the target is a directory
+// and the URI has a trailing slash.
+func relativeUri(fromDir string, targetParts []string, isDirectory bool) string {
+    fromParts := make([]string, 0)
+    if fromDir != "" && fromDir != "." {
+        fromParts = strings.Split(fromDir, "/")
+    }
+
+    // Only directories may be shared; a file is never a common prefix.
+    maxCommon := len(targetParts)
+    if isDirectory == false {
+        maxCommon--
+    }
+
+    common := 0
+    for common < len(fromParts) && common < maxCommon && fromParts[common] == targetParts[common] {
+        common++
+    }
+
+    parts := make([]string, 0)
+    for i := common; i < len(fromParts); i++ {
+        parts = append(parts, "..")
+    }
+
+    parts = append(parts, targetParts[common:]...)
+
+    if isDirectory == true {
+        if len(parts) == 0 {
+            return "./"
+        }
+
+        return escapePath(strings.Join(parts, "/")) + "/"
+    }
+
+    return escapePath(strings.Join(parts, "/"))
+}
+
+// escapePath escapes the characters of a path that may not appear in a URI.
+// A colon in the first segment would be taken as the end of a scheme (e.g.
+// "javascript:"), so such paths are prefixed with "./".
+func escapePath(p string) string {
+    u := &url.URL{
+        Path: p,
+    }
+
+    escaped := u.EscapedPath()
+
+    if i := strings.IndexAny(escaped, ":/"); i != -1 && escaped[i] == ':' {
+        escaped = "./" + escaped
+    }
+
+    return escaped
+}
@@ -0,0 +1,336 @@
+package sitebuilder
+
+import (
+    "errors"
+    "os"
+    "path"
+    "testing"
+
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func TestDirectoryOutputPathStrategy_PagePath(t *testing.T) {
+    sb := getLayoutTestSite("")
+    sb.Context().SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+
+    rootNode := sb.Root()
+    childNode1 := rootNode.Children[0]
+    childChildNode1 := childNode1.Children[0]
+
+    if p := rootNode.OutputPath(); p != "index.html" {
+        t.Fatalf("Root path not correct: [%s]", p)
+    } else if p := childNode1.OutputPath(); p != "child1/index.html" {
+        t.Fatalf("Child path not correct: [%s]", p)
+    } else if p := childChildNode1.OutputPath(); p != "child1/childChild1/index.html" {
+        t.Fatalf("Grandchild path not correct: [%s]", p)
+    }
+}
+
+func TestMappedOutputPathStrategy_PagePath(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    paths := map[string]string{
+        "child1": "about/us.html",
+    }
+
+    mops := NewMappedOutputPathStrategy(paths, NewFlatOutputPathStrategy("%s.htm"))
+    sb.Context().SetOutputPathStrategy(mops)
+
+    if p := sb.Root().Children[0].OutputPath(); p != "about/us.html" {
+        t.Fatalf("Mapped path not correct: [%s]", p)
+    } else if p := sb.Root().Children[1].OutputPath(); p != "child2.htm" {
+        t.Fatalf("Fallback path not correct: [%s]", p)
+    }
+}
+
+func TestCheckOutputPath(t *testing.T) {
+    cases := []struct {
+        outputPath string
+        valid      bool
+    }{
+        {"index.html", true},
+        {"a/../b.html", true},
+        {"assets.html", true},
+        {"/etc/passwd", false},
+        {"../index.html", false},
+        {"a/../../index.html", false},
+        {".", false},
+        {"assets/index.html", false},
+        {"assets", false},
+    }
+
+    for _, c := range cases {
+        err := checkOutputPath(c.outputPath, defaultAssetsPath)
+        if c.valid == true && err != nil {
+            t.Fatalf("Path [%s] should be valid: [%v]", c.outputPath, err)
+        } else if c.valid == false && errors.Is(err, ErrInvalidOutputPath) != true {
+            t.Fatalf("Path [%s] should not be valid: [%v]", c.outputPath, err)
+        }
+    }
+}
+
+func TestOutputPathProblems_AssetsPath(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    err := sb.Context().SetAssetsPath("static/files")
+    log.PanicIf(err)
+
+    paths := map[string]string{
+        "child1":      "assets/page.html",
+        "child2":      "static/files/page.html",
+        "childChild1": "static",
+    }
+
+    mops := NewMappedOutputPathStrategy(paths, NewFlatOutputPathStrategy("%s.html"))
+    sb.Context().SetOutputPathStrategy(mops)
+
+    // The default assets path is an ordinary directory now.
+    problems := outputPathProblems(sb.rootNode.flatten(), sb.Context().AssetsPath())
+
+    expected := []string{"childChild1", "child2"}
+
+    if len(problems) != len(expected) {
+        t.Fatalf("Number of problems not correct: %v", problems)
+    }
+
+    for i, pageId := range expected {
+        if problems[i].PageId != pageId || errors.Is(problems[i], ErrInvalidOutputPath) != true {
+            t.Fatalf("Problem (%d) not correct: %s", i, problems[i])
+        }
+    }
+}
+
+func TestSiteContext_SetAssetsPath(t *testing.T) {
+    sc := NewSiteContext("")
+
+    for _, assetsPath := range []string{"", ".", "..", "../assets", "/assets", "a/../.."} {
+        if err := sc.SetAssetsPath(assetsPath); errors.Is(err, ErrInvalidOutputPath) != true {
+            t.Fatalf("Assets path [%s] should not be valid: [%v]", assetsPath, err)
+        }
+    }
+
+    if sc.AssetsPath() != defaultAssetsPath {
+        t.Fatalf("Assets path should not have changed: [%s]", sc.AssetsPath())
+    }
+
+    err := sc.SetAssetsPath("static/./files/")
+    log.PanicIf(err)
+
+    if sc.AssetsPath() != "static/files" {
+        t.Fatalf("Assets path not cleaned: [%s]", sc.AssetsPath())
+    }
+}
+
+func TestOutputPathProblems(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    paths := map[string]string{
+        "child1":      "../outside.html",
+        "child2":      "index.html",
+        "childChild1": "index.html/page.html",
+    }
+
+    mops := NewMappedOutputPathStrategy(paths, NewFlatOutputPathStrategy("%s.html"))
+    sb.Context().SetOutputPathStrategy(mops)
+
+    problems := outputPathProblems(sb.rootNode.flatten(), defaultAssetsPath)
+
+    expected := []string{"child1", "childChild1", "child2"}
+
+    if len(problems) != len(expected) {
+        t.Fatalf("Number of problems not correct: %v", problems)
+    }
+
+    for i, pageId := range expected {
+        if problems[i].PageId != pageId || errors.Is(problems[i], ErrInvalidOutputPath) != true {
+            t.Fatalf("Problem (%d) not correct: %s", i, problems[i])
+        }
+    }
+
+    err := sb.Validate()
+
+    ve, ok := err.(*ValidationError)
+    if ok != true {
+        t.Fatalf("Expected a ValidationError: [%v]", err)
+    } else if len(ve.Problems) != len(expected) {
+        t.Fatalf("Number of validation problems not correct: %s", ve)
+    }
+}
+
+func TestSiteBuilder_WriteToPath_InvalidOutputPath(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    outputPath := path.Join(tempPath, "output")
+
+    sb := getLayoutTestSite(outputPath)
+
+    paths := map[string]string{
+        "child1": "../escaped.html",
+    }
+
+    mops := NewMappedOutputPathStrategy(paths, NewFlatOutputPathStrategy("%s.html"))
+    sb.Context().SetOutputPathStrategy(mops)
+
+    err = sb.WriteToPath()
+    if errors.Is(err, ErrInvalidOutputPath) != true {
+        t.Fatalf("Expected an invalid output path: [%v]", err)
+    }
+
+    _, err = sb.WriteChangedToPath(WriteOptions{})
+    if errors.Is(err, ErrInvalidOutputPath) != true {
+        t.Fatalf("Expected an invalid output path from the incremental build: [%v]", err)
+    }
+
+    if _, err := os.Stat(outputPath); os.IsNotExist(err) != true {
+        t.Fatalf("Nothing should have been written: [%v]", err)
+    }
+}
+
+func TestSiteNode_AddChildNode_DotPageId(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    for _, pageId := range []string{".", ".."} {
+        if _, err := sb.Root().AddChildNode(pageId, "Dots"); err == nil {
+            t.Fatalf("Page-ID [%s] should not be valid.", pageId)
+        }
+    }
+
+    if _, err := sb.Root().AddChildNode("...", "Dots"); err != nil {
+        t.Fatalf("Page-ID [...] should be valid: [%v]", err)
+    }
+}
+
+func TestOutputPathStrategyFunc_PagePath(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    opsf := OutputPathStrategyFunc(func(sn *SiteNode) string {
+        return "pages/" + sn.PageId + ".html"
+    })
+
+    sb.Context().SetOutputPathStrategy(opsf)
+
+    if p := sb.Root().OutputPath(); p != "pages/index.html" {
+        t.Fatalf("Path not correct: [%s]", p)
+    }
+}
+
+func TestRelativeUriFrom_Colon(t *testing.T) {
+    // Otherwise, the first segment would be taken as a scheme.
+    if uri := relativeUriFrom(nil, "javascript:alert(1)"); uri != "./javascript:alert%281%29" {
+        t.Fatalf("URI not correct: [%s]", uri)
+    }
+
+    if uri := relativeUriFrom(nil, "a/b:c"); uri != "a/b:c" {
+        t.Fatalf("URI not correct: [%s]", uri)
+    }
+}
+
+func TestSiteNode_UriFrom(t *testing.T) {
+    sb := getLayoutTestSite("")
+    sb.Context().SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+
+    rootNode := sb.Root()
+    childNode1 := rootNode.Children[0]
+    childNode2 := rootNode.Children[1]
+    childChildNode1 := childNode1.Children[0]
+
+    cases := []struct {
+        target   *SiteNode
+        from     *SiteNode
+        expected string
+    }{
+        {childChildNode1, nil, "child1/childChild1/index.html"},
+        {childChildNode1, rootNode, "child1/childChild1/index.html"},
+        {childChildNode1, childNode1, "childChild1/index.html"},
+        {rootNode, childChildNode1, "../../index.html"},
+        {childNode2, childChildNode1, "../../child2/index.html"},
+        {childNode1, childChildNode1, "../index.html"},
+        {childNode1, childNode1, "index.html"},
+    }
+
+    for _, c := range cases {
+        if uri := c.target.UriFrom(c.from); uri != c.expected {
+            t.Fatalf("URI of [%s] not correct: [%s] != [%s]", c.target.PageId, uri, c.expected)
+        }
+    }
+}
+
+func TestSiteNode_UriFrom_PrettyUrls(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    sc := sb.Context()
+    sc.SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+    sc.SetPrettyUrls(true)
+
+    rootNode := sb.Root()
+    childNode1 := rootNode.Children[0]
+    childNode2 := rootNode.Children[1]
+    childChildNode1 := childNode1.Children[0]
+
+    cases := []struct {
+        target   *SiteNode
+        from     *SiteNode
+        expected string
+    }{
+        {rootNode, nil, "./"},
+        {childChildNode1, rootNode, "child1/childChild1/"},
+        {childChildNode1, childNode1, "childChild1/"},
+        {rootNode, childChildNode1, "../../"},
+        {childNode2, childChildNode1, "../../child2/"},
+        {childNode1, childChildNode1, "../"},
+        {childNode1, childNode1, "./"},
+    }
+
+    for _, c := range cases {
+        if uri := c.target.UriFrom(c.from); uri != c.expected {
+            t.Fatalf("URI of [%s] not correct: [%s] != [%s]", c.target.PageId, uri, c.expected)
+        }
+    }
+}
+
+func TestSitePageLocalResourceLocator_UriFor(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    paths := map[string]string{
+        "child2": "some dir/child 2.html",
+    }
+
+    mops := NewMappedOutputPathStrategy(paths, NewDirectoryOutputPathStrategy())
+    sb.Context().SetOutputPathStrategy(mops)
+
+    childChildNode1 := sb.Root().Children[0].Children[0]
+
+    splrl := NewSitePageLocalResourceLocator(sb, "child2")
+
+    if uri := splrl.UriFor(childChildNode1); uri != "../../some%20dir/child%202.html" {
+        t.Fatalf("URI not correct: [%s]", uri)
+    } else if uri := splrl.Uri(); uri != "some%20dir/child%202.html" {
+        t.Fatalf("Root-relative URI not correct: [%s]", uri)
+    } else if uri := ResolveUri(splrl, childChildNode1); uri != "../../some%20dir/child%202.html" {
+        t.Fatalf("Resolved URI not correct: [%s]", uri)
+    }
+}
+
+func TestSiteBuilder_WriteToPath_DirectoryOutputPathStrategy(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getLayoutTestSite(tempPath)
+    sb.Context().SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    for _, relPath := range []string{"index.html", "child1/index.html", "child1/childChild1/index.html", "child2/index.html"} {
+        if _, err := os.Stat(path.Join(tempPath, relPath)); err != nil {
+            t.Fatalf("Page not written: [%s]", relPath)
+        }
+    }
+}
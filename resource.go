@@ -4,11 +4,13 @@ import (
     "errors"
     "fmt"
     "io"
-    "mime"
     "os"
     "path"
+    "strings"
+    "sync"
 
     "encoding/base64"
+    "encoding/json"
     "io/ioutil"
     "path/filepath"
 
@@ -16,33 +18,105 @@ import (
 )
 
 const (
-    // MaxEmbeddedResourceSize is the maximum allowed size of any embedded
-    // resource.
+    // MaxEmbeddedResourceSize is the default per-resource limit of the
+    // EmbeddedBudget. It is applied when the resource is rendered rather than
+    // when its locator is constructed so that a site can allow larger
+    // resources.
     MaxEmbeddedResourceSize = 1024 * 1024 * 20
 )
 
 var (
     ErrEmbeddedResourceTooLarge = errors.New("embedded resource will be too large")
+
+    // ErrPageNotFound is returned when a locator refers to a page-ID that
+    // does not exist.
+    ErrPageNotFound = errors.New("page not found")
+
+    // ErrResourceUnreadable is returned when a locator's file can not be
+    // read.
+    ErrResourceUnreadable = errors.New("resource not readable")
 )
 
 // A local file-path.
 
 type LocalResourceLocator struct {
     LocalFilepath string
+
+    // OutputRelative, if true, means that a relative path is relative to the
+    // root of the output path rather than to the current directory.
+    OutputRelative bool
 }
 
+// NewLocalResourceLocator returns a locator for the given path. A relative
+// path is used as the URI as-is.
 func NewLocalResourceLocator(localFilepath string) (lrl *LocalResourceLocator) {
     return &LocalResourceLocator{
         LocalFilepath: localFilepath,
     }
 }
 
+// NewOutputRelativeLocalResourceLocator returns a locator for the given path.
+// A relative path is taken to be relative to the root of the output path and
+// its URI is relative to the page that refers to it.
+func NewOutputRelativeLocalResourceLocator(localFilepath string) (lrl *LocalResourceLocator) {
+    return &LocalResourceLocator{
+        LocalFilepath:  localFilepath,
+        OutputRelative: true,
+    }
+}
+
+// Uri returns the URI of the file. Output-relative paths are relative to the
+// root of the output path.
 func (lrl *LocalResourceLocator) Uri() string {
+    return lrl.UriFor(nil)
+}
+
+// UriFor returns a "file://" URI for an absolute path. An output-relative
+// path is returned relative to the page that refers to it. Any other
+// relative path is returned as-is.
+func (lrl *LocalResourceLocator) UriFor(from *SiteNode) string {
+    uri, _ := lrl.CheckedUriFor(from)
+    return uri
+}
+
+// CheckedUriFor is the same as UriFor. The file is not read, so this never
+// fails.
+func (lrl *LocalResourceLocator) CheckedUriFor(from *SiteNode) (uri string, err error) {
     if path.IsAbs(lrl.LocalFilepath) == true {
-        return fmt.Sprintf("file://%s", lrl.LocalFilepath)
-    } else {
-        return lrl.LocalFilepath
+        return fmt.Sprintf("file://%s", lrl.LocalFilepath), nil
+    } else if lrl.OutputRelative == false {
+        return lrl.LocalFilepath, nil
+    }
+
+    return relativeUriFrom(from, lrl.LocalFilepath), nil
+}
+
+// outputPathFrom returns the path, relative to the output path, of the file
+// that the page `from` refers to. The URI of an output-relative path is
+// relative to the page and that of any other relative path is used as-is, so
+// both are resolved by the browser from the page. ok is false for absolute
+// paths and if `from` is not the page of a site.
+func (lrl *LocalResourceLocator) outputPathFrom(from *SiteNode) (outputPath string, ok bool) {
+    if path.IsAbs(lrl.LocalFilepath) == true || from == nil || from.sb == nil {
+        return "", false
+    }
+
+    if lrl.OutputRelative == true {
+        return path.Clean(lrl.LocalFilepath), true
+    }
+
+    return path.Join(path.Dir(from.OutputPath()), lrl.LocalFilepath), true
+}
+
+// filepathFrom returns the path of the file that the page `from` refers to.
+// Rendering, reading, and validation all use it so that they agree on which
+// file that is. Without a page of a site, the path is used as-is.
+func (lrl *LocalResourceLocator) filepathFrom(from *SiteNode) string {
+    if outputPath, ok := lrl.outputPathFrom(from); ok == true {
+        return path.Join(from.sb.siteContext.htmlOutputPath, outputPath)
     }
+
+    return lrl.LocalFilepath
 }
 
 // A locator that points to the final output page for a node.
@@ -59,20 +133,81 @@ func NewSitePageLocalResourceLocator(sb *SiteBuilder, pageId string) (splrl *Sit
     }
 }
 
+func (splrl *SitePageLocalResourceLocator) setSiteBuilder(sb *SiteBuilder) {
+    splrl.sb = sb
+}
+
+// Uri returns the URI of the page relative to the root of the output path.
 func (splrl *SitePageLocalResourceLocator) Uri() string {
-    defer func() {
-        if state := recover(); state != nil {
-            err := log.Wrap(state.(error))
-            log.Panic(err)
-        }
-    }()
+    return splrl.UriFor(nil)
+}
+
+// UriFor returns the URI of the page relative to the page that refers to it.
+// If `from` is nil, the URI is relative to the root of the output path.
+func (splrl *SitePageLocalResourceLocator) UriFor(from *SiteNode) string {
+    uri, err := splrl.CheckedUriFor(from)
+    log.PanicIf(err)
+
+    return uri
+}
+
+// CheckedUriFor is the same as UriFor but returns ErrPageNotFound if the
+// page-ID does not exist.
+func (splrl *SitePageLocalResourceLocator) CheckedUriFor(from *SiteNode) (uri string, err error) {
+    sn, found := splrl.sb.Node(splrl.PageId)
+    if found == false {
+        return "", fmt.Errorf("%w: [%s]", ErrPageNotFound, splrl.PageId)
+    }
 
-    if found := splrl.sb.PageIsValid(splrl.PageId); found == false {
-        log.Panicf("resource refers to invalid page-ID [%s]", splrl.PageId)
+    return sn.UriFrom(from), nil
+}
+
+// A local file that is copied into the output path when the site is written.
+
+type PublishedResourceLocator struct {
+    sb            *SiteBuilder
+    LocalFilepath string
+}
+
+// NewPublishedResourceLocator returns a locator for a local file that will be
+// copied into the assets subdirectory of the output path, under a filename
+// derived from its content, when the site is written. The same content is only
+// copied once no matter how many pages refer to it.
+func NewPublishedResourceLocator(sb *SiteBuilder, localFilepath string) (prl *PublishedResourceLocator) {
+    return &PublishedResourceLocator{
+        sb:            sb,
+        LocalFilepath: localFilepath,
+    }
+}
+
+func (prl *PublishedResourceLocator) setSiteBuilder(sb *SiteBuilder) {
+    prl.sb = sb
+}
+
+// Uri returns the URI of the published file relative to the root of the
+// output path.
+func (prl *PublishedResourceLocator) Uri() string {
+    return prl.UriFor(nil)
+}
+
+// UriFor returns the URI of the published file relative to the page that
+// refers to it.
+func (prl *PublishedResourceLocator) UriFor(from *SiteNode) string {
+    uri, err := prl.CheckedUriFor(from)
+    log.PanicIf(err)
+
+    return uri
+}
+
+// CheckedUriFor is the same as UriFor but returns ErrResourceUnreadable if
+// the file can not be read.
+func (prl *PublishedResourceLocator) CheckedUriFor(from *SiteNode) (uri string, err error) {
+    publishedPath, err := prl.sb.assets.publish(prl.sb.Context(), prl.LocalFilepath)
+    if err != nil {
+        return "", fmt.Errorf("%w: %s", ErrResourceUnreadable, err.Error())
     }
 
-    filename := splrl.sb.Context().GetFinalPageFilename(splrl.PageId)
-    return filename
+    return relativeUriFrom(from, publishedPath), nil
 }
 
 // Embedded data (rather than any local or remote references).
@@ -81,8 +216,14 @@ type EmbeddedResourceLocator struct {
     MimeType          string
     Base64EncodedData string
     Filepath          string
+
+    // lock protects the deferred read since the same locator may be used by
+    // pages that are being rendered concurrently.
+    lock sync.Mutex
 }
 
+// NewEmbeddedResourceLocatorWithBytes embeds the data. If `mimeType` is an
+// empty-string, it is detected from the data.
 func NewEmbeddedResourceLocatorWithBytes(mimeType string, raw []byte) (erl *EmbeddedResourceLocator, err error) {
     defer func() {
         if state := recover(); state != nil {
@@ -91,8 +232,8 @@ func NewEmbeddedResourceLocatorWithBytes(mimeType string, raw []byte) (erl *Embe
         }
     }()
 
-    if len(raw) > MaxEmbeddedResourceSize {
-        log.Panic(ErrEmbeddedResourceTooLarge)
+    if mimeType == "" {
+        mimeType = DetectMimeType(raw)
     }
 
     encoded := base64.StdEncoding.EncodeToString(raw)
@@ -105,6 +246,8 @@ func NewEmbeddedResourceLocatorWithBytes(mimeType string, raw []byte) (erl *Embe
     return erl, nil
 }
 
+// NewEmbeddedResourceLocatorWithReader reads and embeds the data. If
+// `mimeType` is an empty-string, it is detected from the data.
 func NewEmbeddedResourceLocatorWithReader(mimeType string, r io.Reader) (erl *EmbeddedResourceLocator, err error) {
     defer func() {
         if state := recover(); state != nil {
@@ -113,15 +256,11 @@ func NewEmbeddedResourceLocatorWithReader(mimeType string, r io.Reader) (erl *Em
         }
     }()
 
-    // We read one more byte tha we allow just so we know for certain whether we
-    // should throw an error for it being too large.
-    lr := io.LimitReader(r, MaxEmbeddedResourceSize+1)
-
-    raw, err := ioutil.ReadAll(lr)
+    raw, err := ioutil.ReadAll(r)
     log.PanicIf(err)
 
-    if len(raw) > MaxEmbeddedResourceSize {
-        log.Panic(ErrEmbeddedResourceTooLarge)
+    if mimeType == "" {
+        mimeType = DetectMimeType(raw)
     }
 
     encoded := base64.StdEncoding.EncodeToString(raw)
@@ -136,8 +275,10 @@ func NewEmbeddedResourceLocatorWithReader(mimeType string, r io.Reader) (erl *Em
 
 // NewEmbeddedResourceLocator will read the given file and then
 // embed it. If `mimeType` is an empty-string, we will detect it based on the
-// extension. If `readImmediately` is `false`, we'll read and embed it
-// immediately rather than defer until the URI is actually requested.
+// extension or, if the extension is missing or not known, the content. A
+// warning is logged if the content does not match the extension. If
+// `readImmediately` is `false`, we'll read and embed it immediately rather
+// than defer until the URI is actually requested.
 func NewEmbeddedResourceLocator(localFilepath, mimeType string, readImmediately bool) (erl *EmbeddedResourceLocator, err error) {
     defer func() {
         if state := recover(); state != nil {
@@ -146,23 +287,14 @@ func NewEmbeddedResourceLocator(localFilepath, mimeType string, readImmediately
         }
     }()
 
-    fi, err := os.Stat(localFilepath)
+    _, err = os.Stat(localFilepath)
     log.PanicIf(err)
 
-    if fi.Size() > int64(MaxEmbeddedResourceSize) {
-        log.Panic(ErrEmbeddedResourceTooLarge)
-    }
-
     if mimeType == "" {
-        ext := filepath.Ext(localFilepath)
-        if ext == "" {
-            log.Panicf("no mime-type was given but file does not have an extension: [%s]", localFilepath)
-        }
+        head, err := readFileHead(localFilepath)
+        log.PanicIf(err)
 
-        mimeType = mime.TypeByExtension(ext)
-        if mimeType == "" {
-            log.Panicf("no mime-type given and no mime-type could be determined: [%s]", localFilepath)
-        }
+        mimeType = mimeTypeForFile(localFilepath, head)
     }
 
     erl = &EmbeddedResourceLocator{
@@ -178,17 +310,43 @@ func NewEmbeddedResourceLocator(localFilepath, mimeType string, readImmediately
     return erl, nil
 }
 
-func (erl *EmbeddedResourceLocator) materialize() (err error) {
+// MarshalJSON stores the locator. If the data is backed by a file, only the
+// file-path is stored since the data is just a cache of the file.
+func (erl *EmbeddedResourceLocator) MarshalJSON() (data []byte, err error) {
     defer func() {
         if state := recover(); state != nil {
             err = log.Wrap(state.(error))
-            log.Panic(err)
         }
     }()
 
+    erl.lock.Lock()
+    defer erl.lock.Unlock()
+
+    stored := struct {
+        MimeType          string
+        Base64EncodedData string
+        Filepath          string
+    }{
+        MimeType: erl.MimeType,
+        Filepath: erl.Filepath,
+    }
+
+    if erl.Filepath == "" {
+        stored.Base64EncodedData = erl.Base64EncodedData
+    }
+
+    data, err = json.Marshal(stored)
+    log.PanicIf(err)
+
+    return data, nil
+}
+
+func (erl *EmbeddedResourceLocator) materialize() (err error) {
     if erl.Base64EncodedData == "" {
         raw, err := ioutil.ReadFile(erl.Filepath)
-        log.PanicIf(err)
+        if err != nil {
+            return err
+        }
 
         encoded := base64.StdEncoding.EncodeToString(raw)
         erl.Base64EncodedData = encoded
@@ -197,17 +355,79 @@ func (erl *EmbeddedResourceLocator) materialize() (err error) {
     return nil
 }
 
-func (erl *EmbeddedResourceLocator) Uri() string {
+// UriFor returns a "data:" URI. The data counts against the embedded-resource
+// budget of the site that `from` belongs to, which may cause it to be
+// published or downscaled instead.
+func (erl *EmbeddedResourceLocator) UriFor(from *SiteNode) string {
+    uri, err := erl.CheckedUriFor(from)
+    log.PanicIf(err)
+
+    return uri
+}
+
+// CheckedUriFor is the same as UriFor but returns ErrResourceUnreadable if
+// the data has to be read from a file that can not be read. If the budget
+// would be exceeded and its policy is to fail, an error wrapping
+// ErrEmbeddedResourceTooLarge or ErrEmbeddedBudgetExceeded is returned. If the
+// content of the file does not match its extension and the site's policy is
+// to fail, an error wrapping ErrMimeTypeMismatch is returned.
+func (erl *EmbeddedResourceLocator) CheckedUriFor(from *SiteNode) (uri string, err error) {
+    encoded, err := erl.encodedData()
+    if err != nil {
+        return "", err
+    }
+
+    if from == nil || from.sb == nil {
+        return fmt.Sprintf("data:%s;base64,%s", erl.MimeType, encoded), nil
+    }
+
+    raw, err := base64.StdEncoding.DecodeString(encoded)
+    if err != nil {
+        return "", fmt.Errorf("%w: %s", ErrResourceUnreadable, err.Error())
+    }
+
+    return erl.uriForData(from, raw)
+}
+
+// encodedData returns the base64-encoded data, reading it from the file first
+// if it has not been yet.
+func (erl *EmbeddedResourceLocator) encodedData() (encoded string, err error) {
+    erl.lock.Lock()
+    defer erl.lock.Unlock()
+
     if erl.Base64EncodedData == "" {
         if erl.Filepath == "" {
-            log.Panicf("no data present in embedded resource locator but no file-path stored to read from")
+            return "", fmt.Errorf("%w: no data present in embedded resource locator but no file-path stored to read from", ErrResourceUnreadable)
         }
 
         err := erl.materialize()
-        log.PanicIf(err)
+        if err != nil {
+            return "", fmt.Errorf("%w: %s", ErrResourceUnreadable, err.Error())
+        }
+    }
+
+    return erl.Base64EncodedData, nil
+}
+
+// uriForData returns the URI of the already-decoded data for use in the given
+// page of a site. This is CheckedUriFor for callers that also need the data.
+func (erl *EmbeddedResourceLocator) uriForData(from *SiteNode, raw []byte) (uri string, err error) {
+    if erl.Filepath != "" && from.sb.Context().MimeTypeMismatchPolicy() == FailMimeTypeMismatchPolicy {
+        if err := checkMimeType(erl.Filepath, raw); err != nil {
+            return "", err
+        }
+    }
+
+    extension := filepath.Ext(erl.Filepath)
+    if extension == "" {
+        extension = extensionForMimeType(erl.MimeType)
     }
 
-    return fmt.Sprintf("data:%s;base64,%s", erl.MimeType, erl.Base64EncodedData)
+    return embedData(from, erl.MimeType, raw, extension)
+}
+
+func (erl *EmbeddedResourceLocator) Uri() string {
+    return erl.UriFor(nil)
 }
 
 // Interface.
@@ -215,3 +435,125 @@ func (erl *EmbeddedResourceLocator) Uri() string {
 type ResourceLocator interface {
     Uri() string
 }
+
+// RelativeResourceLocator is a locator that knows which page refers to it.
+// This allows it to produce URIs that resolve correctly from wherever that
+// page is written. If `from` is nil, the URI is relative to the root of the
+// output path. Every built-in locator implements this.
+type RelativeResourceLocator interface {
+    ResourceLocator
+    UriFor(from *SiteNode) string
+}
+
+// resourceLocatorAdapter allows a locator that only implements Uri() to be
+// used as a RelativeResourceLocator. The URI is the same for every page.
+type resourceLocatorAdapter struct {
+    ResourceLocator
+}
+
+func (rla resourceLocatorAdapter) UriFor(from *SiteNode) string {
+    return rla.Uri()
+}
+
+// AsRelativeResourceLocator returns the locator itself if it implements
+// RelativeResourceLocator, or otherwise an adapter that returns Uri() for
+// every page.
+func AsRelativeResourceLocator(rl ResourceLocator) RelativeResourceLocator {
+    if rrl, ok := rl.(RelativeResourceLocator); ok == true {
+        return rrl
+    }
+
+    return resourceLocatorAdapter{rl}
+}
+
+// CheckedResourceLocator is a locator that returns an error rather than
+// panicking when it can not produce a URI. The errors wrap ErrPageNotFound or
+// ErrResourceUnreadable where they apply. Every built-in locator implements
+// this.
+type CheckedResourceLocator interface {
+    RelativeResourceLocator
+    CheckedUriFor(from *SiteNode) (uri string, err error)
+}
+
+// ResolveUri returns the URI of the resource for use in the given page. It
+// panics if the URI can not be produced.
+func ResolveUri(rl ResourceLocator, from *SiteNode) string {
+    return AsRelativeResourceLocator(rl).UriFor(from)
+}
+
+// CheckedResolveUri returns the URI of the resource for use in the given page
+// or an error if it can not be produced. Locators that do not implement
+// CheckedResourceLocator are called via UriFor and any panic is returned as
+// an error.
+func CheckedResolveUri(rl ResourceLocator, from *SiteNode) (uri string, err error) {
+    if crl, ok := rl.(CheckedResourceLocator); ok == true {
+        return crl.CheckedUriFor(from)
+    }
+
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    return AsRelativeResourceLocator(rl).UriFor(from), nil
+}
+
+// ReadResource returns the content of the resource. Local, published, and
+// embedded resources can be read. A relative local path is taken to refer to
+// the same file as the URI that the page `from` links to: an output-relative
+// path is relative to the root of the output path and any other relative path
+// to the directory of the page. Errors wrap ErrResourceUnreadable.
+func ReadResource(rl ResourceLocator, from *SiteNode) (data []byte, err error) {
+    rc, err := openResource(rl, from)
+    if err != nil {
+        return nil, err
+    }
+
+    defer rc.Close()
+
+    data, err = ioutil.ReadAll(rc)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %s", ErrResourceUnreadable, err.Error())
+    }
+
+    return data, nil
+}
+
+// openResource returns a reader of the content of the resource so that
+// callers that only need its beginning do not have to read or decode all of
+// it. It supports the same locators as ReadResource.
+func openResource(rl ResourceLocator, from *SiteNode) (rc io.ReadCloser, err error) {
+    openFile := func(filepath string) (io.ReadCloser, error) {
+        f, err := os.Open(filepath)
+        if err != nil {
+            return nil, fmt.Errorf("%w: %s", ErrResourceUnreadable, err.Error())
+        }
+
+        return f, nil
+    }
+
+    switch t := rl.(type) {
+    case *LocalResourceLocator:
+        return openFile(t.filepathFrom(from))
+    case *PublishedResourceLocator:
+        return openFile(t.LocalFilepath)
+    case *EmbeddedResourceLocator:
+        t.lock.Lock()
+        defer t.lock.Unlock()
+
+        if t.Base64EncodedData == "" {
+            if t.Filepath == "" {
+                return nil, fmt.Errorf("%w: no data present in embedded resource locator but no file-path stored to read from", ErrResourceUnreadable)
+            }
+
+            return openFile(t.Filepath)
+        }
+
+        r := base64.NewDecoder(base64.StdEncoding, strings.NewReader(t.Base64EncodedData))
+
+        return ioutil.NopCloser(r), nil
+    }
+
+    return nil, fmt.Errorf("%w: locator can not be read: [%T]", ErrResourceUnreadable, rl)
+}
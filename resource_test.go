@@ -2,6 +2,7 @@ package sitebuilder
 
 import (
     "bytes"
+    "errors"
     "io/ioutil"
     "os"
     "path"
@@ -136,3 +137,172 @@ func NewEmbeddedResourceLocator_DetectMimetype_NoDefer(t *testing.T) {
         log.Panicf("encoding not correct: [%v]", uri)
     }
 }
+
+type plainResourceLocator struct {
+}
+
+func (prl plainResourceLocator) Uri() string {
+    return "https://example.com/image.png"
+}
+
+func TestLocalResourceLocator_UriFor(t *testing.T) {
+    sb := getLayoutTestSite("")
+    sb.Context().SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+
+    childChildNode1 := sb.Root().Children[0].Children[0]
+
+    lrl := NewOutputRelativeLocalResourceLocator("images/some image.png")
+
+    if uri := lrl.UriFor(childChildNode1); uri != "../../images/some%20image.png" {
+        t.Fatalf("Relative URI not correct: [%s]", uri)
+    } else if uri := lrl.Uri(); uri != "images/some%20image.png" {
+        t.Fatalf("Root-relative URI not correct: [%s]", uri)
+    }
+
+    // Unless the path is output-relative, it is used as-is.
+    lrl = NewLocalResourceLocator("images/some image.png")
+
+    if uri := lrl.UriFor(childChildNode1); uri != "images/some image.png" {
+        t.Fatalf("Relative path not passed through: [%s]", uri)
+    } else if uri := lrl.Uri(); uri != "images/some image.png" {
+        t.Fatalf("Relative path not passed through by Uri(): [%s]", uri)
+    }
+
+    lrl = NewLocalResourceLocator("/some/image/path")
+
+    if uri := lrl.UriFor(childChildNode1); uri != "file:///some/image/path" {
+        t.Fatalf("Absolute URI not correct: [%s]", uri)
+    }
+}
+
+func TestEmbeddedResourceLocator_UriFor(t *testing.T) {
+    sb := getLayoutTestSite("")
+    sb.Context().SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("mime/type", []byte{1, 2, 3})
+    log.PanicIf(err)
+
+    if uri := erl.UriFor(sb.Root().Children[0]); uri != "data:mime/type;base64,AQID" {
+        t.Fatalf("URI not correct: [%s]", uri)
+    }
+}
+
+func TestAsRelativeResourceLocator(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    // Every built-in locator is used as-is.
+
+    builtin := []ResourceLocator{
+        NewLocalResourceLocator("/some/path"),
+        NewSitePageLocalResourceLocator(sb, "child1"),
+        NewPublishedResourceLocator(sb, "/some/path"),
+        new(EmbeddedResourceLocator),
+    }
+
+    for _, rl := range builtin {
+        if rrl := AsRelativeResourceLocator(rl); rrl != rl {
+            t.Fatalf("Built-in locator was adapted: [%T]", rl)
+        }
+    }
+
+    // Other locators are adapted.
+
+    rrl := AsRelativeResourceLocator(plainResourceLocator{})
+
+    if uri := rrl.UriFor(sb.Root().Children[0].Children[0]); uri != "https://example.com/image.png" {
+        t.Fatalf("Adapted URI not correct: [%s]", uri)
+    } else if uri := ResolveUri(plainResourceLocator{}, nil); uri != "https://example.com/image.png" {
+        t.Fatalf("Resolved URI not correct: [%s]", uri)
+    }
+}
+
+type panickingResourceLocator struct {
+}
+
+func (prl panickingResourceLocator) Uri() string {
+    log.Panicf("resource not available")
+    return ""
+}
+
+func TestSitePageLocalResourceLocator_CheckedUriFor(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    splrl := NewSitePageLocalResourceLocator(sb, "child1")
+
+    uri, err := splrl.CheckedUriFor(nil)
+    log.PanicIf(err)
+
+    if uri != "child1.html" {
+        t.Fatalf("URI not correct: [%s]", uri)
+    }
+
+    splrl = NewSitePageLocalResourceLocator(sb, "missing")
+
+    _, err = splrl.CheckedUriFor(nil)
+    if errors.Is(err, ErrPageNotFound) != true {
+        t.Fatalf("Expected missing page: [%v]", err)
+    }
+}
+
+func TestEmbeddedResourceLocator_CheckedUriFor_Unreadable(t *testing.T) {
+    erl := &EmbeddedResourceLocator{
+        MimeType: "image/png",
+        Filepath: "/some/missing/path.png",
+    }
+
+    _, err := erl.CheckedUriFor(nil)
+    if errors.Is(err, ErrResourceUnreadable) != true {
+        t.Fatalf("Expected unreadable resource: [%v]", err)
+    }
+}
+
+func TestCheckedResolveUri_Panic(t *testing.T) {
+    _, err := CheckedResolveUri(panickingResourceLocator{}, nil)
+    if err == nil {
+        t.Fatalf("Expected error.")
+    } else if err.Error() != "resource not available" {
+        t.Fatalf("Unexpected error: [%s]", err)
+    }
+}
+
+func TestReadResource(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    filepath := path.Join(tempPath, "file.txt")
+
+    err = ioutil.WriteFile(filepath, []byte("local"), 0644)
+    log.PanicIf(err)
+
+    sb := NewSiteBuilder("site title", NewTestDialect(), NewSiteContext(tempPath))
+
+    // An output-relative path is relative to the output path.
+    data, err := ReadResource(NewOutputRelativeLocalResourceLocator("file.txt"), sb.Root())
+    log.PanicIf(err)
+
+    if string(data) != "local" {
+        t.Fatalf("Local data not correct: [%s]", data)
+    }
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("text/plain", []byte("embedded"))
+    log.PanicIf(err)
+
+    data, err = ReadResource(erl, nil)
+    log.PanicIf(err)
+
+    if string(data) != "embedded" {
+        t.Fatalf("Embedded data not correct: [%s]", data)
+    }
+
+    _, err = ReadResource(NewLocalResourceLocator(path.Join(tempPath, "missing")), nil)
+    if errors.Is(err, ErrResourceUnreadable) != true {
+        t.Fatalf("Expected unreadable resource: [%v]", err)
+    }
+
+    _, err = ReadResource(NewSitePageLocalResourceLocator(sb, "index"), nil)
+    if errors.Is(err, ErrResourceUnreadable) != true {
+        t.Fatalf("Page should not be readable: [%v]", err)
+    }
+}
@@ -0,0 +1,350 @@
+package sitebuilder
+
+import (
+    "errors"
+    "io"
+    "reflect"
+
+    "encoding/json"
+
+    "github.com/dsoprea/go-logging"
+)
+
+const (
+    // serializationVersion is the version of the stored-site format. It is
+    // bumped whenever the stored representation changes incompatibly.
+    serializationVersion = 1
+)
+
+var (
+    ErrUnknownResourceLocatorType = errors.New("resource-locator type not registered")
+)
+
+var (
+    resourceLocatorTypesByName = make(map[string]reflect.Type)
+    resourceLocatorNamesByType = make(map[reflect.Type]string)
+    metadataTypes              = make(map[string]reflect.Type)
+)
+
+// siteBuilderBinder is implemented by resource-locators that refer back to the
+// SiteBuilder and need to be reattached after being loaded.
+type siteBuilderBinder interface {
+    setSiteBuilder(sb *SiteBuilder)
+}
+
+// RegisterResourceLocatorType registers a ResourceLocator implementation so
+// that it can be saved and loaded. `exemplar` is a pointer to a (possibly
+// empty) instance of the type. The name is what is stored and must remain
+// stable.
+func RegisterResourceLocatorType(name string, exemplar ResourceLocator) {
+    t := reflect.TypeOf(exemplar)
+    if t.Kind() != reflect.Ptr {
+        log.Panicf("resource-locator exemplar must be a pointer: [%s]", t)
+    }
+
+    resourceLocatorTypesByName[name] = t.Elem()
+    resourceLocatorNamesByType[t.Elem()] = name
+}
+
+// RegisterMetadataType registers the concrete type of the values stored under
+// `key` in either statement or page metadata so that they can be restored
+// with their original type. Values with unregistered keys are restored as
+// generic JSON values.
+func RegisterMetadataType(key string, exemplar interface{}) {
+    metadataTypes[key] = reflect.TypeOf(exemplar)
+}
+
+// serializedResourceLocator is the stored form of a ResourceLocator. It
+// carries the registered type-name so that we can reconstruct the right type.
+type serializedResourceLocator struct {
+    Type string
+    Data json.RawMessage
+}
+
+func marshalResourceLocator(rl ResourceLocator) (raw json.RawMessage, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    if rl == nil {
+        return json.RawMessage("null"), nil
+    }
+
+    t := reflect.TypeOf(rl)
+    if t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+
+    name, found := resourceLocatorNamesByType[t]
+    if found == false {
+        log.Panicf("resource-locator type [%s] not registered", t)
+    }
+
+    data, err := json.Marshal(rl)
+    log.PanicIf(err)
+
+    srl := serializedResourceLocator{
+        Type: name,
+        Data: data,
+    }
+
+    raw, err = json.Marshal(srl)
+    log.PanicIf(err)
+
+    return raw, nil
+}
+
+func unmarshalResourceLocator(raw json.RawMessage) (rl ResourceLocator, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    if len(raw) == 0 || string(raw) == "null" {
+        return nil, nil
+    }
+
+    srl := serializedResourceLocator{}
+
+    err = json.Unmarshal(raw, &srl)
+    log.PanicIf(err)
+
+    t, found := resourceLocatorTypesByName[srl.Type]
+    if found == false {
+        log.Panic(ErrUnknownResourceLocatorType)
+    }
+
+    v := reflect.New(t)
+
+    err = json.Unmarshal(srl.Data, v.Interface())
+    log.PanicIf(err)
+
+    rl = v.Interface().(ResourceLocator)
+
+    return rl, nil
+}
+
+func unmarshalMetadata(raw map[string]json.RawMessage) (metadata map[string]interface{}, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    metadata = make(map[string]interface{})
+
+    for key, rawValue := range raw {
+        if t, found := metadataTypes[key]; found == true {
+            v := reflect.New(t)
+
+            err := json.Unmarshal(rawValue, v.Interface())
+            log.PanicIf(err)
+
+            metadata[key] = v.Elem().Interface()
+        } else {
+            var value interface{}
+
+            err := json.Unmarshal(rawValue, &value)
+            log.PanicIf(err)
+
+            metadata[key] = value
+        }
+    }
+
+    return metadata, nil
+}
+
+// UnmarshalJSON restores a statement and the concrete types of its metadata.
+func (ps *PageStatement) UnmarshalJSON(data []byte) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    raw := struct {
+        Type              WidgetType
+        StatementMetadata map[string]json.RawMessage
+    }{}
+
+    err = json.Unmarshal(data, &raw)
+    log.PanicIf(err)
+
+    metadata, err := unmarshalMetadata(raw.StatementMetadata)
+    log.PanicIf(err)
+
+    ps.Type = raw.Type
+    ps.StatementMetadata = metadata
+
+    return nil
+}
+
+// UnmarshalJSON restores the page content and the concrete types of its
+// metadata.
+func (pc *PageContent) UnmarshalJSON(data []byte) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    raw := struct {
+        Statements   []PageStatement
+        PageMetadata map[string]json.RawMessage
+    }{}
+
+    err = json.Unmarshal(data, &raw)
+    log.PanicIf(err)
+
+    metadata, err := unmarshalMetadata(raw.PageMetadata)
+    log.PanicIf(err)
+
+    if raw.Statements == nil {
+        raw.Statements = make([]PageStatement, 0)
+    }
+
+    pc.Statements = raw.Statements
+    pc.PageMetadata = metadata
+
+    return nil
+}
+
+// storedSite is the top-level structure that we save.
+type storedSite struct {
+    Version int
+    Root    *SiteNode
+}
+
+// Save writes the complete node structure, including all statements and
+// resource-locators, as JSON. It can be restored with LoadSiteBuilder. Node
+// and section layouts are templates, which can not be stored, so they are
+// not included.
+func (sb *SiteBuilder) Save(w io.Writer) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    ss := storedSite{
+        Version: serializationVersion,
+        Root:    sb.rootNode,
+    }
+
+    e := json.NewEncoder(w)
+    e.SetIndent("", "  ")
+
+    err = e.Encode(ss)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// LoadSiteBuilder restores a site previously written by Save. The dialect and
+// context (including the site-wide layout) are not stored and must be
+// provided again, and any node and section layouts must be set again.
+func LoadSiteBuilder(r io.Reader, dialect Dialect, siteContext *SiteContext) (sb *SiteBuilder, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    ss := storedSite{}
+
+    d := json.NewDecoder(r)
+
+    err = d.Decode(&ss)
+    log.PanicIf(err)
+
+    if ss.Version != serializationVersion {
+        log.Panicf("stored site has unsupported version (%d)", ss.Version)
+    } else if ss.Root == nil {
+        log.Panicf("stored site does not have a root node")
+    } else if ss.Root.PageId != rootPageId {
+        log.Panicf("stored root node has wrong page-ID: [%s]", ss.Root.PageId)
+    }
+
+    sb = NewSiteBuilder(ss.Root.PageTitle, dialect, siteContext)
+    sb.rootNode = ss.Root
+
+    err = sb.adoptNode(ss.Root, nil)
+    log.PanicIf(err)
+
+    return sb, nil
+}
+
+// adoptNode attaches a loaded node and its descendants to the builder and to
+// their parents, indexes them, and reattaches any locators that refer back to
+// the builder.
+func (sb *SiteBuilder) adoptNode(sn *SiteNode, parent *SiteNode) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    if parent == nil {
+        // Replace the root that was created with the builder.
+        sb.pageIndex[rootPageId] = sn
+    } else {
+        if isValidPageId(sn.PageId) == false {
+            log.Panicf("page-ID has an invalid format: [%s]", sn.PageId)
+        }
+
+        err := sb.indexPage(sn)
+        log.PanicIf(err)
+    }
+
+    sn.sb = sb
+    sn.parent = parent
+
+    if sn.Content == nil {
+        sn.Content = NewPageContent()
+    }
+
+    if sn.Children == nil {
+        sn.Children = make([]*SiteNode, 0)
+    }
+
+    for _, ps := range sn.Content.Statements {
+        for _, rl := range ps.Locators() {
+            if sbb, ok := rl.(siteBuilderBinder); ok == true {
+                sbb.setSiteBuilder(sb)
+            }
+        }
+    }
+
+    for _, childNode := range sn.Children {
+        err := sb.adoptNode(childNode, sn)
+        log.PanicIf(err)
+    }
+
+    return nil
+}
+
+func init() {
+    RegisterResourceLocatorType("local", &LocalResourceLocator{})
+    RegisterResourceLocatorType("site_page", &SitePageLocalResourceLocator{})
+    RegisterResourceLocatorType("embedded", &EmbeddedResourceLocator{})
+    RegisterResourceLocatorType("published", &PublishedResourceLocator{})
+
+    RegisterMetadataType("heading", HeadingWidget{})
+    RegisterMetadataType("image", ImageWidget{})
+    RegisterMetadataType("horizontal_navbar", NavbarWidget{})
+    RegisterMetadataType("vertical_navbar", NavbarWidget{})
+    RegisterMetadataType("link", LinkWidget{})
+    RegisterMetadataType("child_navbar", ChildNavbarWidget{})
+    RegisterMetadataType("breadcrumb", BreadcrumbWidget{})
+    RegisterMetadataType("sequence_navigation", SequenceNavigationWidget{})
+    RegisterMetadataType("paragraph", ParagraphWidget{})
+    RegisterMetadataType("table", TableWidget{})
+    RegisterMetadataType("code_block", CodeBlockWidget{})
+    RegisterMetadataType("gallery", GalleryWidget{})
+    RegisterMetadataType(sitemapPageMetadataKey, SitemapEntry{})
+    RegisterMetadataType(feedSourcePageMetadataKey, FeedSource{})
+    RegisterMetadataType(feedItemPageMetadataKey, FeedItem{})
+}
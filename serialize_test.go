@@ -0,0 +1,296 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os"
+    "reflect"
+    "strings"
+    "testing"
+
+    "encoding/json"
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func getSerializationTestSite() (sb *SiteBuilder) {
+    sc := NewSiteContext("")
+
+    td := NewTestDialect()
+    sb = NewSiteBuilder("site title", td, sc)
+
+    rootNode := sb.Root()
+    rootPb := rootNode.Builder()
+
+    lrl := NewLocalResourceLocator("/some/image/path")
+    iw := NewImageWidget("image alt text", lrl, 10, 20)
+
+    err := rootPb.AddContentImage(iw)
+    log.PanicIf(err)
+
+    erl, err := NewEmbeddedResourceLocatorWithBytes("mime/type", []byte{1, 2, 3})
+    log.PanicIf(err)
+
+    iw = NewImageWidget("embedded alt text", erl, 0, 0)
+
+    err = rootPb.AddContentImage(iw)
+    log.PanicIf(err)
+
+    items := []LinkWidget{
+        NewLinkWidget("Child1", NewSitePageLocalResourceLocator(sb, "child1")),
+        NewLinkWidget("Child2", NewSitePageLocalResourceLocator(sb, "child2")),
+    }
+
+    nw := NewNavbarWidget(items)
+
+    err = rootPb.AddHorizontalNavbar(nw)
+    log.PanicIf(err)
+
+    err = rootPb.AddVerticalNavbar(nw, "vertical heading")
+    log.PanicIf(err)
+
+    err = rootPb.AddChildNavbar(VerticalNavbarStyle)
+    log.PanicIf(err)
+
+    childNode1, err := rootNode.AddChildNode("child1", "Child1")
+    log.PanicIf(err)
+
+    lw := NewLinkWidget("Home", NewSitePageLocalResourceLocator(sb, "index"))
+
+    err = childNode1.Builder().AddLink(lw)
+    log.PanicIf(err)
+
+    _, err = rootNode.AddChildNode("child2", "Child2")
+    log.PanicIf(err)
+
+    _, err = childNode1.AddChildNode("childChild1", "ChildChild1")
+    log.PanicIf(err)
+
+    rootNode.Content.PageMetadata["custom"] = "value"
+
+    return sb
+}
+
+func TestSiteBuilder_Save_RoundTrip(t *testing.T) {
+    sb := getSerializationTestSite()
+
+    b := new(bytes.Buffer)
+
+    err := sb.Save(b)
+    log.PanicIf(err)
+
+    original := b.String()
+
+    restoredSb, err := LoadSiteBuilder(b, NewTestDialect(), NewSiteContext(""))
+    log.PanicIf(err)
+
+    b2 := new(bytes.Buffer)
+
+    err = restoredSb.Save(b2)
+    log.PanicIf(err)
+
+    if b2.String() != original {
+        fmt.Printf("ORIGINAL:\n%s\nRESTORED:\n%s\n", original, b2.String())
+        t.Fatalf("Restored site does not serialize identically.")
+    }
+
+    rootNode := restoredSb.Root()
+
+    if rootNode.PageTitle != "site title" {
+        t.Fatalf("Root title not correct: [%s]", rootNode.PageTitle)
+    } else if len(rootNode.Content.Statements) != 6 {
+        t.Fatalf("Root statement count not correct: (%d)", len(rootNode.Content.Statements))
+    } else if rootNode.Content.PageMetadata["custom"] != "value" {
+        t.Fatalf("Page metadata not restored.")
+    }
+
+    iw := rootNode.Content.Statements[0].StatementMetadata["image"].(ImageWidget)
+
+    expectedIw := NewImageWidget("image alt text", NewLocalResourceLocator("/some/image/path"), 10, 20)
+    if reflect.DeepEqual(iw, expectedIw) != true {
+        t.Fatalf("Image widget not restored correctly: %v", iw)
+    }
+
+    iw = rootNode.Content.Statements[1].StatementMetadata["image"].(ImageWidget)
+
+    if uri := iw.Locator.Uri(); uri != "data:mime/type;base64,AQID" {
+        t.Fatalf("Embedded locator not restored correctly: [%s]", uri)
+    }
+
+    nw := rootNode.Content.Statements[2].StatementMetadata["horizontal_navbar"].(NavbarWidget)
+
+    if len(nw.Items) != 2 {
+        t.Fatalf("Navbar items not restored.")
+    } else if uri := nw.Items[1].Locator.Uri(); uri != "child2.html" {
+        t.Fatalf("Page locator not restored correctly: [%s]", uri)
+    }
+
+    splrl := nw.Items[0].Locator.(*SitePageLocalResourceLocator)
+    if splrl.sb != restoredSb {
+        t.Fatalf("Page locator not bound to the restored builder.")
+    }
+
+    h := rootNode.Content.Statements[3].StatementMetadata["heading"].(HeadingWidget)
+    if h.Text != "vertical heading" || h.Level != 1 {
+        t.Fatalf("Heading not restored correctly: %v", h)
+    }
+
+    cnw := rootNode.Content.Statements[5].StatementMetadata["child_navbar"].(ChildNavbarWidget)
+    if cnw.Style != VerticalNavbarStyle {
+        t.Fatalf("Child navbar not restored correctly: %v", cnw)
+    }
+
+    childNode1 := rootNode.Children[0]
+    if childNode1.PageId != "child1" || len(childNode1.Children) != 1 {
+        t.Fatalf("Child not restored correctly: %s", childNode1)
+    } else if childNode1.SiteBuilder() != restoredSb {
+        t.Fatalf("Child not bound to the restored builder.")
+    } else if childNode1.Parent() != rootNode || childNode1.Children[0].Parent() != childNode1 {
+        t.Fatalf("Parents not restored.")
+    }
+
+    for _, pageId := range []string{"index", "child1", "child2", "childChild1"} {
+        if restoredSb.PageIsValid(pageId) != true {
+            t.Fatalf("Page-ID not indexed: [%s]", pageId)
+        }
+    }
+}
+
+func TestLoadSiteBuilder_ModifyAfterLoad(t *testing.T) {
+    sb := getSerializationTestSite()
+
+    b := new(bytes.Buffer)
+
+    err := sb.Save(b)
+    log.PanicIf(err)
+
+    restoredSb, err := LoadSiteBuilder(b, NewTestDialect(), NewSiteContext(""))
+    log.PanicIf(err)
+
+    childNode1 := restoredSb.Root().Children[0]
+
+    _, err = childNode1.AddChildNode("childChild1", "Duplicate")
+    if err == nil {
+        t.Fatalf("Expected error for duplicate page-ID.")
+    }
+
+    newNode, err := childNode1.AddChildNode("childChild2", "ChildChild2")
+    log.PanicIf(err)
+
+    lrl := NewLocalResourceLocator("/some/image/path")
+    iw := NewImageWidget("image alt text", lrl, 0, 0)
+
+    err = newNode.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    err = newNode.Render()
+    log.PanicIf(err)
+
+    expected := `<header>ChildChild2</header>
+<widget>image alt text | file:///some/image/path</widget>
+<footer>ChildChild2</footer>
+`
+
+    if string(newNode.FinalOutput()) != expected {
+        t.Fatalf("Unexpected output:\n%s", newNode.FinalOutput())
+    }
+}
+
+func TestLoadSiteBuilder_UnknownLocatorType(t *testing.T) {
+    raw := `{
+  "Version": 1,
+  "Root": {
+    "PageId": "index",
+    "PageTitle": "site title",
+    "Content": {
+      "Statements": [
+        {
+          "Type": 4,
+          "StatementMetadata": {
+            "link": {
+              "Text": "text",
+              "Locator": {
+                "Type": "unknown",
+                "Data": {}
+              }
+            }
+          }
+        }
+      ]
+    }
+  }
+}`
+
+    _, err := LoadSiteBuilder(strings.NewReader(raw), NewTestDialect(), NewSiteContext(""))
+    if err == nil {
+        t.Fatalf("Expected error for unknown locator type.")
+    } else if errors.Is(err, ErrUnknownResourceLocatorType) != true {
+        t.Fatalf("Unexpected error: [%s]", err)
+    }
+}
+
+func TestLoadSiteBuilder_WrongVersion(t *testing.T) {
+    raw := `{"Version": 99, "Root": {"PageId": "index"}}`
+
+    _, err := LoadSiteBuilder(strings.NewReader(raw), NewTestDialect(), NewSiteContext(""))
+    if err == nil {
+        t.Fatalf("Expected error for unsupported version.")
+    } else if err.Error() != "stored site has unsupported version (99)" {
+        t.Fatalf("Unexpected error: [%s]", err)
+    }
+}
+
+func TestPageStatement_Locators(t *testing.T) {
+    lrl1 := NewLocalResourceLocator("/some/path1")
+    lrl2 := NewLocalResourceLocator("/some/path2")
+
+    items := []LinkWidget{
+        NewLinkWidget("Link1", lrl1),
+        NewLinkWidget("Link2", lrl2),
+    }
+
+    ps := PageStatement{
+        Type: HorizontalNavbar,
+        StatementMetadata: map[string]interface{}{
+            "horizontal_navbar": NewNavbarWidget(items),
+        },
+    }
+
+    locators := ps.Locators()
+
+    expected := []ResourceLocator{lrl1, lrl2}
+    if reflect.DeepEqual(locators, expected) != true {
+        t.Fatalf("Locators not correct: %v", locators)
+    }
+}
+
+func TestEmbeddedResourceLocator_MarshalJSON_FileBacked(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    assetFilepath := writeTestAsset(tempPath, "image.png")
+
+    erl, err := NewEmbeddedResourceLocator(assetFilepath, "", true)
+    log.PanicIf(err)
+
+    raw, err := json.Marshal(erl)
+    log.PanicIf(err)
+
+    expected := fmt.Sprintf(`{"MimeType":"image/png","Base64EncodedData":"","Filepath":"%s"}`, assetFilepath)
+    if string(raw) != expected {
+        t.Fatalf("File-backed locator not stored correctly: [%s]", raw)
+    }
+
+    restored := new(EmbeddedResourceLocator)
+
+    err = json.Unmarshal(raw, restored)
+    log.PanicIf(err)
+
+    if uri := restored.Uri(); uri != "data:image/png;base64,AQID" {
+        t.Fatalf("Restored locator not correct: [%s]", uri)
+    }
+}
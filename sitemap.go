@@ -0,0 +1,460 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "os"
+    "path"
+    "strconv"
+    "strings"
+    "time"
+
+    "encoding/xml"
+    "io/ioutil"
+    "net/url"
+
+    "github.com/dsoprea/go-logging"
+)
+
+const (
+    // SitemapFilename is the name of the sitemap (or, for large sites, the
+    // sitemap index) in the output path.
+    SitemapFilename = "sitemap.xml"
+
+    // RobotsFilename is the name of the robots file in the output path.
+    RobotsFilename = "robots.txt"
+
+    sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+    // sitemapPageMetadataKey is the PageMetadata key that a node's
+    // SitemapEntry is stored under.
+    sitemapPageMetadataKey = "sitemap"
+)
+
+var (
+    // maxSitemapUrls is the most URLs that the protocol allows in one
+    // sitemap. Larger sites are split into several sitemaps that are listed
+    // by a sitemap index.
+    maxSitemapUrls = 50000
+
+    // maxSitemapSize is the largest (uncompressed) size in bytes that the
+    // protocol allows for one sitemap. Sites that would exceed it are split
+    // the same way.
+    maxSitemapSize = 50 * 1024 * 1024
+)
+
+var (
+    ErrInvalidSitemapEntry = errors.New("sitemap entry not valid")
+
+    // ErrBaseUrlNotSet indicates that an absolute URL is needed (e.g. for a
+    // feed) but the site has no base URL.
+    ErrBaseUrlNotSet = errors.New("base URL not set")
+)
+
+// ChangeFrequency is how often a page is expected to change.
+type ChangeFrequency string
+
+const (
+    ChangeFrequencyAlways  ChangeFrequency = "always"
+    ChangeFrequencyHourly  ChangeFrequency = "hourly"
+    ChangeFrequencyDaily   ChangeFrequency = "daily"
+    ChangeFrequencyWeekly  ChangeFrequency = "weekly"
+    ChangeFrequencyMonthly ChangeFrequency = "monthly"
+    ChangeFrequencyYearly  ChangeFrequency = "yearly"
+    ChangeFrequencyNever   ChangeFrequency = "never"
+)
+
+// SitemapEntry is the optional sitemap information for one page. Fields that
+// are not set are left out of the sitemap.
+type SitemapEntry struct {
+    LastModified    time.Time
+    ChangeFrequency ChangeFrequency
+
+    // Priority is between zero and one. Since zero means "not set", use a
+    // small value such as 0.1 for the lowest priority.
+    Priority float64
+}
+
+func (se SitemapEntry) validate() (err error) {
+    switch se.ChangeFrequency {
+    case "", ChangeFrequencyAlways, ChangeFrequencyHourly, ChangeFrequencyDaily, ChangeFrequencyWeekly, ChangeFrequencyMonthly, ChangeFrequencyYearly, ChangeFrequencyNever:
+    default:
+        return ErrInvalidSitemapEntry
+    }
+
+    if se.Priority < 0.0 || se.Priority > 1.0 {
+        return ErrInvalidSitemapEntry
+    }
+
+    return nil
+}
+
+// SetSitemapEntry stores the sitemap information for this page in its
+// PageMetadata.
+func (sn *SiteNode) SetSitemapEntry(se SitemapEntry) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    err = se.validate()
+    log.PanicIf(err)
+
+    sn.Content.PageMetadata[sitemapPageMetadataKey] = se
+
+    return nil
+}
+
+// SitemapEntry returns the sitemap information for this page, if any was set.
+func (sn *SiteNode) SitemapEntry() (se SitemapEntry, found bool) {
+    se, found = sn.Content.PageMetadata[sitemapPageMetadataKey].(SitemapEntry)
+    return se, found
+}
+
+// SetBaseUrl sets the absolute URL that the site is published at (e.g.
+// "https://example.com/docs"). A sitemap and robots.txt are only written if
+// this is set. Since crawlers only look for robots.txt at the root of the
+// host, it is not written if the base URL has a path.
+func (sc *SiteContext) SetBaseUrl(baseUrl string) {
+    sc.baseUrl = baseUrl
+}
+
+func (sc *SiteContext) BaseUrl() string {
+    return sc.baseUrl
+}
+
+// AbsoluteUrl returns the absolute URL of the given URI relative to the base
+// URL. The URI must already be escaped (e.g. as returned by
+// SiteNode.UriFrom(nil)). If there is no base URL, ErrBaseUrlNotSet is
+// returned.
+func (sc *SiteContext) AbsoluteUrl(relativeUri string) (absoluteUrl string, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    if sc.baseUrl == "" {
+        log.Panic(ErrBaseUrlNotSet)
+    }
+
+    base, err := url.Parse(strings.TrimRight(sc.baseUrl, "/") + "/")
+    log.PanicIf(err)
+
+    reference, err := url.Parse(relativeUri)
+    log.PanicIf(err)
+
+    return base.ResolveReference(reference).String(), nil
+}
+
+// RobotsGroup is one group of rules in robots.txt . If neither Allow nor
+// Disallow have any paths, everything is allowed for the user-agents.
+type RobotsGroup struct {
+    UserAgents []string
+    Allow      []string
+    Disallow   []string
+}
+
+// SetRobotsGroups sets the rules written to robots.txt . By default, all
+// user-agents are allowed everywhere. A reference to the sitemap is always
+// appended.
+func (sc *SiteContext) SetRobotsGroups(groups []RobotsGroup) {
+    sc.robotsGroups = groups
+}
+
+type sitemapUrlSet struct {
+    XMLName xml.Name     `xml:"urlset"`
+    Xmlns   string       `xml:"xmlns,attr"`
+    Urls    []sitemapUrl `xml:"url"`
+}
+
+type sitemapUrl struct {
+    Loc        string `xml:"loc"`
+    LastMod    string `xml:"lastmod,omitempty"`
+    ChangeFreq string `xml:"changefreq,omitempty"`
+    Priority   string `xml:"priority,omitempty"`
+}
+
+type sitemapIndex struct {
+    XMLName  xml.Name           `xml:"sitemapindex"`
+    Xmlns    string             `xml:"xmlns,attr"`
+    Sitemaps []sitemapReference `xml:"sitemap"`
+}
+
+type sitemapReference struct {
+    Loc string `xml:"loc"`
+}
+
+// writeXmlFile writes the document with the standard XML declaration.
+func writeXmlFile(filepath string, document interface{}) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    raw, err := xml.MarshalIndent(document, "", "  ")
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    b.WriteString(xml.Header)
+    b.Write(raw)
+    b.WriteString("\n")
+
+    err = ioutil.WriteFile(filepath, b.Bytes(), 0644)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// hasRootBaseUrl returns whether the base URL refers to the root of its host.
+func (sc *SiteContext) hasRootBaseUrl() (isRoot bool, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    u, err := url.Parse(sc.baseUrl)
+    log.PanicIf(err)
+
+    return u.Path == "" || u.Path == "/", nil
+}
+
+// sitemapUrlSize returns the number of bytes that the URL adds to a sitemap.
+func sitemapUrlSize(su sitemapUrl) (size int, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    b := new(bytes.Buffer)
+
+    // The element is indented and put on its own line.
+    e := xml.NewEncoder(b)
+    e.Indent("  ", "  ")
+
+    err = e.EncodeElement(su, xml.StartElement{Name: xml.Name{Local: "url"}})
+    log.PanicIf(err)
+
+    return b.Len() + 1, nil
+}
+
+// splitSitemapUrls splits the URLs into groups that each fit into one
+// sitemap, both by count and by size. Every group has at least one URL.
+func splitSitemapUrls(urls []sitemapUrl) (chunks [][]sitemapUrl, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    empty, err := xml.MarshalIndent(sitemapUrlSet{Xmlns: sitemapNamespace}, "", "  ")
+    log.PanicIf(err)
+
+    // The declaration, the empty urlset, and the trailing newlines.
+    overhead := len(xml.Header) + len(empty) + 2
+
+    chunks = make([][]sitemapUrl, 0)
+
+    start := 0
+    size := overhead
+
+    for i, su := range urls {
+        urlSize, err := sitemapUrlSize(su)
+        log.PanicIf(err)
+
+        if i > start && (i-start >= maxSitemapUrls || size+urlSize > maxSitemapSize) {
+            chunks = append(chunks, urls[start:i])
+
+            start = i
+            size = overhead
+        }
+
+        size += urlSize
+    }
+
+    chunks = append(chunks, urls[start:])
+
+    return chunks, nil
+}
+
+// sitemapChunkFilename returns the name of the nth (one-based) sitemap of a
+// split sitemap.
+func sitemapChunkFilename(n int) string {
+    return fmt.Sprintf("sitemap-%d.xml", n)
+}
+
+// writeSitemap writes a sitemap of every page. Sites with more URLs, or more
+// bytes, than one sitemap may hold are split into several sitemaps and
+// SitemapFilename becomes an index of them.
+func (sb *SiteBuilder) writeSitemap() (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    sc := sb.siteContext
+
+    urls := make([]sitemapUrl, 0)
+    for _, sn := range sb.rootNode.flatten() {
+        loc, err := sc.AbsoluteUrl(sn.UriFrom(nil))
+        log.PanicIf(err)
+
+        su := sitemapUrl{
+            Loc: loc,
+        }
+
+        if se, found := sn.SitemapEntry(); found == true {
+            if se.LastModified.IsZero() == false {
+                su.LastMod = se.LastModified.Format(time.RFC3339)
+            }
+
+            su.ChangeFreq = string(se.ChangeFrequency)
+
+            if se.Priority != 0.0 {
+                su.Priority = strconv.FormatFloat(se.Priority, 'f', -1, 64)
+            }
+        }
+
+        urls = append(urls, su)
+    }
+
+    chunks, err := splitSitemapUrls(urls)
+    log.PanicIf(err)
+
+    chunkCount := 0
+
+    if len(chunks) == 1 {
+        urlSet := sitemapUrlSet{
+            Xmlns: sitemapNamespace,
+            Urls:  urls,
+        }
+
+        err = writeXmlFile(path.Join(sc.htmlOutputPath, SitemapFilename), urlSet)
+        log.PanicIf(err)
+    } else {
+        index := sitemapIndex{
+            Xmlns:    sitemapNamespace,
+            Sitemaps: make([]sitemapReference, 0),
+        }
+
+        for _, chunk := range chunks {
+            chunkCount++
+            filename := sitemapChunkFilename(chunkCount)
+
+            urlSet := sitemapUrlSet{
+                Xmlns: sitemapNamespace,
+                Urls:  chunk,
+            }
+
+            err = writeXmlFile(path.Join(sc.htmlOutputPath, filename), urlSet)
+            log.PanicIf(err)
+
+            loc, err := sc.AbsoluteUrl(relativeUriFrom(nil, filename))
+            log.PanicIf(err)
+
+            index.Sitemaps = append(index.Sitemaps, sitemapReference{Loc: loc})
+        }
+
+        err = writeXmlFile(path.Join(sc.htmlOutputPath, SitemapFilename), index)
+        log.PanicIf(err)
+    }
+
+    // Remove any sitemaps left over from a previous, larger build.
+    for n := chunkCount + 1; ; n++ {
+        err := os.Remove(path.Join(sc.htmlOutputPath, sitemapChunkFilename(n)))
+        if err == nil {
+            continue
+        } else if os.IsNotExist(err) == true {
+            break
+        }
+
+        log.Panic(err)
+    }
+
+    return nil
+}
+
+// writeRobots writes robots.txt with the configured groups and a reference to
+// the sitemap.
+func (sb *SiteBuilder) writeRobots() (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    sc := sb.siteContext
+
+    groups := sc.robotsGroups
+    if len(groups) == 0 {
+        groups = []RobotsGroup{
+            {UserAgents: []string{"*"}},
+        }
+    }
+
+    b := new(bytes.Buffer)
+
+    for _, rg := range groups {
+        for _, userAgent := range rg.UserAgents {
+            fmt.Fprintf(b, "User-agent: %s\n", userAgent)
+        }
+
+        for _, allowPath := range rg.Allow {
+            fmt.Fprintf(b, "Allow: %s\n", allowPath)
+        }
+
+        for _, disallowPath := range rg.Disallow {
+            fmt.Fprintf(b, "Disallow: %s\n", disallowPath)
+        }
+
+        if len(rg.Allow) == 0 && len(rg.Disallow) == 0 {
+            b.WriteString("Disallow:\n")
+        }
+
+        b.WriteString("\n")
+    }
+
+    sitemapUrl, err := sc.AbsoluteUrl(SitemapFilename)
+    log.PanicIf(err)
+
+    fmt.Fprintf(b, "Sitemap: %s\n", sitemapUrl)
+
+    err = ioutil.WriteFile(path.Join(sc.htmlOutputPath, RobotsFilename), b.Bytes(), 0644)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// writeSearchMetadata writes the sitemap and robots.txt if a base URL was
+// given. robots.txt is skipped if the base URL is not the root of its host.
+func (sb *SiteBuilder) writeSearchMetadata() (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    if sb.siteContext.baseUrl == "" {
+        return nil
+    }
+
+    err = sb.writeSitemap()
+    log.PanicIf(err)
+
+    isRoot, err := sb.siteContext.hasRootBaseUrl()
+    log.PanicIf(err)
+
+    if isRoot == true {
+        err = sb.writeRobots()
+        log.PanicIf(err)
+    }
+
+    return nil
+}
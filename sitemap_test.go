@@ -0,0 +1,386 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "errors"
+    "os"
+    "path"
+    "strings"
+    "testing"
+    "time"
+
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func readOutputFile(t *testing.T, htmlOutputPath, filename string) string {
+    raw, err := ioutil.ReadFile(path.Join(htmlOutputPath, filename))
+    if err != nil {
+        t.Fatalf("Could not read [%s]: %s", filename, err)
+    }
+
+    return string(raw)
+}
+
+func TestSiteBuilder_WriteToPath_Sitemap(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getLayoutTestSite(tempPath)
+    sb.Context().SetBaseUrl("https://example.com/docs/")
+
+    se := SitemapEntry{
+        LastModified:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+        ChangeFrequency: ChangeFrequencyWeekly,
+        Priority:        0.8,
+    }
+
+    err = sb.Root().SetSitemapEntry(se)
+    log.PanicIf(err)
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    actual := readOutputFile(t, tempPath, SitemapFilename)
+
+    expected := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/docs/index.html</loc>
+    <lastmod>2020-01-02T03:04:05Z</lastmod>
+    <changefreq>weekly</changefreq>
+    <priority>0.8</priority>
+  </url>
+  <url>
+    <loc>https://example.com/docs/child1.html</loc>
+  </url>
+  <url>
+    <loc>https://example.com/docs/childChild1.html</loc>
+  </url>
+  <url>
+    <loc>https://example.com/docs/child2.html</loc>
+  </url>
+</urlset>
+`
+
+    if actual != expected {
+        t.Fatalf("Sitemap not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+
+    // Crawlers only look for robots.txt at the root of the host.
+
+    if _, err := os.Stat(path.Join(tempPath, RobotsFilename)); os.IsNotExist(err) == false {
+        t.Fatalf("robots.txt should not be written below the root of the host.")
+    }
+}
+
+func TestSiteBuilder_WriteToPath_SitemapIndex(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    originalMaxSitemapUrls := maxSitemapUrls
+    maxSitemapUrls = 3
+
+    defer func() {
+        maxSitemapUrls = originalMaxSitemapUrls
+    }()
+
+    // Leave a sitemap from a previous, larger build behind.
+    err = ioutil.WriteFile(path.Join(tempPath, "sitemap-3.xml"), []byte{}, 0644)
+    log.PanicIf(err)
+
+    sb := getLayoutTestSite(tempPath)
+    sb.Context().SetBaseUrl("https://example.com")
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    actual := readOutputFile(t, tempPath, SitemapFilename)
+
+    expected := `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap>
+    <loc>https://example.com/sitemap-1.xml</loc>
+  </sitemap>
+  <sitemap>
+    <loc>https://example.com/sitemap-2.xml</loc>
+  </sitemap>
+</sitemapindex>
+`
+
+    if actual != expected {
+        t.Fatalf("Sitemap index not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+
+    actual = readOutputFile(t, tempPath, "sitemap-2.xml")
+
+    expected = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/child2.html</loc>
+  </url>
+</urlset>
+`
+
+    if actual != expected {
+        t.Fatalf("Second sitemap not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+
+    if _, err := os.Stat(path.Join(tempPath, "sitemap-3.xml")); os.IsNotExist(err) == false {
+        t.Fatalf("Stale sitemap not removed.")
+    }
+}
+
+func TestSiteBuilder_WriteToPath_SitemapSize(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getLayoutTestSite(tempPath)
+    sb.Context().SetBaseUrl("https://example.com")
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    fullSize := len(readOutputFile(t, tempPath, SitemapFilename))
+
+    originalMaxSitemapSize := maxSitemapSize
+
+    defer func() {
+        maxSitemapSize = originalMaxSitemapSize
+    }()
+
+    // A sitemap that fits exactly is not split.
+
+    maxSitemapSize = fullSize
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    if actual := readOutputFile(t, tempPath, SitemapFilename); len(actual) != fullSize {
+        t.Fatalf("Sitemap should not have been split:\n%s", actual)
+    }
+
+    // One byte less requires a split.
+
+    maxSitemapSize = fullSize - 1
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    if actual := readOutputFile(t, tempPath, SitemapFilename); strings.Contains(actual, "<sitemapindex") == false {
+        t.Fatalf("Expected a sitemap index:\n%s", actual)
+    }
+
+    urlCount := 0
+    for n := 1; n <= 2; n++ {
+        actual := readOutputFile(t, tempPath, sitemapChunkFilename(n))
+
+        if len(actual) > maxSitemapSize {
+            t.Fatalf("Sitemap (%d) is too large: (%d) > (%d)", n, len(actual), maxSitemapSize)
+        }
+
+        urlCount += strings.Count(actual, "<url>")
+    }
+
+    if urlCount != 4 {
+        t.Fatalf("URLs not all listed: (%d)", urlCount)
+    }
+}
+
+func TestSiteBuilder_WriteToPath_Robots(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getLayoutTestSite(tempPath)
+    sb.Context().SetBaseUrl("https://example.com/")
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    actual := readOutputFile(t, tempPath, RobotsFilename)
+
+    expected := `User-agent: *
+Disallow:
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+    if actual != expected {
+        t.Fatalf("Robots not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+}
+
+func TestSiteBuilder_WriteToPath_SitemapEscaping(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sc := NewSiteContext(tempPath)
+    sc.SetBaseUrl("https://example.com")
+
+    paths := map[string]string{
+        "spaced": "my page.html",
+    }
+
+    sc.SetOutputPathStrategy(NewMappedOutputPathStrategy(paths, NewFlatOutputPathStrategy("%s.html")))
+
+    sb := NewSiteBuilder("site title", NewTestDialect(), sc)
+
+    _, err = sb.Root().AddChildNode("spaced", "Spaced")
+    log.PanicIf(err)
+
+    _, err = sb.Root().AddChildNode("a\\b", "Backslash")
+    log.PanicIf(err)
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    actual := readOutputFile(t, tempPath, SitemapFilename)
+
+    expected := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/index.html</loc>
+  </url>
+  <url>
+    <loc>https://example.com/my%20page.html</loc>
+  </url>
+  <url>
+    <loc>https://example.com/a%5Cb.html</loc>
+  </url>
+</urlset>
+`
+
+    if actual != expected {
+        t.Fatalf("Sitemap not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+}
+
+func TestSiteBuilder_WriteToPath_NoBaseUrl(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getLayoutTestSite(tempPath)
+
+    err = sb.WriteToPath()
+    log.PanicIf(err)
+
+    for _, filename := range []string{SitemapFilename, RobotsFilename} {
+        if _, err := os.Stat(path.Join(tempPath, filename)); os.IsNotExist(err) == false {
+            t.Fatalf("[%s] should not be written without a base URL.", filename)
+        }
+    }
+}
+
+func TestSiteContext_AbsoluteUrl_NoBaseUrl(t *testing.T) {
+    sc := NewSiteContext("")
+
+    _, err := sc.AbsoluteUrl("index.html")
+    if errors.Is(err, ErrBaseUrlNotSet) != true {
+        t.Fatalf("Expected an unset base URL: [%v]", err)
+    }
+}
+
+func TestSiteBuilder_writeRobots_Groups(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getLayoutTestSite(tempPath)
+
+    sc := sb.Context()
+    sc.SetBaseUrl("https://example.com")
+
+    groups := []RobotsGroup{
+        {
+            UserAgents: []string{"*"},
+            Disallow:   []string{"/private/"},
+        },
+        {
+            UserAgents: []string{"BadBot", "WorseBot"},
+            Allow:      []string{"/public/"},
+            Disallow:   []string{"/"},
+        },
+    }
+
+    sc.SetRobotsGroups(groups)
+
+    err = sb.writeRobots()
+    log.PanicIf(err)
+
+    actual := readOutputFile(t, tempPath, RobotsFilename)
+
+    expected := `User-agent: *
+Disallow: /private/
+
+User-agent: BadBot
+User-agent: WorseBot
+Allow: /public/
+Disallow: /
+
+Sitemap: https://example.com/sitemap.xml
+`
+
+    if actual != expected {
+        t.Fatalf("Robots not correct:\nACTUAL:\n%s\nEXPECTED:\n%s", actual, expected)
+    }
+}
+
+func TestSiteNode_SetSitemapEntry_Invalid(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    err := sb.Root().SetSitemapEntry(SitemapEntry{Priority: 1.5})
+    if log.Is(err, ErrInvalidSitemapEntry) != true {
+        t.Fatalf("Expected error for invalid priority: [%v]", err)
+    }
+
+    err = sb.Root().SetSitemapEntry(SitemapEntry{ChangeFrequency: "sometimes"})
+    if log.Is(err, ErrInvalidSitemapEntry) != true {
+        t.Fatalf("Expected error for invalid change frequency: [%v]", err)
+    }
+
+    if _, found := sb.Root().SitemapEntry(); found == true {
+        t.Fatalf("Invalid entry should not be stored.")
+    }
+}
+
+func TestSiteNode_SitemapEntry_RoundTrip(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    se := SitemapEntry{
+        LastModified:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+        ChangeFrequency: ChangeFrequencyDaily,
+        Priority:        0.5,
+    }
+
+    err := sb.Root().Children[0].SetSitemapEntry(se)
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    err = sb.Save(b)
+    log.PanicIf(err)
+
+    restoredSb, err := LoadSiteBuilder(b, NewTestDialect(), NewSiteContext(""))
+    log.PanicIf(err)
+
+    restoredSe, found := restoredSb.Root().Children[0].SitemapEntry()
+    if found != true {
+        t.Fatalf("Sitemap entry not restored.")
+    } else if restoredSe.LastModified.Equal(se.LastModified) != true || restoredSe.ChangeFrequency != se.ChangeFrequency || restoredSe.Priority != se.Priority {
+        t.Fatalf("Sitemap entry not restored correctly: %v", restoredSe)
+    }
+}
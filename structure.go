@@ -2,11 +2,11 @@ package sitebuilder
 
 import (
     "fmt"
-    "os"
-    "path"
+    "reflect"
     "regexp"
+    "sync"
 
-    "io/ioutil"
+    "html/template"
 
     "github.com/dsoprea/go-logging"
 )
@@ -28,6 +28,60 @@ type PageStatement struct {
     StatementMetadata map[string]interface{}
 }
 
+// Locators returns every resource-locator referenced by the statement's
+// widgets, however deeply they are nested.
+func (ps PageStatement) Locators() (locators []ResourceLocator) {
+    locators = make([]ResourceLocator, 0)
+
+    for _, value := range ps.StatementMetadata {
+        locators = collectLocators(reflect.ValueOf(value), locators)
+    }
+
+    return locators
+}
+
+func collectLocators(v reflect.Value, locators []ResourceLocator) []ResourceLocator {
+    if v.IsValid() == false {
+        return locators
+    }
+
+    if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+        if v.IsNil() == true {
+            return locators
+        }
+    }
+
+    if v.CanInterface() == true {
+        if rl, ok := v.Interface().(ResourceLocator); ok == true {
+            return append(locators, rl)
+        }
+    }
+
+    switch v.Kind() {
+    case reflect.Interface, reflect.Ptr:
+        locators = collectLocators(v.Elem(), locators)
+    case reflect.Struct:
+        for i := 0; i < v.NumField(); i++ {
+            // Skip unexported fields.
+            if v.Type().Field(i).PkgPath != "" {
+                continue
+            }
+
+            locators = collectLocators(v.Field(i), locators)
+        }
+    case reflect.Slice, reflect.Array:
+        for i := 0; i < v.Len(); i++ {
+            locators = collectLocators(v.Index(i), locators)
+        }
+    case reflect.Map:
+        for _, key := range v.MapKeys() {
+            locators = collectLocators(v.MapIndex(key), locators)
+        }
+    }
+
+    return locators
+}
+
 // PageContent describes all dialect-specific content for a page prior to
 // generating HTML.
 type PageContent struct {
@@ -56,6 +110,9 @@ type SiteNode struct {
     sb                 *SiteBuilder
     intermediateOutput []byte
     finalOutput        []byte
+    layout             *template.Template
+    sectionLayout      *template.Template
+    parent             *SiteNode
 
     PageId    string
     PageTitle string
@@ -104,24 +161,74 @@ func (sn *SiteNode) FinalOutput() []byte {
     return sn.finalOutput
 }
 
+// releaseOutput drops the rendered output once it has been written.
+func (sn *SiteNode) releaseOutput() {
+    sn.intermediateOutput = nil
+    sn.finalOutput = nil
+}
+
+// Render renders this node and all of its descendants.
 func (sn *SiteNode) Render() (err error) {
     defer func() {
         if state := recover(); state != nil {
-            err = log.Wrap(state.(error))
+            err = recoveredError(state)
         }
     }()
 
-    err = sn.sb.dialect.RenderIntermediate(sn)
+    _, err = sn.RenderTree()
+    log.PanicIf(err)
+
+    return nil
+}
+
+// RenderTree renders this node and all of its descendants, depth-first, and
+// returns the number of nodes that were rendered. Every node is rendered
+// exactly once.
+func (sn *SiteNode) RenderTree() (renderedCount int, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    err = sn.renderNode()
     log.PanicIf(err)
 
-    for _, sn := range sn.Children {
-        err := sn.Render()
+    renderedCount = 1
+
+    for _, childNode := range sn.Children {
+        childCount, err := childNode.RenderTree()
         log.PanicIf(err)
+
+        renderedCount += childCount
     }
 
+    return renderedCount, nil
+}
+
+// renderNode renders only this node: the intermediate content, the HTML, and
+// then the layout.
+func (sn *SiteNode) renderNode() (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    sn.sb.embedded.resetPage(sn.PageId)
+
+    err = sn.sb.dialect.RenderIntermediate(sn)
+    log.PanicIf(err)
+
     err = sn.sb.dialect.RenderHtml(sn)
     log.PanicIf(err)
 
+    err = sn.applyLayout()
+    log.PanicIf(err)
+
+    err = sn.injectFeedLinks()
+    log.PanicIf(err)
+
     return nil
 }
 
@@ -134,22 +241,44 @@ func (sn *SiteNode) AddChildNode(pageId, pageTitle string) (childNode *SiteNode,
         }
     }()
 
-    if _, found := sn.sb.pageIndex[pageId]; found == true {
-        log.Panicf("node with page-ID [%s] already exists", pageId)
-    }
-
-    if pageIdRe.MatchString(pageId) == false {
+    if isValidPageId(pageId) == false {
         log.Panicf("page-ID has an invalid format: [%s]", pageId)
     }
 
     childNode = NewSiteNode(sn.sb, pageId, pageTitle)
-    sn.sb.pageIndex[childNode.PageId] = struct{}{}
+    childNode.parent = sn
+
+    err = sn.sb.indexPage(childNode)
+    log.PanicIf(err)
 
     sn.Children = append(sn.Children, childNode)
 
     return childNode, nil
 }
 
+// Parent returns the node that this node is a child of, or nil for the root
+// and for nodes that were not added via AddChildNode.
+func (sn *SiteNode) Parent() *SiteNode {
+    return sn.parent
+}
+
+// Ancestors returns the nodes from the root down to (but not including) this
+// node. It is empty for the root.
+func (sn *SiteNode) Ancestors() (ancestors []*SiteNode) {
+    ancestors = make([]*SiteNode, 0)
+
+    for current := sn.parent; current != nil; current = current.parent {
+        ancestors = append(ancestors, current)
+    }
+
+    // Reverse so that the root is first.
+    for i, j := 0, len(ancestors)-1; i < j; i, j = i+1, j-1 {
+        ancestors[i], ancestors[j] = ancestors[j], ancestors[i]
+    }
+
+    return ancestors
+}
+
 func (sn *SiteNode) SiteBuilder() *SiteBuilder {
     return sn.sb
 }
@@ -160,10 +289,18 @@ func (sn *SiteNode) Builder() *PageBuilder {
 
 // SiteBuilder contains all nodes for the current site being built.
 type SiteBuilder struct {
-    dialect     Dialect
-    rootNode    *SiteNode
-    pageIndex   map[string]struct{}
-    siteContext *SiteContext
+    dialect       Dialect
+    rootNode      *SiteNode
+    pageIndex     map[string]*SiteNode
+    pageIndexLock sync.RWMutex
+    siteContext   *SiteContext
+    assets        *assetPublisher
+    embedded      *embeddedAccountant
+
+    // sequence is the order of the pages while a build is in progress.
+    sequence     *pageSequence
+    buildDepth   int
+    sequenceLock sync.Mutex
 }
 
 type SiteContext struct {
@@ -173,12 +310,43 @@ type SiteContext struct {
     // IdToLocalFilepathFormat is the filename template that we'll plug a page-
     // ID into in order to produce the final filename.
     idToLocalFilepathFormat string
+
+    // outputPathStrategy determines where each page is written.
+    outputPathStrategy OutputPathStrategy
+
+    // prettyUrls causes links to "index.html" pages to refer to their
+    // directory instead.
+    prettyUrls bool
+
+    // layout is the site-wide layout applied to the output of the dialect.
+    layout *template.Template
+
+    // assetsPath is the subdirectory of the output path that published
+    // resources are copied into.
+    assetsPath string
+
+    // baseUrl is the absolute URL that the site is published at. The sitemap
+    // and robots.txt are only written if it is set.
+    baseUrl string
+
+    // robotsGroups are the rules written to robots.txt .
+    robotsGroups []RobotsGroup
+
+    // embeddedBudget limits the resources that are embedded in the pages.
+    embeddedBudget EmbeddedBudget
+
+    // mimeTypeMismatchPolicy determines what happens to embedded files whose
+    // content does not match their extension.
+    mimeTypeMismatchPolicy MimeTypeMismatchPolicy
 }
 
 func NewSiteContext(htmlOutputPath string) *SiteContext {
     return &SiteContext{
         htmlOutputPath:          htmlOutputPath,
         idToLocalFilepathFormat: defaultIdToLocalFilepathFormat,
+        outputPathStrategy:      NewFlatOutputPathStrategy(defaultIdToLocalFilepathFormat),
+        assetsPath:              defaultAssetsPath,
+        embeddedBudget:          NewEmbeddedBudget(),
     }
 }
 
@@ -186,41 +354,77 @@ func NewSiteContext(htmlOutputPath string) *SiteContext {
 //     return sc.toLocalFilepathFormat
 // }
 
+// SetIdToLocalFilepathFormat writes every page directly into the output path
+// using the given filename template. This replaces any other output-path
+// strategy.
 func (sc *SiteContext) SetIdToLocalFilepathFormat(format string) {
     sc.idToLocalFilepathFormat = format
+    sc.outputPathStrategy = NewFlatOutputPathStrategy(format)
 }
 
 func (sc *SiteContext) HtmlOutputPath() string {
     return sc.htmlOutputPath
 }
 
+// GetFinalPageFilename returns the filename that the page would have with the
+// flat output-path strategy. Use SiteNode.OutputPath to get the path that the
+// page is actually written to.
 func (sc *SiteContext) GetFinalPageFilename(pageId string) string {
     filename := fmt.Sprintf(sc.idToLocalFilepathFormat, pageId)
     return filename
 }
 
 func NewSiteBuilder(siteTitle string, dialect Dialect, siteContext *SiteContext) (sb *SiteBuilder) {
-    pageIndex := map[string]struct{}{
-        rootPageId: struct{}{},
-    }
-
     sb = &SiteBuilder{
         dialect:     dialect,
-        pageIndex:   pageIndex,
+        pageIndex:   make(map[string]*SiteNode),
         siteContext: siteContext,
+        assets:      newAssetPublisher(),
+        embedded:    newEmbeddedAccountant(),
     }
 
     rootNode := NewSiteNode(sb, rootPageId, siteTitle)
     sb.rootNode = rootNode
 
+    sb.pageIndex[rootPageId] = rootNode
+
     return sb
 }
 
+// PageIsValid returns whether a node with the given page-ID exists. It is safe
+// to call while nodes are being rendered concurrently.
 func (sb *SiteBuilder) PageIsValid(pageId string) bool {
+    sb.pageIndexLock.RLock()
+    defer sb.pageIndexLock.RUnlock()
+
     _, found := sb.pageIndex[pageId]
     return found
 }
 
+// Node returns the node with the given page-ID. It is safe to call while
+// nodes are being rendered concurrently.
+func (sb *SiteBuilder) Node(pageId string) (sn *SiteNode, found bool) {
+    sb.pageIndexLock.RLock()
+    defer sb.pageIndexLock.RUnlock()
+
+    sn, found = sb.pageIndex[pageId]
+    return sn, found
+}
+
+// indexPage registers a new node. It fails if the page-ID is already used.
+func (sb *SiteBuilder) indexPage(sn *SiteNode) (err error) {
+    sb.pageIndexLock.Lock()
+    defer sb.pageIndexLock.Unlock()
+
+    if _, found := sb.pageIndex[sn.PageId]; found == true {
+        return log.Errorf("node with page-ID [%s] already exists", sn.PageId)
+    }
+
+    sb.pageIndex[sn.PageId] = sn
+
+    return nil
+}
+
 // Root is the root node (homepage) of the site.
 func (sb *SiteBuilder) Context() (siteContext *SiteContext) {
     return sb.siteContext
@@ -231,48 +435,48 @@ func (sb *SiteBuilder) Root() (rootNode *SiteNode) {
     return sb.rootNode
 }
 
-func (sb *SiteBuilder) WriteToPath() (err error) {
+// Render renders every node in the site and returns the number of nodes that
+// were rendered.
+func (sb *SiteBuilder) Render() (renderedCount int, err error) {
     defer func() {
         if state := recover(); state != nil {
-            err = log.Wrap(state.(error))
+            err = recoveredError(state)
         }
     }()
 
-    err = sb.rootNode.Render()
-    log.PanicIf(err)
-
-    err = os.MkdirAll(sb.siteContext.htmlOutputPath, 0755)
-    log.PanicIf(err)
+    defer sb.startBuild()()
 
-    err = sb.writeToPath(sb.rootNode)
+    renderedCount, err = sb.rootNode.RenderTree()
     log.PanicIf(err)
 
-    return nil
+    return renderedCount, nil
 }
 
-func (sb *SiteBuilder) writeToPath(sn *SiteNode) (err error) {
+// WriteToPath renders every node and writes it to the output path, one node
+// at a time.
+func (sb *SiteBuilder) WriteToPath() (err error) {
     defer func() {
         if state := recover(); state != nil {
-            err = log.Wrap(state.(error))
+            err = recoveredError(state)
         }
     }()
 
-    filename := sb.Context().GetFinalPageFilename(sn.PageId)
-    pageFilepath := path.Join(sb.siteContext.htmlOutputPath, filename)
-
-    finalOutput := sn.FinalOutput()
+    options := WriteOptions{
+        Concurrency: 1,
+    }
 
-    err = ioutil.WriteFile(pageFilepath, finalOutput, 0666)
+    err = sb.WriteToPathWithOptions(options)
     log.PanicIf(err)
 
-    for _, childNode := range sn.Children {
-        err := sb.writeToPath(childNode)
-        log.PanicIf(err)
-    }
-
     return nil
 }
 
+// isValidPageId returns whether the page-ID has a valid format. Since page-IDs
+// may become directory names, "." and ".." are not valid.
+func isValidPageId(pageId string) bool {
+    return pageIdRe.MatchString(pageId) == true && pageId != "." && pageId != ".."
+}
+
 func init() {
     var err error
 
@@ -168,7 +168,7 @@ func TestSiteBuilder_WriteToPath(t *testing.T) {
     }
 }
 
-func TestSiteBuilder_writeToPath_Simple(t *testing.T) {
+func TestSiteBuilder_WriteToPath_Simple(t *testing.T) {
     tempPath, err := ioutil.TempDir("", "gssb")
     log.PanicIf(err)
 
@@ -191,10 +191,7 @@ func TestSiteBuilder_writeToPath_Simple(t *testing.T) {
 
     // Write.
 
-    err = sb.rootNode.Render()
-    log.PanicIf(err)
-
-    err = sb.writeToPath(rootNode)
+    err = sb.WriteToPath()
     log.PanicIf(err)
 
     // Read.
@@ -228,7 +225,7 @@ func TestSiteBuilder_writeToPath_Simple(t *testing.T) {
     }
 }
 
-func TestSiteBuilder_writeToPath_Tree(t *testing.T) {
+func TestSiteBuilder_WriteToPath_Tree(t *testing.T) {
     tempPath, err := ioutil.TempDir("", "gssb")
     log.PanicIf(err)
 
@@ -260,10 +257,7 @@ func TestSiteBuilder_writeToPath_Tree(t *testing.T) {
 
     // Write.
 
-    err = sb.rootNode.Render()
-    log.PanicIf(err)
-
-    err = sb.writeToPath(rootNode)
+    err = sb.WriteToPath()
     log.PanicIf(err)
 
     // Read.
@@ -309,3 +303,130 @@ func TestSiteNode_AddChildNode_InvalidFormat(t *testing.T) {
         t.Fatalf("We didn't encounter the right error: [%s]", err)
     }
 }
+
+// countingDialect wraps the test dialect and counts how many times each node
+// is rendered.
+type countingDialect struct {
+    *TestDialect
+
+    intermediateCalls map[string]int
+    htmlCalls         map[string]int
+}
+
+func newCountingDialect() *countingDialect {
+    return &countingDialect{
+        TestDialect:       NewTestDialect(),
+        intermediateCalls: make(map[string]int),
+        htmlCalls:         make(map[string]int),
+    }
+}
+
+func (cd *countingDialect) RenderIntermediate(sn *SiteNode) (err error) {
+    cd.intermediateCalls[sn.PageId]++
+    return cd.TestDialect.RenderIntermediate(sn)
+}
+
+func (cd *countingDialect) RenderHtml(sn *SiteNode) (err error) {
+    cd.htmlCalls[sn.PageId]++
+    return cd.TestDialect.RenderHtml(sn)
+}
+
+func TestSiteBuilder_Render_EachNodeOnce(t *testing.T) {
+    sc := NewSiteContext("")
+
+    cd := newCountingDialect()
+    sb := NewSiteBuilder("site title", cd, sc)
+
+    rootNode := sb.Root()
+
+    childNode1, err := rootNode.AddChildNode("child1", "Child1")
+    log.PanicIf(err)
+
+    _, err = rootNode.AddChildNode("child2", "Child2")
+    log.PanicIf(err)
+
+    childChildNode1, err := childNode1.AddChildNode("childChild1", "ChildChild1")
+    log.PanicIf(err)
+
+    _, err = childChildNode1.AddChildNode("childChildChild1", "ChildChildChild1")
+    log.PanicIf(err)
+
+    renderedCount, err := sb.Render()
+    log.PanicIf(err)
+
+    if renderedCount != 5 {
+        t.Fatalf("Rendered count not correct: (%d)", renderedCount)
+    }
+
+    expected := map[string]int{
+        "index":            1,
+        "child1":           1,
+        "child2":           1,
+        "childChild1":      1,
+        "childChildChild1": 1,
+    }
+
+    if reflect.DeepEqual(cd.intermediateCalls, expected) != true {
+        t.Fatalf("Intermediate render counts not correct: %v", cd.intermediateCalls)
+    } else if reflect.DeepEqual(cd.htmlCalls, expected) != true {
+        t.Fatalf("HTML render counts not correct: %v", cd.htmlCalls)
+    }
+}
+
+func TestSiteNode_RenderTree_Subtree(t *testing.T) {
+    sc := NewSiteContext("")
+
+    cd := newCountingDialect()
+    sb := NewSiteBuilder("site title", cd, sc)
+
+    rootNode := sb.Root()
+
+    childNode1, err := rootNode.AddChildNode("child1", "Child1")
+    log.PanicIf(err)
+
+    _, err = rootNode.AddChildNode("child2", "Child2")
+    log.PanicIf(err)
+
+    _, err = childNode1.AddChildNode("childChild1", "ChildChild1")
+    log.PanicIf(err)
+
+    renderedCount, err := childNode1.RenderTree()
+    log.PanicIf(err)
+
+    if renderedCount != 2 {
+        t.Fatalf("Rendered count not correct: (%d)", renderedCount)
+    }
+
+    expected := map[string]int{
+        "child1":      1,
+        "childChild1": 1,
+    }
+
+    if reflect.DeepEqual(cd.intermediateCalls, expected) != true {
+        t.Fatalf("Intermediate render counts not correct: %v", cd.intermediateCalls)
+    }
+}
+
+func TestSiteNode_Ancestors(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    rootNode := sb.Root()
+    childNode1 := rootNode.Children[0]
+    childChildNode1 := childNode1.Children[0]
+
+    if len(rootNode.Ancestors()) != 0 {
+        t.Fatalf("Root should not have ancestors.")
+    } else if rootNode.Parent() != nil {
+        t.Fatalf("Root should not have a parent.")
+    } else if childChildNode1.Parent() != childNode1 {
+        t.Fatalf("Parent not correct.")
+    }
+
+    ancestors := childChildNode1.Ancestors()
+
+    if len(ancestors) != 2 {
+        t.Fatalf("Ancestor count not correct: (%d)", len(ancestors))
+    } else if ancestors[0] != rootNode || ancestors[1] != childNode1 {
+        t.Fatalf("Ancestors not correct: %v", ancestors)
+    }
+}
@@ -0,0 +1,393 @@
+package sitebuilder
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "reflect"
+    "strings"
+
+    "encoding/csv"
+    "encoding/json"
+
+    "github.com/dsoprea/go-logging"
+)
+
+const (
+    // tableTagName is the name of the struct tag that configures the column
+    // of a field. The value is the header followed by options (e.g.
+    // `table:"Size,align=right"`). A value of "-" skips the field.
+    tableTagName = "table"
+)
+
+var (
+    // ErrInvalidTable indicates a table without columns or with rows that
+    // have more cells than there are columns.
+    ErrInvalidTable = errors.New("table not valid")
+)
+
+// ColumnAlignment determines how the cells of a column are aligned.
+type ColumnAlignment int
+
+const (
+    DefaultAlignment ColumnAlignment = iota
+    LeftAlignment
+    CenterAlignment
+    RightAlignment
+)
+
+// String returns the name of the alignment as used by CSS, or an empty
+// string for the default alignment.
+func (ca ColumnAlignment) String() string {
+    switch ca {
+    case LeftAlignment:
+        return "left"
+    case CenterAlignment:
+        return "center"
+    case RightAlignment:
+        return "right"
+    }
+
+    return ""
+}
+
+// CellType determines what a table cell contains.
+type CellType int
+
+const (
+    TextCellType CellType = iota
+    LinkCellType
+    ImageCellType
+)
+
+// TableCell is one cell of a table. The text is the text of a text or link
+// cell or the alt text of an image cell. The locator is only used by link and
+// image cells.
+type TableCell struct {
+    Type    CellType
+    Text    string
+    Locator ResourceLocator
+}
+
+func NewTextCell(text string) TableCell {
+    return TableCell{
+        Type: TextCellType,
+        Text: text,
+    }
+}
+
+func NewLinkCell(text string, locator ResourceLocator) TableCell {
+    return TableCell{
+        Type:    LinkCellType,
+        Text:    text,
+        Locator: locator,
+    }
+}
+
+func NewImageCell(altText string, locator ResourceLocator) TableCell {
+    return TableCell{
+        Type:    ImageCellType,
+        Text:    altText,
+        Locator: locator,
+    }
+}
+
+// MarshalJSON stores the cell along with the type of its locator.
+func (tc TableCell) MarshalJSON() (data []byte, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    locator, err := marshalResourceLocator(tc.Locator)
+    log.PanicIf(err)
+
+    stored := struct {
+        Type    CellType
+        Text    string
+        Locator json.RawMessage
+    }{
+        Type:    tc.Type,
+        Text:    tc.Text,
+        Locator: locator,
+    }
+
+    data, err = json.Marshal(stored)
+    log.PanicIf(err)
+
+    return data, nil
+}
+
+// UnmarshalJSON restores the cell and its locator.
+func (tc *TableCell) UnmarshalJSON(data []byte) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    stored := struct {
+        Type    CellType
+        Text    string
+        Locator json.RawMessage
+    }{}
+
+    err = json.Unmarshal(data, &stored)
+    log.PanicIf(err)
+
+    locator, err := unmarshalResourceLocator(stored.Locator)
+    log.PanicIf(err)
+
+    tc.Type = stored.Type
+    tc.Text = stored.Text
+    tc.Locator = locator
+
+    return nil
+}
+
+// TableColumn describes one column of a table.
+type TableColumn struct {
+    Header    string
+    Alignment ColumnAlignment
+}
+
+func NewTableColumn(header string, alignment ColumnAlignment) TableColumn {
+    return TableColumn{
+        Header:    header,
+        Alignment: alignment,
+    }
+}
+
+// TableWidget is a table with a header row. Rows may have fewer cells than
+// there are columns, in which case they are padded with empty cells.
+type TableWidget struct {
+    // Caption, if not empty, describes the table.
+    Caption string
+
+    Columns []TableColumn
+    Rows    [][]TableCell
+}
+
+func NewTableWidget(columns []TableColumn, rows [][]TableCell) TableWidget {
+    return TableWidget{
+        Columns: columns,
+        Rows:    rows,
+    }
+}
+
+// Validate returns ErrInvalidTable if the table has no columns or if any row
+// has more cells than there are columns.
+func (tw TableWidget) Validate() error {
+    if len(tw.Columns) == 0 {
+        return fmt.Errorf("%w: no columns", ErrInvalidTable)
+    }
+
+    for i, row := range tw.Rows {
+        if len(row) > len(tw.Columns) {
+            return fmt.Errorf("%w: row (%d) has (%d) cells but there are only (%d) columns", ErrInvalidTable, i, len(row), len(tw.Columns))
+        }
+    }
+
+    return nil
+}
+
+// Cell returns the cell at the given position. The cells that pad short rows
+// are empty text cells.
+func (tw TableWidget) Cell(row, column int) TableCell {
+    cells := tw.Rows[row]
+    if column >= len(cells) {
+        return NewTextCell("")
+    }
+
+    return cells[column]
+}
+
+// NewTableWidgetFromStrings builds a table of text cells. The first record
+// has the headers.
+func NewTableWidgetFromStrings(records [][]string) (tw TableWidget, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    if len(records) == 0 {
+        log.Panicf("%w: no header record", ErrInvalidTable)
+    }
+
+    columns := make([]TableColumn, len(records[0]))
+    for i, header := range records[0] {
+        columns[i] = NewTableColumn(header, DefaultAlignment)
+    }
+
+    rows := make([][]TableCell, len(records)-1)
+    for i, record := range records[1:] {
+        cells := make([]TableCell, len(record))
+        for j, value := range record {
+            cells[j] = NewTextCell(value)
+        }
+
+        rows[i] = cells
+    }
+
+    tw = NewTableWidget(columns, rows)
+
+    err = tw.Validate()
+    log.PanicIf(err)
+
+    return tw, nil
+}
+
+// NewTableWidgetFromCsv builds a table of text cells from CSV data. The first
+// record has the headers.
+func NewTableWidgetFromCsv(r io.Reader) (tw TableWidget, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    cr := csv.NewReader(r)
+
+    records, err := cr.ReadAll()
+    log.PanicIf(err)
+
+    tw, err = NewTableWidgetFromStrings(records)
+    log.PanicIf(err)
+
+    return tw, nil
+}
+
+// NewTableWidgetFromStructs builds a table with a row for every item of the
+// given slice, which must have structs or pointers to structs. There is a
+// column for every exported field. The "table" tag of a field may give the
+// header (which otherwise is the name of the field) and an "align" option
+// (e.g. `table:"Size,align=right"`), or may be "-" to skip the field.
+//
+// Fields that are LinkWidgets, ImageWidgets, or TableCells become cells of
+// that kind. Everything else is formatted as text.
+func NewTableWidgetFromStructs(items interface{}) (tw TableWidget, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    v := reflect.ValueOf(items)
+    if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+        log.Panicf("%w: not a slice: [%s]", ErrInvalidTable, v.Type())
+    }
+
+    t := v.Type().Elem()
+    if t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+
+    if t.Kind() != reflect.Struct {
+        log.Panicf("%w: not a slice of structs: [%s]", ErrInvalidTable, v.Type())
+    }
+
+    columns := make([]TableColumn, 0)
+    fieldIndices := make([]int, 0)
+
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+
+        // Skip unexported fields.
+        if field.PkgPath != "" {
+            continue
+        }
+
+        tag := field.Tag.Get(tableTagName)
+        if tag == "-" {
+            continue
+        }
+
+        column, err := parseTableTag(field.Name, tag)
+        log.PanicIf(err)
+
+        columns = append(columns, column)
+        fieldIndices = append(fieldIndices, i)
+    }
+
+    rows := make([][]TableCell, v.Len())
+    for i := 0; i < v.Len(); i++ {
+        item := v.Index(i)
+        if item.Kind() == reflect.Ptr {
+            if item.IsNil() == true {
+                rows[i] = []TableCell{}
+                continue
+            }
+
+            item = item.Elem()
+        }
+
+        cells := make([]TableCell, len(fieldIndices))
+        for j, fieldIndex := range fieldIndices {
+            cells[j] = valueToTableCell(item.Field(fieldIndex))
+        }
+
+        rows[i] = cells
+    }
+
+    tw = NewTableWidget(columns, rows)
+
+    err = tw.Validate()
+    log.PanicIf(err)
+
+    return tw, nil
+}
+
+// parseTableTag returns the column described by the given tag.
+func parseTableTag(fieldName, tag string) (column TableColumn, err error) {
+    parts := strings.Split(tag, ",")
+
+    column.Header = parts[0]
+    if column.Header == "" {
+        column.Header = fieldName
+    }
+
+    for _, option := range parts[1:] {
+        option = strings.TrimSpace(option)
+
+        if strings.HasPrefix(option, "align=") == false {
+            return column, fmt.Errorf("%w: option not valid for field [%s]: [%s]", ErrInvalidTable, fieldName, option)
+        }
+
+        switch strings.TrimPrefix(option, "align=") {
+        case "left":
+            column.Alignment = LeftAlignment
+        case "center":
+            column.Alignment = CenterAlignment
+        case "right":
+            column.Alignment = RightAlignment
+        default:
+            return column, fmt.Errorf("%w: alignment not valid for field [%s]: [%s]", ErrInvalidTable, fieldName, option)
+        }
+    }
+
+    return column, nil
+}
+
+// valueToTableCell returns the cell for the value of one field.
+func valueToTableCell(v reflect.Value) TableCell {
+    if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() == true {
+        return NewTextCell("")
+    }
+
+    if v.Kind() == reflect.Ptr {
+        v = v.Elem()
+    }
+
+    switch value := v.Interface().(type) {
+    case TableCell:
+        return value
+    case LinkWidget:
+        return NewLinkCell(value.Text, value.Locator)
+    case ImageWidget:
+        return NewImageCell(value.AltText, value.Locator)
+    }
+
+    return NewTextCell(fmt.Sprintf("%v", v.Interface()))
+}
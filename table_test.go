@@ -0,0 +1,210 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "errors"
+    "strings"
+    "testing"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func TestTableWidget_Validate(t *testing.T) {
+    tw := NewTableWidget(nil, nil)
+
+    if err := tw.Validate(); errors.Is(err, ErrInvalidTable) != true {
+        t.Fatalf("Table without columns should not be valid: %v", err)
+    }
+
+    columns := []TableColumn{
+        NewTableColumn("a", DefaultAlignment),
+    }
+
+    rows := [][]TableCell{
+        {NewTextCell("1"), NewTextCell("2")},
+    }
+
+    tw = NewTableWidget(columns, rows)
+
+    if err := tw.Validate(); errors.Is(err, ErrInvalidTable) != true {
+        t.Fatalf("Table with a long row should not be valid: %v", err)
+    }
+}
+
+func TestTableWidget_Cell(t *testing.T) {
+    columns := []TableColumn{
+        NewTableColumn("a", DefaultAlignment),
+        NewTableColumn("b", DefaultAlignment),
+    }
+
+    rows := [][]TableCell{
+        {NewTextCell("1")},
+    }
+
+    tw := NewTableWidget(columns, rows)
+
+    if tc := tw.Cell(0, 0); tc.Text != "1" {
+        t.Fatalf("Cell not correct: %v", tc)
+    } else if tc := tw.Cell(0, 1); tc.Type != TextCellType || tc.Text != "" {
+        t.Fatalf("Padding cell not correct: %v", tc)
+    }
+}
+
+func TestNewTableWidgetFromStrings(t *testing.T) {
+    records := [][]string{
+        {"Name", "Size"},
+        {"a", "1"},
+        {"b"},
+    }
+
+    tw, err := NewTableWidgetFromStrings(records)
+    log.PanicIf(err)
+
+    if len(tw.Columns) != 2 || tw.Columns[1].Header != "Size" {
+        t.Fatalf("Columns not correct: %v", tw.Columns)
+    } else if len(tw.Rows) != 2 || tw.Rows[0][1].Text != "1" || len(tw.Rows[1]) != 1 {
+        t.Fatalf("Rows not correct: %v", tw.Rows)
+    }
+
+    _, err = NewTableWidgetFromStrings([][]string{{"a"}, {"1", "2"}})
+    if errors.Is(err, ErrInvalidTable) != true {
+        t.Fatalf("Long row should not be valid: %v", err)
+    }
+}
+
+func TestNewTableWidgetFromCsv(t *testing.T) {
+    r := strings.NewReader("Name,Description\na,\"one, two\"\nb,three\n")
+
+    tw, err := NewTableWidgetFromCsv(r)
+    log.PanicIf(err)
+
+    if len(tw.Columns) != 2 || tw.Columns[0].Header != "Name" {
+        t.Fatalf("Columns not correct: %v", tw.Columns)
+    } else if len(tw.Rows) != 2 || tw.Rows[0][1].Text != "one, two" {
+        t.Fatalf("Rows not correct: %v", tw.Rows)
+    }
+}
+
+func TestNewTableWidgetFromStructs(t *testing.T) {
+    type file struct {
+        Name     string
+        Size     int        `table:"Size (bytes),align=right"`
+        Download LinkWidget `table:",align=center"`
+        Checksum string     `table:"-"`
+        Preview  *ImageWidget
+        hidden   string
+    }
+
+    lrl := NewLocalResourceLocator("/some/file")
+    iw := NewImageWidget("preview", NewLocalResourceLocator("/some/image"), 0, 0)
+
+    items := []*file{
+        {"a", 100, NewLinkWidget("download", lrl), "abc", &iw, ""},
+        {Name: "b"},
+        nil,
+    }
+
+    tw, err := NewTableWidgetFromStructs(items)
+    log.PanicIf(err)
+
+    expectedColumns := []TableColumn{
+        NewTableColumn("Name", DefaultAlignment),
+        NewTableColumn("Size (bytes)", RightAlignment),
+        NewTableColumn("Download", CenterAlignment),
+        NewTableColumn("Preview", DefaultAlignment),
+    }
+
+    if len(tw.Columns) != len(expectedColumns) {
+        t.Fatalf("Columns not correct: %v", tw.Columns)
+    }
+
+    for i, tc := range expectedColumns {
+        if tw.Columns[i] != tc {
+            t.Fatalf("Column (%d) not correct: %v", i, tw.Columns[i])
+        }
+    }
+
+    if len(tw.Rows) != 3 {
+        t.Fatalf("Rows not correct: %v", tw.Rows)
+    }
+
+    row := tw.Rows[0]
+    if row[1].Type != TextCellType || row[1].Text != "100" {
+        t.Fatalf("Text cell not correct: %v", row[1])
+    } else if row[2].Type != LinkCellType || row[2].Text != "download" || row[2].Locator != lrl {
+        t.Fatalf("Link cell not correct: %v", row[2])
+    } else if row[3].Type != ImageCellType || row[3].Text != "preview" {
+        t.Fatalf("Image cell not correct: %v", row[3])
+    }
+
+    if tc := tw.Rows[1][3]; tc.Type != TextCellType || tc.Text != "" {
+        t.Fatalf("Nil image should be an empty cell: %v", tc)
+    } else if len(tw.Rows[2]) != 0 {
+        t.Fatalf("Nil item should be an empty row: %v", tw.Rows[2])
+    }
+}
+
+func TestNewTableWidgetFromStructs_Invalid(t *testing.T) {
+    _, err := NewTableWidgetFromStructs([]string{"a"})
+    if errors.Is(err, ErrInvalidTable) != true {
+        t.Fatalf("Slice of strings should not be valid: %v", err)
+    }
+
+    type item struct {
+        Value int `table:"Value,align=middle"`
+    }
+
+    _, err = NewTableWidgetFromStructs([]item{{1}})
+    if errors.Is(err, ErrInvalidTable) != true {
+        t.Fatalf("Alignment should not be valid: %v", err)
+    }
+}
+
+func TestTableWidget_RoundTrip(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    columns := []TableColumn{
+        NewTableColumn("Page", LeftAlignment),
+        NewTableColumn("Note", DefaultAlignment),
+    }
+
+    rows := [][]TableCell{
+        {NewLinkCell("child", NewSitePageLocalResourceLocator(sb, "child1")), NewTextCell("first")},
+    }
+
+    tw := NewTableWidget(columns, rows)
+    tw.Caption = "Pages"
+
+    err := sb.Root().Builder().AddTable(tw)
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    err = sb.Save(b)
+    log.PanicIf(err)
+
+    restoredSb, err := LoadSiteBuilder(b, NewTestDialect(), NewSiteContext(""))
+    log.PanicIf(err)
+
+    restoredTw := restoredSb.Root().Content.Statements[0].StatementMetadata["table"].(TableWidget)
+
+    if restoredTw.Caption != "Pages" || len(restoredTw.Columns) != 2 || restoredTw.Columns[0].Alignment != LeftAlignment {
+        t.Fatalf("Table not restored: %v", restoredTw)
+    } else if restoredTw.Rows[0][1].Text != "first" || restoredTw.Rows[0][1].Locator != nil {
+        t.Fatalf("Text cell not restored correctly: %v", restoredTw.Rows[0][1])
+    }
+
+    splrl := restoredTw.Rows[0][0].Locator.(*SitePageLocalResourceLocator)
+    if splrl.sb != restoredSb || splrl.Uri() != "child1.html" {
+        t.Fatalf("Link cell locator not restored correctly.")
+    }
+}
+
+func TestPageBuilder_AddTable_Invalid(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    err := sb.Root().Builder().AddTable(NewTableWidget(nil, nil))
+    if errors.Is(err, ErrInvalidTable) != true {
+        t.Fatalf("Invalid table should not be added: %v", err)
+    }
+}
@@ -15,6 +15,12 @@ func NewTestDialect() (md *TestDialect) {
     return &TestDialect{}
 }
 
+// SupportsWidgetType returns whether statements of the given type can be
+// rendered.
+func (md *TestDialect) SupportsWidgetType(wt WidgetType) bool {
+    return wt == ContentImage
+}
+
 // RenderIntermediate produces dialect-specific content that can be passed to
 // RenderHtml.
 func (md *TestDialect) RenderIntermediate(sn *SiteNode) (err error) {
@@ -23,14 +29,17 @@ func (md *TestDialect) RenderIntermediate(sn *SiteNode) (err error) {
     _, err = fmt.Fprintf(b, "## page-top | %s ##\n", sn.PageTitle)
     log.PanicIf(err)
 
-    for _, ps := range sn.Content.Statements {
+    for i, ps := range sn.Content.Statements {
         switch ps.Type {
         case ContentImage:
             iw := ps.StatementMetadata["image"].(ImageWidget)
 
-            uri := iw.Locator.Uri()
+            uri, err := CheckedResolveUri(iw.Locator, sn)
+            if err != nil {
+                log.Panic(NewStatementError(sn.PageId, i, err))
+            }
 
-            _, err := fmt.Fprintf(b, "## widget | image | %s | %s ##\n", iw.AltText, uri)
+            _, err = fmt.Fprintf(b, "## widget | image | %s | %s ##\n", iw.AltText, uri)
             log.PanicIf(err)
             // TODO(dustin): !! Finish handling navbar.
         default:
@@ -38,11 +47,6 @@ func (md *TestDialect) RenderIntermediate(sn *SiteNode) (err error) {
         }
     }
 
-    for _, childNode := range sn.Children {
-        err := md.RenderIntermediate(childNode)
-        log.PanicIf(err)
-    }
-
     _, err = fmt.Fprintf(b, "## page-bottom | %s ##\n", sn.PageTitle)
     log.PanicIf(err)
 
@@ -0,0 +1,244 @@
+package sitebuilder
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "strings"
+
+    "encoding/base64"
+)
+
+var (
+    // ErrMissingAltText indicates an image that has no alternative text.
+    ErrMissingAltText = errors.New("image has no alt text")
+
+    // ErrUnsupportedWidget indicates a statement whose widget type the dialect
+    // can not render.
+    ErrUnsupportedWidget = errors.New("widget type not supported by dialect")
+
+    // ErrDuplicateTitle indicates a page with the same title as another page.
+    ErrDuplicateTitle = errors.New("page title is not unique")
+)
+
+// ValidationProblem describes one problem found by Validate. The statement
+// index is the position of the statement in the page's PageContent or -1 if
+// the problem is with the page itself.
+type ValidationProblem struct {
+    PageId         string
+    StatementIndex int
+    Err            error
+}
+
+func (vp ValidationProblem) Error() string {
+    if vp.StatementIndex < 0 {
+        return fmt.Sprintf("page [%s]: %s", vp.PageId, vp.Err.Error())
+    }
+
+    return fmt.Sprintf("page [%s] statement (%d): %s", vp.PageId, vp.StatementIndex, vp.Err.Error())
+}
+
+// Unwrap returns the cause so that errors.Is() can be used to check for
+// errors like ErrPageNotFound.
+func (vp ValidationProblem) Unwrap() error {
+    return vp.Err
+}
+
+// ValidationError collects every problem found by Validate.
+type ValidationError struct {
+    Problems []ValidationProblem
+}
+
+func (ve *ValidationError) Error() string {
+    messages := make([]string, len(ve.Problems))
+    for i, vp := range ve.Problems {
+        messages[i] = vp.Error()
+    }
+
+    return fmt.Sprintf("(%d) problem(s) found: %s", len(ve.Problems), strings.Join(messages, "; "))
+}
+
+// Validate checks every node and statement without rendering anything and
+// returns a *ValidationError with all of the problems that were found, or nil
+// if there were none. Links to missing pages, local files that do not exist,
+// oversized embedded resources, images without alt text, widgets that the
+// dialect does not support, duplicate page titles, output paths that are not
+// valid, and feed sources on a site without a base URL are reported. Embedded
+// resources that exceed the budget are only reported if its policy is to
+// fail, and resources that are generated when rendering (e.g. thumbnails) are
+// not counted. Embedded files whose content does not match their extension
+// are only reported if the MIME-type mismatch policy is to fail.
+func (sb *SiteBuilder) Validate() error {
+    problems := make([]ValidationProblem, 0)
+
+    nodes := sb.rootNode.flatten()
+
+    // Local files may refer to pages that are yet to be written.
+    outputPaths := make(map[string]struct{}, len(nodes))
+    for _, sn := range nodes {
+        outputPaths[sn.OutputPath()] = struct{}{}
+    }
+
+    outputPathErrors := make(map[string]error)
+    for _, vp := range outputPathProblems(nodes, sb.siteContext.assetsPath) {
+        outputPathErrors[vp.PageId] = vp.Err
+    }
+
+    wts, _ := sb.dialect.(WidgetTypeSupporter)
+
+    titles := make(map[string]string, len(nodes))
+
+    eb := sb.siteContext.EmbeddedBudget()
+    siteEmbeddedSize := int64(0)
+
+    for _, sn := range nodes {
+        if firstPageId, found := titles[sn.PageTitle]; found == true {
+            err := fmt.Errorf("%w: [%s] is also used by [%s]", ErrDuplicateTitle, sn.PageTitle, firstPageId)
+            problems = append(problems, ValidationProblem{sn.PageId, -1, err})
+        } else {
+            titles[sn.PageTitle] = sn.PageId
+        }
+
+        if err, found := outputPathErrors[sn.PageId]; found == true {
+            problems = append(problems, ValidationProblem{sn.PageId, -1, err})
+        }
+
+        if _, found := sn.FeedSource(); found == true && sb.siteContext.baseUrl == "" {
+            err := fmt.Errorf("%w: feed source needs absolute URLs", ErrBaseUrlNotSet)
+            problems = append(problems, ValidationProblem{sn.PageId, -1, err})
+        }
+
+        pageEmbeddedSize := int64(0)
+
+        for i, ps := range sn.Content.Statements {
+            if wts != nil && wts.SupportsWidgetType(ps.Type) == false {
+                err := fmt.Errorf("%w: (%d)", ErrUnsupportedWidget, ps.Type)
+                problems = append(problems, ValidationProblem{sn.PageId, i, err})
+            }
+
+            if ps.Type == ContentImage {
+                if iw, ok := ps.StatementMetadata["image"].(ImageWidget); ok == true && strings.TrimSpace(iw.AltText) == "" {
+                    problems = append(problems, ValidationProblem{sn.PageId, i, ErrMissingAltText})
+                }
+            } else if ps.Type == Table {
+                if tw, ok := ps.StatementMetadata["table"].(TableWidget); ok == true {
+                    for _, row := range tw.Rows {
+                        for _, tc := range row {
+                            if tc.Type == ImageCellType && strings.TrimSpace(tc.Text) == "" {
+                                problems = append(problems, ValidationProblem{sn.PageId, i, ErrMissingAltText})
+                            }
+                        }
+                    }
+                }
+            } else if ps.Type == Gallery {
+                if gw, ok := ps.StatementMetadata["gallery"].(GalleryWidget); ok == true {
+                    for _, gi := range gw.Images {
+                        if strings.TrimSpace(gi.Caption) == "" {
+                            problems = append(problems, ValidationProblem{sn.PageId, i, ErrMissingAltText})
+                        }
+                    }
+                }
+            }
+
+            for _, rl := range ps.Locators() {
+                if err := sb.validateLocator(sn, rl, outputPaths); err != nil {
+                    problems = append(problems, ValidationProblem{sn.PageId, i, err})
+                    continue
+                }
+
+                if erl, ok := rl.(*EmbeddedResourceLocator); ok == true && eb.Policy == FailEmbeddedBudgetPolicy {
+                    // validateLocator has already checked that the size can be
+                    // determined.
+                    size, _ := embeddedResourceSize(erl)
+
+                    if err := eb.check(size, pageEmbeddedSize, siteEmbeddedSize); err != nil {
+                        problems = append(problems, ValidationProblem{sn.PageId, i, err})
+                    }
+
+                    pageEmbeddedSize += size
+                    siteEmbeddedSize += size
+                }
+            }
+        }
+    }
+
+    if len(problems) > 0 {
+        return &ValidationError{
+            Problems: problems,
+        }
+    }
+
+    return nil
+}
+
+// validateLocator checks that the resource that the locator refers to exists
+// without producing its URI, which may have side-effects.
+func (sb *SiteBuilder) validateLocator(sn *SiteNode, rl ResourceLocator, outputPaths map[string]struct{}) error {
+    switch t := rl.(type) {
+    case *SitePageLocalResourceLocator:
+        if _, found := sb.Node(t.PageId); found == false {
+            return fmt.Errorf("%w: [%s]", ErrPageNotFound, t.PageId)
+        }
+    case *LocalResourceLocator:
+        // Pages have not necessarily been written yet.
+        if outputPath, ok := t.outputPathFrom(sn); ok == true {
+            if _, found := outputPaths[outputPath]; found == true {
+                return nil
+            }
+        }
+
+        if _, err := os.Stat(t.filepathFrom(sn)); err != nil {
+            return fmt.Errorf("%w: %s", ErrResourceUnreadable, err.Error())
+        }
+    case *PublishedResourceLocator:
+        if _, err := os.Stat(t.LocalFilepath); err != nil {
+            return fmt.Errorf("%w: %s", ErrResourceUnreadable, err.Error())
+        }
+    case *EmbeddedResourceLocator:
+        _, err := embeddedResourceSize(t)
+        if err != nil {
+            return err
+        }
+
+        if t.Filepath != "" && sb.siteContext.MimeTypeMismatchPolicy() == FailMimeTypeMismatchPolicy {
+            head, err := readFileHead(t.Filepath)
+            if err != nil {
+                return fmt.Errorf("%w: %s", ErrResourceUnreadable, err.Error())
+            }
+
+            if err := checkMimeType(t.Filepath, head); err != nil {
+                return err
+            }
+        }
+    default:
+        if _, err := CheckedResolveUri(rl, sn); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// embeddedResourceSize returns the size of the data without reading it from
+// its file or decoding it.
+func embeddedResourceSize(erl *EmbeddedResourceLocator) (size int64, err error) {
+    erl.lock.Lock()
+    defer erl.lock.Unlock()
+
+    if encoded := erl.Base64EncodedData; encoded != "" {
+        if len(encoded)%4 != 0 {
+            return 0, fmt.Errorf("%w: embedded data is not valid base64", ErrResourceUnreadable)
+        }
+
+        padding := len(encoded) - len(strings.TrimRight(encoded, "="))
+
+        return int64(base64.StdEncoding.DecodedLen(len(encoded)) - padding), nil
+    }
+
+    fi, err := os.Stat(erl.Filepath)
+    if err != nil {
+        return 0, fmt.Errorf("%w: %s", ErrResourceUnreadable, err.Error())
+    }
+
+    return fi.Size(), nil
+}
@@ -0,0 +1,155 @@
+package sitebuilder
+
+import (
+    "errors"
+    "os"
+    "path"
+    "testing"
+
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func TestSiteBuilder_Validate_NoProblems(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    iw := NewImageWidget("alt text", NewSitePageLocalResourceLocator(sb, "child2"), 0, 0)
+
+    err := sb.Root().Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    // A relative local path to a page that is yet to be written is valid.
+    iw = NewImageWidget("alt text", NewOutputRelativeLocalResourceLocator("child1.html"), 0, 0)
+
+    err = sb.Root().Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    if err := sb.Validate(); err != nil {
+        t.Fatalf("Expected no problems: %s", err)
+    }
+}
+
+func TestSiteBuilder_Validate(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    oversizedFilepath := path.Join(tempPath, "oversized.png")
+
+    f, err := os.Create(oversizedFilepath)
+    log.PanicIf(err)
+
+    err = f.Truncate(MaxEmbeddedResourceSize + 1)
+    log.PanicIf(err)
+
+    f.Close()
+
+    sb := getLayoutTestSite(tempPath)
+
+    rootNode := sb.Root()
+    childNode1 := rootNode.Children[0]
+    childNode2 := rootNode.Children[1]
+
+    // Rendering is not required to find any of these.
+
+    _, err = rootNode.AddChildNode("another", "Child1")
+    log.PanicIf(err)
+
+    iw := NewImageWidget("", NewSitePageLocalResourceLocator(sb, "missing"), 0, 0)
+
+    err = childNode1.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    iw = NewImageWidget("alt text", NewLocalResourceLocator("missing.png"), 0, 0)
+
+    err = childNode2.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    // The size is only checked against the budget, not when the locator is
+    // constructed.
+    erl, err := NewEmbeddedResourceLocator(oversizedFilepath, "image/png", false)
+    log.PanicIf(err)
+
+    iw = NewImageWidget("alt text", erl, 0, 0)
+
+    err = childNode2.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    // The test dialect only supports images.
+    err = childNode2.Builder().AddLink(NewLinkWidget("text", NewSitePageLocalResourceLocator(sb, "index")))
+    log.PanicIf(err)
+
+    err = sb.Validate()
+
+    ve, ok := err.(*ValidationError)
+    if ok != true {
+        t.Fatalf("Expected a ValidationError: [%v]", err)
+    }
+
+    expected := []struct {
+        pageId         string
+        statementIndex int
+        err            error
+    }{
+        {"child1", 0, ErrMissingAltText},
+        {"child1", 0, ErrPageNotFound},
+        {"child2", 0, ErrResourceUnreadable},
+        {"child2", 1, ErrEmbeddedResourceTooLarge},
+        {"child2", 2, ErrUnsupportedWidget},
+        {"another", -1, ErrDuplicateTitle},
+    }
+
+    if len(ve.Problems) != len(expected) {
+        t.Fatalf("Number of problems not correct: %s", ve)
+    }
+
+    for i, e := range expected {
+        vp := ve.Problems[i]
+
+        if vp.PageId != e.pageId || vp.StatementIndex != e.statementIndex || errors.Is(vp, e.err) != true {
+            t.Fatalf("Problem (%d) not correct: %s", i, vp)
+        }
+    }
+}
+
+func TestSiteBuilder_Validate_RelativeLocalPaths(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getLayoutTestSite(tempPath)
+    sb.Context().SetOutputPathStrategy(NewDirectoryOutputPathStrategy())
+
+    childNode1 := sb.Root().Children[0]
+
+    err = os.Mkdir(path.Join(tempPath, "child1"), 0755)
+    log.PanicIf(err)
+
+    writeTestAsset(path.Join(tempPath, "child1"), "image.png")
+
+    // Relative paths are checked relative to the page, like the URIs that it
+    // links to, rather than to the current directory.
+
+    locators := []ResourceLocator{
+        NewLocalResourceLocator("image.png"),
+        NewLocalResourceLocator("../index.html"),
+        NewLocalResourceLocator("validate_test.go"),
+    }
+
+    for _, lrl := range locators {
+        err = childNode1.Builder().AddContentImage(NewImageWidget("alt text", lrl, 0, 0))
+        log.PanicIf(err)
+    }
+
+    err = sb.Validate()
+
+    ve, ok := err.(*ValidationError)
+    if ok != true {
+        t.Fatalf("Expected a ValidationError: [%v]", err)
+    } else if len(ve.Problems) != 1 || ve.Problems[0].PageId != "child1" || ve.Problems[0].StatementIndex != 2 || errors.Is(ve.Problems[0], ErrResourceUnreadable) != true {
+        t.Fatalf("Problems not correct: %s", ve)
+    }
+}
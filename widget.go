@@ -1,6 +1,10 @@
 package sitebuilder
 
-import ()
+import (
+    "encoding/json"
+
+    "github.com/dsoprea/go-logging"
+)
 
 type WidgetType int
 
@@ -11,6 +15,13 @@ const (
     VerticalNavbar
     Link
     Heading
+    ChildNavbar
+    Breadcrumb
+    SequenceNavigation
+    Paragraph
+    Table
+    CodeBlock
+    Gallery
 )
 
 // Image
@@ -19,11 +30,25 @@ type ImageWidget struct {
     AltText       string
     Locator       ResourceLocator
     Width, Height int
+
+    // VariantWidths, if not empty, are the widths of downscaled copies of the
+    // image that are generated when the page is rendered and offered to the
+    // browser via `srcset`.
+    VariantWidths []int
+
+    // Sizes is the `sizes` attribute that goes with the variants. By default,
+    // the image is shown at its width but never wider than the viewport.
+    Sizes string
+
+    // LazyLoading has the browser defer loading the image until it is near
+    // the viewport.
+    LazyLoading bool
 }
 
-// NewImageWidget creates an image widget. If width and height are zero, no
-// width/height will be prescribed. If just the width is given, the height will
-// be scaled accordingly.
+// NewImageWidget creates an image widget. If width and height are zero, they
+// are read from the image when the page is rendered, if the image is local or
+// embedded. If just one of them is given, the other will be scaled
+// accordingly.
 func NewImageWidget(altText string, locator ResourceLocator, width, height int) (iw ImageWidget) {
     return ImageWidget{
         AltText: altText,
@@ -33,6 +58,85 @@ func NewImageWidget(altText string, locator ResourceLocator, width, height int)
     }
 }
 
+// NewResponsiveImageWidget creates an image widget that is loaded lazily and
+// that has a downscaled variant for each of the given widths.
+func NewResponsiveImageWidget(altText string, locator ResourceLocator, variantWidths ...int) (iw ImageWidget) {
+    return ImageWidget{
+        AltText:       altText,
+        Locator:       locator,
+        VariantWidths: variantWidths,
+        LazyLoading:   true,
+    }
+}
+
+// MarshalJSON stores the widget along with the type of its locator.
+func (iw ImageWidget) MarshalJSON() (data []byte, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    locator, err := marshalResourceLocator(iw.Locator)
+    log.PanicIf(err)
+
+    stored := struct {
+        AltText       string
+        Locator       json.RawMessage
+        Width, Height int
+        VariantWidths []int
+        Sizes         string
+        LazyLoading   bool
+    }{
+        AltText:       iw.AltText,
+        Locator:       locator,
+        Width:         iw.Width,
+        Height:        iw.Height,
+        VariantWidths: iw.VariantWidths,
+        Sizes:         iw.Sizes,
+        LazyLoading:   iw.LazyLoading,
+    }
+
+    data, err = json.Marshal(stored)
+    log.PanicIf(err)
+
+    return data, nil
+}
+
+// UnmarshalJSON restores the widget and its locator.
+func (iw *ImageWidget) UnmarshalJSON(data []byte) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    stored := struct {
+        AltText       string
+        Locator       json.RawMessage
+        Width, Height int
+        VariantWidths []int
+        Sizes         string
+        LazyLoading   bool
+    }{}
+
+    err = json.Unmarshal(data, &stored)
+    log.PanicIf(err)
+
+    locator, err := unmarshalResourceLocator(stored.Locator)
+    log.PanicIf(err)
+
+    iw.AltText = stored.AltText
+    iw.Locator = locator
+    iw.Width = stored.Width
+    iw.Height = stored.Height
+    iw.VariantWidths = stored.VariantWidths
+    iw.Sizes = stored.Sizes
+    iw.LazyLoading = stored.LazyLoading
+
+    return nil
+}
+
 // Link
 
 type LinkWidget struct {
@@ -47,6 +151,56 @@ func NewLinkWidget(text string, locator ResourceLocator) (lw LinkWidget) {
     }
 }
 
+// MarshalJSON stores the widget along with the type of its locator.
+func (lw LinkWidget) MarshalJSON() (data []byte, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    locator, err := marshalResourceLocator(lw.Locator)
+    log.PanicIf(err)
+
+    stored := struct {
+        Text    string
+        Locator json.RawMessage
+    }{
+        Text:    lw.Text,
+        Locator: locator,
+    }
+
+    data, err = json.Marshal(stored)
+    log.PanicIf(err)
+
+    return data, nil
+}
+
+// UnmarshalJSON restores the widget and its locator.
+func (lw *LinkWidget) UnmarshalJSON(data []byte) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    stored := struct {
+        Text    string
+        Locator json.RawMessage
+    }{}
+
+    err = json.Unmarshal(data, &stored)
+    log.PanicIf(err)
+
+    locator, err := unmarshalResourceLocator(stored.Locator)
+    log.PanicIf(err)
+
+    lw.Text = stored.Text
+    lw.Locator = locator
+
+    return nil
+}
+
 // Navbar
 
 type NavbarWidget struct {
@@ -72,3 +226,290 @@ func NewHeadingWidget(level int, text string) HeadingWidget {
         Text:  text,
     }
 }
+
+// Child navbar
+
+// NavbarStyle determines how a navbar is laid out.
+type NavbarStyle int
+
+const (
+    HorizontalNavbarStyle NavbarStyle = iota
+    VerticalNavbarStyle
+)
+
+// ChildNavbarWidget is a navbar whose items are the children of the page it is
+// on. The items are determined when the page is rendered so that children that
+// are added after the widget still appear.
+type ChildNavbarWidget struct {
+    Style NavbarStyle
+}
+
+func NewChildNavbarWidget(style NavbarStyle) ChildNavbarWidget {
+    return ChildNavbarWidget{
+        Style: style,
+    }
+}
+
+// Items returns a link to each of the current children of the given node.
+func (cnw ChildNavbarWidget) Items(sn *SiteNode) (items []LinkWidget) {
+    items = make([]LinkWidget, len(sn.Children))
+
+    for i, childNode := range sn.Children {
+        splrl := NewSitePageLocalResourceLocator(sn.sb, childNode.PageId)
+        items[i] = NewLinkWidget(childNode.PageTitle, splrl)
+    }
+
+    return items
+}
+
+// Breadcrumb
+
+const (
+    defaultBreadcrumbSeparator = " > "
+)
+
+// BreadcrumbWidget shows the path from the root to the page it is on. The
+// path is determined when the page is rendered.
+type BreadcrumbWidget struct {
+    // RootText, if not empty, is shown for the root instead of its title.
+    RootText string
+
+    // Separator is shown between the crumbs.
+    Separator string
+}
+
+func NewBreadcrumbWidget() BreadcrumbWidget {
+    return BreadcrumbWidget{
+        Separator: defaultBreadcrumbSeparator,
+    }
+}
+
+// Items returns a link to each ancestor of the given node, starting with the
+// root, and the text of the node itself (which is not linked).
+func (bw BreadcrumbWidget) Items(sn *SiteNode) (items []LinkWidget, currentText string) {
+    ancestors := sn.Ancestors()
+
+    items = make([]LinkWidget, len(ancestors))
+
+    for i, ancestorNode := range ancestors {
+        splrl := NewSitePageLocalResourceLocator(sn.sb, ancestorNode.PageId)
+        items[i] = NewLinkWidget(bw.crumbText(ancestorNode), splrl)
+    }
+
+    return items, bw.crumbText(sn)
+}
+
+func (bw BreadcrumbWidget) crumbText(sn *SiteNode) string {
+    if sn.parent == nil && bw.RootText != "" {
+        return bw.RootText
+    }
+
+    return sn.PageTitle
+}
+
+// Sequence navigation
+
+// SequenceNavigationWidget links a page to the previous and next pages in
+// sequence and up to its parent. The links are determined when the page is
+// rendered.
+type SequenceNavigationWidget struct {
+    // AcrossSections, if true, orders every page in the site depth-first so
+    // that the first child of a section follows the section and the last page
+    // of a section is followed by the next section. Otherwise, only the
+    // siblings of the page are considered.
+    AcrossSections bool
+
+    PreviousLabel string
+    UpLabel       string
+    NextLabel     string
+}
+
+func NewSequenceNavigationWidget(acrossSections bool) SequenceNavigationWidget {
+    return SequenceNavigationWidget{
+        AcrossSections: acrossSections,
+        PreviousLabel:  "Previous",
+        UpLabel:        "Up",
+        NextLabel:      "Next",
+    }
+}
+
+// SequenceLinks are the resolved links of a SequenceNavigationWidget. Any of
+// them may be nil.
+type SequenceLinks struct {
+    Previous *LinkWidget
+    Up       *LinkWidget
+    Next     *LinkWidget
+}
+
+// Links determines the previous, up, and next links for the given node.
+func (snw SequenceNavigationWidget) Links(sn *SiteNode) (sl SequenceLinks) {
+    linkTo := func(target *SiteNode) *LinkWidget {
+        splrl := NewSitePageLocalResourceLocator(sn.sb, target.PageId)
+        lw := NewLinkWidget(target.PageTitle, splrl)
+
+        return &lw
+    }
+
+    if sn.parent != nil {
+        sl.Up = linkTo(sn.parent)
+    }
+
+    var sequence []*SiteNode
+    i := -1
+
+    if snw.AcrossSections == true {
+        ps := sn.sb.pageSequence()
+        sequence = ps.nodes
+
+        if position, found := ps.positions[sn.PageId]; found == true && sequence[position] == sn {
+            i = position
+        }
+    } else if sn.parent != nil {
+        sequence = sn.parent.Children
+
+        for j, current := range sequence {
+            if current == sn {
+                i = j
+                break
+            }
+        }
+    }
+
+    if i < 0 {
+        return sl
+    }
+
+    if i > 0 {
+        sl.Previous = linkTo(sequence[i-1])
+    }
+
+    if i < len(sequence)-1 {
+        sl.Next = linkTo(sequence[i+1])
+    }
+
+    return sl
+}
+
+// Paragraph
+
+// SpanStyle determines how the text of a span is formatted.
+type SpanStyle int
+
+const (
+    PlainSpanStyle SpanStyle = iota
+    EmphasisSpanStyle
+    StrongSpanStyle
+    CodeSpanStyle
+    LinkSpanStyle
+)
+
+// TextSpan is a run of text within a paragraph. The text is literal: the
+// dialects escape anything that would otherwise be interpreted as markup.
+// The locator is only used by link spans.
+type TextSpan struct {
+    Style   SpanStyle
+    Text    string
+    Locator ResourceLocator
+}
+
+func NewPlainSpan(text string) TextSpan {
+    return TextSpan{
+        Style: PlainSpanStyle,
+        Text:  text,
+    }
+}
+
+func NewEmphasisSpan(text string) TextSpan {
+    return TextSpan{
+        Style: EmphasisSpanStyle,
+        Text:  text,
+    }
+}
+
+func NewStrongSpan(text string) TextSpan {
+    return TextSpan{
+        Style: StrongSpanStyle,
+        Text:  text,
+    }
+}
+
+func NewCodeSpan(text string) TextSpan {
+    return TextSpan{
+        Style: CodeSpanStyle,
+        Text:  text,
+    }
+}
+
+func NewLinkSpan(text string, locator ResourceLocator) TextSpan {
+    return TextSpan{
+        Style:   LinkSpanStyle,
+        Text:    text,
+        Locator: locator,
+    }
+}
+
+// MarshalJSON stores the span along with the type of its locator.
+func (ts TextSpan) MarshalJSON() (data []byte, err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    locator, err := marshalResourceLocator(ts.Locator)
+    log.PanicIf(err)
+
+    stored := struct {
+        Style   SpanStyle
+        Text    string
+        Locator json.RawMessage
+    }{
+        Style:   ts.Style,
+        Text:    ts.Text,
+        Locator: locator,
+    }
+
+    data, err = json.Marshal(stored)
+    log.PanicIf(err)
+
+    return data, nil
+}
+
+// UnmarshalJSON restores the span and its locator.
+func (ts *TextSpan) UnmarshalJSON(data []byte) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    stored := struct {
+        Style   SpanStyle
+        Text    string
+        Locator json.RawMessage
+    }{}
+
+    err = json.Unmarshal(data, &stored)
+    log.PanicIf(err)
+
+    locator, err := unmarshalResourceLocator(stored.Locator)
+    log.PanicIf(err)
+
+    ts.Style = stored.Style
+    ts.Text = stored.Text
+    ts.Locator = locator
+
+    return nil
+}
+
+// ParagraphWidget is a paragraph of prose made up of spans of differently
+// formatted text.
+type ParagraphWidget struct {
+    Spans []TextSpan
+}
+
+func NewParagraphWidget(spans ...TextSpan) ParagraphWidget {
+    return ParagraphWidget{
+        Spans: spans,
+    }
+}
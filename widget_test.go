@@ -0,0 +1,250 @@
+package sitebuilder
+
+import (
+    "bytes"
+    "testing"
+
+    "github.com/dsoprea/go-logging"
+)
+
+func TestChildNavbarWidget_Items(t *testing.T) {
+    sc := NewSiteContext("")
+    sb := NewSiteBuilder("site title", NewTestDialect(), sc)
+
+    rootNode := sb.Root()
+
+    err := rootNode.Builder().AddChildNavbar(VerticalNavbarStyle)
+    log.PanicIf(err)
+
+    // The children are added after the navbar.
+
+    _, err = rootNode.AddChildNode("child1", "Child1")
+    log.PanicIf(err)
+
+    _, err = rootNode.AddChildNode("child2", "Child2")
+    log.PanicIf(err)
+
+    ps := rootNode.Content.Statements[0]
+    if ps.Type != ChildNavbar {
+        t.Fatalf("Statement type not correct: (%d)", ps.Type)
+    }
+
+    cnw := ps.StatementMetadata["child_navbar"].(ChildNavbarWidget)
+    if cnw.Style != VerticalNavbarStyle {
+        t.Fatalf("Style not correct: (%d)", cnw.Style)
+    }
+
+    items := cnw.Items(rootNode)
+
+    if len(items) != 2 {
+        t.Fatalf("Item count not correct: (%d)", len(items))
+    } else if items[0].Text != "Child1" || items[0].Locator.Uri() != "child1.html" {
+        t.Fatalf("First item not correct: %v", items[0])
+    } else if items[1].Text != "Child2" || items[1].Locator.Uri() != "child2.html" {
+        t.Fatalf("Second item not correct: %v", items[1])
+    }
+}
+
+func TestChildNavbarWidget_Items_NoChildren(t *testing.T) {
+    sc := NewSiteContext("")
+    sb := NewSiteBuilder("site title", NewTestDialect(), sc)
+
+    cnw := NewChildNavbarWidget(HorizontalNavbarStyle)
+
+    items := cnw.Items(sb.Root())
+    if len(items) != 0 {
+        t.Fatalf("Expected no items.")
+    }
+}
+
+func TestBreadcrumbWidget_Items(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    childChildNode1 := sb.Root().Children[0].Children[0]
+
+    bw := NewBreadcrumbWidget()
+
+    items, currentText := bw.Items(childChildNode1)
+
+    if len(items) != 2 {
+        t.Fatalf("Item count not correct: (%d)", len(items))
+    } else if items[0].Text != "site title" || items[0].Locator.Uri() != "index.html" {
+        t.Fatalf("First item not correct: %v", items[0])
+    } else if items[1].Text != "Child1" || items[1].Locator.Uri() != "child1.html" {
+        t.Fatalf("Second item not correct: %v", items[1])
+    } else if currentText != "ChildChild1" {
+        t.Fatalf("Current text not correct: [%s]", currentText)
+    } else if bw.Separator != " > " {
+        t.Fatalf("Default separator not correct: [%s]", bw.Separator)
+    }
+}
+
+func TestBreadcrumbWidget_Items_RootText(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    bw := NewBreadcrumbWidget()
+    bw.RootText = "Home"
+
+    items, _ := bw.Items(sb.Root().Children[0])
+
+    if items[0].Text != "Home" {
+        t.Fatalf("Root text not used: [%s]", items[0].Text)
+    }
+
+    items, currentText := bw.Items(sb.Root())
+
+    if len(items) != 0 {
+        t.Fatalf("Root should not have any linked items.")
+    } else if currentText != "Home" {
+        t.Fatalf("Root text not used for current page: [%s]", currentText)
+    }
+}
+
+func TestSequenceNavigationWidget_Links_Siblings(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    rootNode := sb.Root()
+    childNode1 := rootNode.Children[0]
+    childNode2 := rootNode.Children[1]
+
+    snw := NewSequenceNavigationWidget(false)
+
+    sl := snw.Links(childNode1)
+
+    if sl.Previous != nil {
+        t.Fatalf("Expected no previous link for the first child: %v", sl.Previous)
+    } else if sl.Up == nil || sl.Up.Text != "site title" || sl.Up.Locator.Uri() != "index.html" {
+        t.Fatalf("Up link not correct: %v", sl.Up)
+    } else if sl.Next == nil || sl.Next.Text != "Child2" || sl.Next.Locator.Uri() != "child2.html" {
+        t.Fatalf("Next link not correct: %v", sl.Next)
+    }
+
+    sl = snw.Links(childNode2)
+
+    if sl.Previous == nil || sl.Previous.Text != "Child1" {
+        t.Fatalf("Previous link not correct: %v", sl.Previous)
+    } else if sl.Next != nil {
+        t.Fatalf("Expected no next link for the last child: %v", sl.Next)
+    }
+
+    // The only child of a section has no siblings.
+
+    sl = snw.Links(childNode1.Children[0])
+
+    if sl.Previous != nil || sl.Next != nil {
+        t.Fatalf("Expected no sibling links: %v", sl)
+    } else if sl.Up == nil || sl.Up.Text != "Child1" {
+        t.Fatalf("Up link not correct: %v", sl.Up)
+    }
+
+    sl = snw.Links(rootNode)
+
+    if sl.Previous != nil || sl.Up != nil || sl.Next != nil {
+        t.Fatalf("Expected no links for the root: %v", sl)
+    }
+}
+
+func TestSequenceNavigationWidget_Links_AcrossSections(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    rootNode := sb.Root()
+    childNode1 := rootNode.Children[0]
+    childChildNode1 := childNode1.Children[0]
+
+    snw := NewSequenceNavigationWidget(true)
+
+    sl := snw.Links(rootNode)
+
+    if sl.Previous != nil || sl.Up != nil {
+        t.Fatalf("Expected only a next link for the root: %v", sl)
+    } else if sl.Next == nil || sl.Next.Text != "Child1" {
+        t.Fatalf("Next link not correct: %v", sl.Next)
+    }
+
+    sl = snw.Links(childChildNode1)
+
+    if sl.Previous == nil || sl.Previous.Text != "Child1" {
+        t.Fatalf("Previous link not correct: %v", sl.Previous)
+    } else if sl.Up == nil || sl.Up.Text != "Child1" {
+        t.Fatalf("Up link not correct: %v", sl.Up)
+    } else if sl.Next == nil || sl.Next.Text != "Child2" {
+        t.Fatalf("Next link not correct: %v", sl.Next)
+    }
+
+    sl = snw.Links(rootNode.Children[1])
+
+    if sl.Previous == nil || sl.Previous.Text != "ChildChild1" {
+        t.Fatalf("Previous link not correct: %v", sl.Previous)
+    } else if sl.Next != nil {
+        t.Fatalf("Expected no next link for the last page: %v", sl.Next)
+    }
+}
+
+func TestSiteBuilder_startBuild(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    endBuild := sb.startBuild()
+
+    // The pages are only ordered once per build, including nested builds.
+    ps := sb.pageSequence()
+
+    sb.startBuild()()
+
+    if sb.pageSequence() != ps {
+        t.Fatalf("Pages should only be ordered once per build.")
+    } else if len(ps.nodes) != 4 || ps.positions["child2"] != 3 {
+        t.Fatalf("Sequence not correct: %v", ps.positions)
+    }
+
+    endBuild()
+
+    if sb.sequence != nil {
+        t.Fatalf("Sequence should be dropped when the build ends.")
+    }
+
+    // Outside of a build, pages that were added since are included.
+    _, err := sb.Root().AddChildNode("child3", "Child3")
+    log.PanicIf(err)
+
+    sl := NewSequenceNavigationWidget(true).Links(sb.Root().Children[1])
+    if sl.Next == nil || sl.Next.Text != "Child3" {
+        t.Fatalf("Next link not correct: %v", sl.Next)
+    }
+}
+
+func TestParagraphWidget_RoundTrip(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    pw := NewParagraphWidget(
+        NewPlainSpan("Some "),
+        NewStrongSpan("strong"),
+        NewPlainSpan(" text and a "),
+        NewLinkSpan("link", NewSitePageLocalResourceLocator(sb, "child1")),
+    )
+
+    err := sb.Root().Builder().AddParagraph(pw)
+    log.PanicIf(err)
+
+    b := new(bytes.Buffer)
+
+    err = sb.Save(b)
+    log.PanicIf(err)
+
+    restoredSb, err := LoadSiteBuilder(b, NewTestDialect(), NewSiteContext(""))
+    log.PanicIf(err)
+
+    restoredPw := restoredSb.Root().Content.Statements[0].StatementMetadata["paragraph"].(ParagraphWidget)
+
+    if len(restoredPw.Spans) != 4 {
+        t.Fatalf("Spans not restored: %v", restoredPw)
+    } else if restoredPw.Spans[1].Style != StrongSpanStyle || restoredPw.Spans[1].Text != "strong" {
+        t.Fatalf("Span not restored correctly: %v", restoredPw.Spans[1])
+    } else if restoredPw.Spans[0].Locator != nil {
+        t.Fatalf("Plain span should not have a locator.")
+    }
+
+    splrl := restoredPw.Spans[3].Locator.(*SitePageLocalResourceLocator)
+    if splrl.sb != restoredSb || splrl.Uri() != "child1.html" {
+        t.Fatalf("Link span locator not restored correctly.")
+    }
+}
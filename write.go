@@ -0,0 +1,342 @@
+package sitebuilder
+
+import (
+    "fmt"
+    "os"
+    "path"
+    "runtime"
+    "strings"
+    "sync"
+
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// WriteOptions controls how the site is rendered and written.
+type WriteOptions struct {
+    // Concurrency is the number of nodes that are rendered and written at the
+    // same time. If zero, the number of CPUs is used.
+    Concurrency int
+}
+
+// NodeError describes the failure to render or write one node.
+type NodeError struct {
+    PageId string
+    Err    error
+}
+
+func (ne NodeError) Error() string {
+    return fmt.Sprintf("page [%s]: %s", ne.PageId, ne.Err.Error())
+}
+
+// Unwrap returns the cause so that errors.Is() can be used to check for
+// errors like ErrResourceUnreadable.
+func (ne NodeError) Unwrap() error {
+    return unwrapStack(ne.Err)
+}
+
+// StatementError describes the failure to render one statement of a page.
+// The statement index is the position of the statement in the page's
+// PageContent.
+type StatementError struct {
+    PageId         string
+    StatementIndex int
+    Err            error
+}
+
+func NewStatementError(pageId string, statementIndex int, err error) *StatementError {
+    return &StatementError{
+        PageId:         pageId,
+        StatementIndex: statementIndex,
+        Err:            err,
+    }
+}
+
+func (se *StatementError) Error() string {
+    return fmt.Sprintf("page [%s] statement (%d): %s", se.PageId, se.StatementIndex, se.Err.Error())
+}
+
+// Unwrap returns the cause so that errors.Is() can be used to check for
+// errors like ErrPageNotFound.
+func (se *StatementError) Unwrap() error {
+    return unwrapStack(se.Err)
+}
+
+// unwrapStack removes the stack-trace wrapper that log.Wrap() adds, if there
+// is one. The wrapper does not implement Unwrap(), so errors.Is() and
+// errors.As() can not see past it.
+func unwrapStack(err error) error {
+    if wrapped := log.Wrap(err); error(wrapped) == err {
+        return wrapped.Err
+    }
+
+    return err
+}
+
+// recoveredError returns the error of a recovered panic without its
+// stack-trace wrapper. Exported functions whose errors are documented to wrap
+// errors like ErrResourceUnreadable return it so that callers can use
+// errors.Is().
+func recoveredError(state interface{}) error {
+    return unwrapStack(state.(error))
+}
+
+// StatementErrors returns every StatementError found in the given error,
+// including those of every page in a *WriteError.
+func StatementErrors(err error) (statementErrors []*StatementError) {
+    statementErrors = make([]*StatementError, 0)
+
+    if err == nil {
+        return statementErrors
+    }
+
+    switch e := err.(type) {
+    case *StatementError:
+        return append(statementErrors, e)
+    case *WriteError:
+        for _, ne := range e.NodeErrors {
+            statementErrors = append(statementErrors, StatementErrors(ne.Err)...)
+        }
+
+        return statementErrors
+    case NodeError:
+        return StatementErrors(e.Err)
+    }
+
+    if cause := unwrapStack(err); cause != err {
+        return StatementErrors(cause)
+    }
+
+    if wrapper, ok := err.(interface{ Unwrap() error }); ok == true {
+        return StatementErrors(wrapper.Unwrap())
+    }
+
+    return statementErrors
+}
+
+// WriteError collects the failures of every node that could not be rendered
+// or written.
+type WriteError struct {
+    NodeErrors []NodeError
+}
+
+func (we *WriteError) Error() string {
+    messages := make([]string, len(we.NodeErrors))
+    for i, ne := range we.NodeErrors {
+        messages[i] = ne.Error()
+    }
+
+    return fmt.Sprintf("(%d) page(s) could not be written: %s", len(we.NodeErrors), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the error of every node so that errors.Is() can be used to
+// check whether any of them failed with an error like ErrResourceUnreadable.
+func (we *WriteError) Unwrap() []error {
+    errs := make([]error, len(we.NodeErrors))
+    for i, ne := range we.NodeErrors {
+        errs[i] = ne
+    }
+
+    return errs
+}
+
+// WriteToPathWithOptions renders every node and writes it to the output path
+// on a pool of workers. Each page is written as soon as it is rendered and its
+// output is then released, so the output of the whole site is never held at
+// once. A failing node does not stop the others; if any nodes failed, a
+// *WriteError describing every failure is returned once all nodes have been
+// processed.
+func (sb *SiteBuilder) WriteToPathWithOptions(options WriteOptions) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = recoveredError(state)
+        }
+    }()
+
+    err = sb.checkOutputPaths()
+    log.PanicIf(err)
+
+    err = os.MkdirAll(sb.siteContext.htmlOutputPath, 0755)
+    log.PanicIf(err)
+
+    defer sb.startBuild()()
+    defer sb.assets.startWriting()()
+
+    nodes := sb.rootNode.flatten()
+
+    we := sb.writeNodes(nodes, options)
+    if we != nil {
+        return we
+    }
+
+    err = sb.assets.writeToPath(sb.siteContext)
+    log.PanicIf(err)
+
+    err = sb.writeSearchMetadata()
+    log.PanicIf(err)
+
+    err = sb.writeFeeds()
+    log.PanicIf(err)
+
+    return nil
+}
+
+// writeNodes renders and writes the given nodes on a pool of workers. It
+// returns nil if every node succeeded.
+func (sb *SiteBuilder) writeNodes(nodes []*SiteNode, options WriteOptions) *WriteError {
+    concurrency := options.Concurrency
+    if concurrency <= 0 {
+        concurrency = runtime.NumCPU()
+    }
+
+    nodeC := make(chan *SiteNode)
+
+    we := &WriteError{
+        NodeErrors: make([]NodeError, 0),
+    }
+
+    var weLock sync.Mutex
+    var wg sync.WaitGroup
+
+    for i := 0; i < concurrency; i++ {
+        wg.Add(1)
+
+        go func() {
+            defer wg.Done()
+
+            for sn := range nodeC {
+                err := sb.renderAndWriteNode(sn)
+                if err != nil {
+                    weLock.Lock()
+                    we.NodeErrors = append(we.NodeErrors, NodeError{PageId: sn.PageId, Err: err})
+                    weLock.Unlock()
+                }
+            }
+        }()
+    }
+
+    for _, sn := range nodes {
+        nodeC <- sn
+    }
+
+    close(nodeC)
+    wg.Wait()
+
+    if len(we.NodeErrors) > 0 {
+        return we
+    }
+
+    return nil
+}
+
+// renderAndWriteNode renders and writes a single node.
+func (sb *SiteBuilder) renderAndWriteNode(sn *SiteNode) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    err = sn.renderNode()
+    log.PanicIf(err)
+
+    err = sb.writeNode(sn)
+    log.PanicIf(err)
+
+    // The output is not needed anymore and is not kept for the rest of the
+    // build.
+    sn.releaseOutput()
+
+    return nil
+}
+
+// writeNode writes the final output of a single node.
+func (sb *SiteBuilder) writeNode(sn *SiteNode) (err error) {
+    defer func() {
+        if state := recover(); state != nil {
+            err = log.Wrap(state.(error))
+        }
+    }()
+
+    pageFilepath := path.Join(sb.siteContext.htmlOutputPath, sn.OutputPath())
+
+    err = os.MkdirAll(path.Dir(pageFilepath), 0755)
+    log.PanicIf(err)
+
+    finalOutput := sn.FinalOutput()
+
+    err = ioutil.WriteFile(pageFilepath, finalOutput, 0666)
+    log.PanicIf(err)
+
+    return nil
+}
+
+// flatten returns this node and all of its descendants in depth-first order.
+func (sn *SiteNode) flatten() (nodes []*SiteNode) {
+    nodes = []*SiteNode{sn}
+
+    for _, childNode := range sn.Children {
+        nodes = append(nodes, childNode.flatten()...)
+    }
+
+    return nodes
+}
+
+// pageSequence is every page in depth-first order along with the position of
+// each page-ID in it.
+type pageSequence struct {
+    nodes     []*SiteNode
+    positions map[string]int
+}
+
+func newPageSequence(rootNode *SiteNode) *pageSequence {
+    nodes := rootNode.flatten()
+
+    positions := make(map[string]int, len(nodes))
+    for i, sn := range nodes {
+        positions[sn.PageId] = i
+    }
+
+    return &pageSequence{
+        nodes:     nodes,
+        positions: positions,
+    }
+}
+
+// startBuild orders the pages once for the duration of a build so that they
+// do not have to be ordered again for every page that navigates across
+// sections. The returned function ends the build. Builds may be nested.
+func (sb *SiteBuilder) startBuild() (endBuild func()) {
+    sb.sequenceLock.Lock()
+    defer sb.sequenceLock.Unlock()
+
+    if sb.buildDepth == 0 {
+        sb.sequence = newPageSequence(sb.rootNode)
+    }
+
+    sb.buildDepth++
+
+    return func() {
+        sb.sequenceLock.Lock()
+        defer sb.sequenceLock.Unlock()
+
+        sb.buildDepth--
+        if sb.buildDepth == 0 {
+            sb.sequence = nil
+        }
+    }
+}
+
+// pageSequence returns the order of the pages of the build in progress or,
+// outside of a build, of the pages as they are now.
+func (sb *SiteBuilder) pageSequence() *pageSequence {
+    sb.sequenceLock.Lock()
+    defer sb.sequenceLock.Unlock()
+
+    if sb.sequence != nil {
+        return sb.sequence
+    }
+
+    return newPageSequence(sb.rootNode)
+}
@@ -0,0 +1,261 @@
+package sitebuilder
+
+import (
+    "errors"
+    "fmt"
+    "os"
+    "path"
+    "sort"
+    "testing"
+
+    "io/ioutil"
+
+    "github.com/dsoprea/go-logging"
+)
+
+// failingDialect wraps the test dialect and fails for specific pages.
+type failingDialect struct {
+    *TestDialect
+
+    failingPageIds map[string]struct{}
+}
+
+func (fd *failingDialect) RenderIntermediate(sn *SiteNode) (err error) {
+    if _, found := fd.failingPageIds[sn.PageId]; found == true {
+        return fmt.Errorf("failing page [%s]", sn.PageId)
+    }
+
+    return fd.TestDialect.RenderIntermediate(sn)
+}
+
+func TestSiteBuilder_WriteToPathWithOptions(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sc := NewSiteContext(tempPath)
+    sb := NewSiteBuilder("site title", NewTestDialect(), sc)
+
+    rootNode := sb.Root()
+
+    // Share one embedded locator among every page so that the deferred read
+    // happens concurrently.
+
+    assetFilepath := writeTestAsset(tempPath, "image.png")
+
+    erl, err := NewEmbeddedResourceLocator(assetFilepath, "", false)
+    log.PanicIf(err)
+
+    expectedFiles := []string{"assets", "image.png", "index.html"}
+
+    for i := 0; i < 20; i++ {
+        pageId := fmt.Sprintf("child%d", i)
+
+        childNode, err := rootNode.AddChildNode(pageId, fmt.Sprintf("Child%d", i))
+        log.PanicIf(err)
+
+        pb := childNode.Builder()
+
+        err = pb.AddContentImage(NewImageWidget("embedded", erl, 0, 0))
+        log.PanicIf(err)
+
+        err = pb.AddContentImage(NewImageWidget("published", NewPublishedResourceLocator(sb, assetFilepath), 0, 0))
+        log.PanicIf(err)
+
+        err = pb.AddContentImage(NewImageWidget("page", NewSitePageLocalResourceLocator(sb, "index"), 0, 0))
+        log.PanicIf(err)
+
+        expectedFiles = append(expectedFiles, fmt.Sprintf("%s.html", pageId))
+    }
+
+    options := WriteOptions{
+        Concurrency: 8,
+    }
+
+    err = sb.WriteToPathWithOptions(options)
+    log.PanicIf(err)
+
+    files, err := ioutil.ReadDir(tempPath)
+    log.PanicIf(err)
+
+    actualFiles := make([]string, 0)
+    for _, fi := range files {
+        actualFiles = append(actualFiles, fi.Name())
+    }
+
+    sort.Strings(actualFiles)
+    sort.Strings(expectedFiles)
+
+    if fmt.Sprintf("%v", actualFiles) != fmt.Sprintf("%v", expectedFiles) {
+        t.Fatalf("Exact files weren't produced: %v", actualFiles)
+    }
+
+    actual, err := ioutil.ReadFile(path.Join(tempPath, "child7.html"))
+    log.PanicIf(err)
+
+    expected := fmt.Sprintf(`<header>Child7</header>
+<widget>embedded | data:image/png;base64,AQID</widget>
+<widget>published | assets/%s.png</widget>
+<widget>page | index.html</widget>
+<footer>Child7</footer>
+`, testAssetDigest)
+
+    if string(actual) != expected {
+        t.Fatalf("Page not correct:\n%s", actual)
+    }
+
+    // The output of the written pages is not kept.
+    for _, sn := range rootNode.flatten() {
+        if sn.intermediateOutput != nil || sn.finalOutput != nil {
+            t.Fatalf("Output of [%s] was not released.", sn.PageId)
+        }
+    }
+}
+
+func TestSiteBuilder_WriteToPathWithOptions_CollectErrors(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    fd := &failingDialect{
+        TestDialect: NewTestDialect(),
+        failingPageIds: map[string]struct{}{
+            "child1":      struct{}{},
+            "childChild1": struct{}{},
+        },
+    }
+
+    sc := NewSiteContext(tempPath)
+    sb := NewSiteBuilder("site title", fd, sc)
+
+    rootNode := sb.Root()
+
+    childNode1, err := rootNode.AddChildNode("child1", "Child1")
+    log.PanicIf(err)
+
+    _, err = rootNode.AddChildNode("child2", "Child2")
+    log.PanicIf(err)
+
+    _, err = childNode1.AddChildNode("childChild1", "ChildChild1")
+    log.PanicIf(err)
+
+    options := WriteOptions{
+        Concurrency: 3,
+    }
+
+    err = sb.WriteToPathWithOptions(options)
+    if err == nil {
+        t.Fatalf("Expected error.")
+    }
+
+    we, ok := err.(*WriteError)
+    if ok != true {
+        t.Fatalf("Expected a WriteError: [%s]", err)
+    }
+
+    failedPageIds := make([]string, 0)
+    for _, ne := range we.NodeErrors {
+        failedPageIds = append(failedPageIds, ne.PageId)
+    }
+
+    sort.Strings(failedPageIds)
+
+    if fmt.Sprintf("%v", failedPageIds) != "[child1 childChild1]" {
+        t.Fatalf("Failed pages not correct: %v", failedPageIds)
+    }
+
+    // The pages that didn't fail should have still been written.
+
+    for _, filename := range []string{"index.html", "child2.html"} {
+        _, err := os.Stat(path.Join(tempPath, filename))
+        log.PanicIf(err)
+    }
+}
+
+func TestSiteNode_flatten(t *testing.T) {
+    sb := getLayoutTestSite("")
+
+    nodes := sb.Root().flatten()
+
+    pageIds := make([]string, len(nodes))
+    for i, sn := range nodes {
+        pageIds[i] = sn.PageId
+    }
+
+    if fmt.Sprintf("%v", pageIds) != "[index child1 childChild1 child2]" {
+        t.Fatalf("Nodes not correct: %v", pageIds)
+    }
+}
+
+func TestStatementErrors(t *testing.T) {
+    tempPath, err := ioutil.TempDir("", "gssb")
+    log.PanicIf(err)
+
+    defer os.RemoveAll(tempPath)
+
+    sb := getLayoutTestSite(tempPath)
+
+    rootNode := sb.Root()
+    childNode2 := rootNode.Children[1]
+
+    iw := NewImageWidget("good image", NewLocalResourceLocator("/some/image/path"), 0, 0)
+
+    err = childNode2.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    iw = NewImageWidget("bad image", NewPublishedResourceLocator(sb, path.Join(tempPath, "missing.png")), 0, 0)
+
+    err = childNode2.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    iw = NewImageWidget("bad page", NewSitePageLocalResourceLocator(sb, "missing"), 0, 0)
+
+    err = rootNode.Builder().AddContentImage(iw)
+    log.PanicIf(err)
+
+    err = sb.WriteToPath()
+    if err == nil {
+        t.Fatalf("Expected error.")
+    }
+
+    // The causes can be found from the top-level error.
+
+    if errors.Is(err, ErrResourceUnreadable) != true {
+        t.Fatalf("Expected unreadable resource: [%s]", err)
+    } else if errors.Is(err, ErrPageNotFound) != true {
+        t.Fatalf("Expected missing page: [%s]", err)
+    }
+
+    var firstSe *StatementError
+    if errors.As(err, &firstSe) != true {
+        t.Fatalf("Expected a StatementError: [%s]", err)
+    }
+
+    statementErrors := StatementErrors(err)
+
+    sort.Slice(statementErrors, func(i, j int) bool {
+        return statementErrors[i].PageId < statementErrors[j].PageId
+    })
+
+    if len(statementErrors) != 2 {
+        t.Fatalf("Expected two statement errors: %v", statementErrors)
+    }
+
+    se := statementErrors[0]
+    if se.PageId != "child2" || se.StatementIndex != 1 {
+        t.Fatalf("First statement error not correct: [%s]", se)
+    } else if errors.Is(se, ErrResourceUnreadable) != true {
+        t.Fatalf("Expected unreadable resource: [%s]", se)
+    }
+
+    se = statementErrors[1]
+    if se.PageId != "index" || se.StatementIndex != 0 {
+        t.Fatalf("Second statement error not correct: [%s]", se)
+    } else if errors.Is(se, ErrPageNotFound) != true {
+        t.Fatalf("Expected missing page: [%s]", se)
+    } else if se.Error() != "page [index] statement (0): page not found: [missing]" {
+        t.Fatalf("Message not correct: [%s]", se.Error())
+    }
+}